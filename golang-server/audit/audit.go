@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"encoding/json"
+	"log/slog"
+
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/middleware"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ActionRegister     = "auth.register"
+	ActionLogin        = "auth.login"
+	ActionLoginFailed  = "auth.login_failed"
+	ActionOIDCLogin    = "auth.oidc_login"
+	ActionOIDCLink     = "auth.oidc_link"
+	ActionUserUpdate   = "user.update"
+	ActionAPIKeyCreate = "api_key.create"
+	ActionAPIKeyRevoke = "api_key.revoke"
+
+	ActionTextReadingCreate = "text_reading.create"
+	ActionTextReadingUpdate = "text_reading.update"
+	ActionTextReadingDelete = "text_reading.delete"
+
+	ActionOCRCachePurge = "ocr_cache.purge"
+)
+
+const (
+	TargetUser        = "user"
+	TargetAPIKey      = "api_key"
+	TargetTextReading = "text_reading"
+	TargetOCRCache    = "ocr_cache"
+)
+
+// Entry describes an event to record. When ActorID is nil the actor is taken
+// from the claims of the authenticated request, if any.
+type Entry struct {
+	ActorID    *uint
+	ActorName  string
+	Action     string
+	TargetType string
+	TargetID   string
+	Success    bool
+	Details    map[string]interface{}
+}
+
+// Recorder stores audit events.
+type Recorder interface {
+	Record(c *gin.Context, entry Entry)
+}
+
+// RepositoryRecorder stores audit events in the audit log repository.
+type RepositoryRecorder struct {
+	Events repository.AuditRepository
+}
+
+// Record appends an audit event for the request. Failures are logged and never
+// fail the request itself.
+func (r RepositoryRecorder) Record(c *gin.Context, entry Entry) {
+	event := models.AuditEvent{
+		ActorID:    entry.ActorID,
+		ActorName:  entry.ActorName,
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		Success:    entry.Success,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+
+	if event.ActorID == nil {
+		if value, ok := c.Get(middleware.ContextClaims); ok {
+			if claims, ok := value.(*auth.Claims); ok {
+				event.ActorID = &claims.UserID
+				event.ActorName = claims.Username
+			}
+		}
+	}
+
+	if len(entry.Details) > 0 {
+		details, err := json.Marshal(entry.Details)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "Failed to encode audit details", "action", entry.Action, "error", err)
+		} else {
+			event.Details = string(details)
+		}
+	}
+
+	if err := r.Events.Create(c.Request.Context(), &event); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to record audit event", "action", entry.Action, "error", err)
+	}
+}
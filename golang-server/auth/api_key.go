@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const apiKeyPrefix = "ocr"
+
+const (
+	ScopeOCR               = "ocr"
+	ScopeTextReadingsRead  = "text-readings:read"
+	ScopeTextReadingsWrite = "text-readings:write"
+)
+
+var AllScopes = []string{ScopeOCR, ScopeTextReadingsRead, ScopeTextReadingsWrite}
+
+// GenerateAPIKey returns a new key in the form "ocr_<prefix>_<secret>" together
+// with its lookup prefix and the hash that should be stored instead of the key.
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	prefixBytes := make([]byte, 4)
+	if _, err := rand.Read(prefixBytes); err != nil {
+		return "", "", "", fmt.Errorf("error while generating key prefix: %v", err)
+	}
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", "", fmt.Errorf("error while generating key secret: %v", err)
+	}
+
+	prefix = hex.EncodeToString(prefixBytes)
+	key = fmt.Sprintf("%s_%s_%s", apiKeyPrefix, prefix, base64.RawURLEncoding.EncodeToString(secretBytes))
+
+	return key, prefix, HashAPIKey(key), nil
+}
+
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseAPIKey extracts the lookup prefix from a key, reporting false if the key
+// is not in the expected format.
+func ParseAPIKey(key string) (string, bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+func CompareAPIKey(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(hash)) == 1
+}
+
+func ValidScope(scope string) bool {
+	for _, s := range AllScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
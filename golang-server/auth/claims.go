@@ -0,0 +1,21 @@
+package auth
+
+import "github.com/golang-jwt/jwt"
+
+// Claims are the claims carried by the API's tokens. The subject is the
+// user's ID; UserID repeats it as a number for convenience.
+type Claims struct {
+	UserID   uint     `json:"uid"`
+	Username string   `json:"username"`
+	Roles    []string `json:"roles,omitempty"`
+	jwt.StandardClaims
+}
+
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
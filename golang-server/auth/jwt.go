@@ -2,22 +2,67 @@ package auth
 
 import (
 	"fmt"
-	"os"
+	"strconv"
 	"time"
 
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/example/golang-postgres-crud/models"
 	"github.com/golang-jwt/jwt"
 )
 
-var secretKey = []byte(os.Getenv("JWT-SECRET-KEY"))
+const (
+	tokenLifetime = time.Hour * 24
+	// linkTokenLifetime leaves time to sign in with the identity provider
+	// while linking an account, as long as the flow cookie lasts.
+	linkTokenLifetime = 10 * time.Minute
+)
 
-func CreateToken(username string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256,
-		jwt.MapClaims{
-			"username": username,
-			"exp":      time.Now().Add(time.Hour * 24).Unix(),
-		})
+// linkAudience returns the audience of link tokens. It differs from the
+// audience of access tokens, so VerifyToken rejects link tokens and
+// VerifyLinkToken rejects access tokens.
+func linkAudience() string {
+	return config.Current.Auth.Audience + "/oidc-link"
+}
 
-	tokenString, err := token.SignedString(secretKey)
+func CreateToken(user models.User) (string, error) {
+	return createToken(user, config.Current.Auth.Audience, tokenLifetime)
+}
+
+// CreateLinkToken issues a short-lived token that only names the user
+// linking an OIDC identity to their account, for VerifyLinkToken. It is not
+// an access token.
+func CreateLinkToken(user models.User) (string, error) {
+	return createToken(user, linkAudience(), linkTokenLifetime)
+}
+
+func createToken(user models.User, audience string, lifetime time.Duration) (string, error) {
+	key := signingKey(time.Now())
+	if key == nil {
+		return "", fmt.Errorf("no active signing key")
+	}
+
+	tokenID, err := randomString(16)
+	if err != nil {
+		return "", fmt.Errorf("error while generating token ID: %v", err)
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(key.Method, &Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Roles:    []string{user.Role},
+		StandardClaims: jwt.StandardClaims{
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			Issuer:    config.Current.Auth.Issuer,
+			Audience:  audience,
+			Id:        tokenID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(lifetime).Unix(),
+		},
+	})
+	token.Header["kid"] = key.ID
+
+	tokenString, err := token.SignedString(key.signingMaterial())
 	if err != nil {
 		return "", fmt.Errorf("error while signing token: %v", err)
 	}
@@ -25,21 +70,77 @@ func CreateToken(username string) (string, error) {
 	return tokenString, nil
 }
 
-func VerifyToken(tokenString string) error {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+func VerifyToken(tokenString string) (*Claims, error) {
+	return verifyToken(tokenString, config.Current.Auth.Audience)
+}
+
+// VerifyLinkToken verifies a token issued by CreateLinkToken.
+func VerifyLinkToken(tokenString string) (*Claims, error) {
+	return verifyToken(tokenString, linkAudience())
+}
+
+func verifyToken(tokenString, audience string) (*Claims, error) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key := verificationKey(kid)
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key: %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		return secretKey, nil
+		if !key.verifiesAt(time.Now()) {
+			return nil, fmt.Errorf("signing key %q is retired", kid)
+		}
+		return key.verificationMaterial(), nil
 	})
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if !token.Valid {
-		return fmt.Errorf("invalid token")
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	if !claims.VerifyIssuer(config.Current.Auth.Issuer, true) {
+		return nil, fmt.Errorf("unexpected issuer: %q", claims.Issuer)
+	}
+	if !claims.VerifyAudience(audience, true) {
+		return nil, fmt.Errorf("unexpected audience: %q", claims.Audience)
 	}
+	if claims.Subject != strconv.FormatUint(uint64(claims.UserID), 10) {
+		return nil, fmt.Errorf("subject does not match user ID")
+	}
+
+	return claims, nil
+}
+
+// TokenService issues and verifies the API's access tokens, and the link
+// tokens that carry an OIDC linking flow.
+type TokenService interface {
+	CreateToken(user models.User) (string, error)
+	VerifyToken(tokenString string) (*Claims, error)
+	CreateLinkToken(user models.User) (string, error)
+	VerifyLinkToken(tokenString string) (*Claims, error)
+}
+
+// JWTService is the TokenService backed by the keys loaded with LoadKeys.
+type JWTService struct{}
+
+func (JWTService) CreateToken(user models.User) (string, error) {
+	return CreateToken(user)
+}
+
+func (JWTService) VerifyToken(tokenString string) (*Claims, error) {
+	return VerifyToken(tokenString)
+}
+
+func (JWTService) CreateLinkToken(user models.User) (string, error) {
+	return CreateLinkToken(user)
+}
 
-	return nil
+func (JWTService) VerifyLinkToken(tokenString string) (*Claims, error) {
+	return VerifyLinkToken(tokenString)
 }
@@ -0,0 +1,57 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/example/golang-postgres-crud/models"
+)
+
+func loadSecret(t *testing.T) {
+	t.Helper()
+	saved := config.Current
+	t.Cleanup(func() {
+		config.Current = saved
+		auth.LoadKeys()
+	})
+	config.Current.Auth.KeysFile = ""
+	config.Current.Auth.SecretKey = "test-secret"
+	if err := auth.LoadKeys(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLinkTokens(t *testing.T) {
+	loadSecret(t)
+	user := models.User{Username: "ann", Role: models.RoleUser}
+	user.ID = 7
+
+	access, err := auth.CreateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := auth.CreateLinkToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := auth.VerifyLinkToken(link)
+	if err != nil || claims.UserID != 7 {
+		t.Fatalf("link token: claims %+v, error %v", claims, err)
+	}
+	if lifetime := time.Unix(claims.ExpiresAt, 0).Sub(time.Unix(claims.IssuedAt, 0)); lifetime > 10*time.Minute {
+		t.Errorf("link token lasts %v, want at most 10 minutes", lifetime)
+	}
+	if _, err := auth.VerifyToken(link); err == nil {
+		t.Error("link token accepted as an access token")
+	}
+
+	if claims, err := auth.VerifyToken(access); err != nil || claims.UserID != 7 {
+		t.Errorf("access token: claims %+v, error %v", claims, err)
+	}
+	if _, err := auth.VerifyLinkToken(access); err == nil {
+		t.Error("access token accepted as a link token")
+	}
+}
@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/golang-jwt/jwt"
+)
+
+// SigningKey is one entry of the key set. A key signs new tokens while it is
+// active (between NotBefore and NotAfter) and keeps verifying the tokens it
+// signed until they expire.
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	NotBefore time.Time
+	NotAfter  time.Time
+
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+	secret  []byte
+}
+
+// keyFile is the on-disk description of the key set pointed to by
+// JWT_KEYS_FILE. Key paths are resolved relative to the file itself.
+type keyFile struct {
+	Keys []struct {
+		ID             string    `json:"kid"`
+		Algorithm      string    `json:"alg"`
+		PrivateKeyFile string    `json:"privateKeyFile"`
+		PublicKeyFile  string    `json:"publicKeyFile"`
+		NotBefore      time.Time `json:"notBefore"`
+		NotAfter       time.Time `json:"notAfter"`
+	} `json:"keys"`
+}
+
+var (
+	keysMu sync.RWMutex
+	keys   []*SigningKey
+)
+
+// LoadKeys reads the signing keys from the configuration. Asymmetric keys
+// listed in JWT_KEYS_FILE take precedence; JWT_SECRET_KEY is only used as a
+// single HS256 key when no key file is configured.
+func LoadKeys() error {
+	var loaded []*SigningKey
+	var err error
+
+	switch {
+	case config.Current.Auth.KeysFile != "":
+		loaded, err = loadKeyFile(config.Current.Auth.KeysFile)
+		if err != nil {
+			return err
+		}
+	case config.Current.Auth.SecretKey != "":
+		loaded = []*SigningKey{{ID: "hs256", Method: jwt.SigningMethodHS256, secret: []byte(config.Current.Auth.SecretKey)}}
+	default:
+		return fmt.Errorf("no JWT signing key configured: set JWT_KEYS_FILE or JWT_SECRET_KEY")
+	}
+
+	if !hasActiveSigningKey(loaded, time.Now()) {
+		return fmt.Errorf("no JWT key with a private key is active")
+	}
+
+	keysMu.Lock()
+	keys = loaded
+	keysMu.Unlock()
+
+	return nil
+}
+
+func loadKeyFile(path string) ([]*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading JWT key file: %v", err)
+	}
+
+	var file keyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error while parsing JWT key file: %v", err)
+	}
+
+	dir := filepath.Dir(path)
+	seen := make(map[string]bool)
+	var loaded []*SigningKey
+
+	for _, k := range file.Keys {
+		if k.ID == "" {
+			return nil, fmt.Errorf("JWT key without kid in %s", path)
+		}
+		if seen[k.ID] {
+			return nil, fmt.Errorf("duplicate JWT key id %q", k.ID)
+		}
+		seen[k.ID] = true
+
+		key := &SigningKey{ID: k.ID, NotBefore: k.NotBefore, NotAfter: k.NotAfter}
+
+		switch k.Algorithm {
+		case "RS256":
+			key.Method = jwt.SigningMethodRS256
+		case "EdDSA":
+			key.Method = jwt.SigningMethodEdDSA
+		default:
+			return nil, fmt.Errorf("JWT key %q: unsupported algorithm %q", k.ID, k.Algorithm)
+		}
+
+		if k.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(resolvePath(dir, k.PrivateKeyFile))
+			if err != nil {
+				return nil, fmt.Errorf("JWT key %q: %v", k.ID, err)
+			}
+			if err := key.parsePrivateKey(pem); err != nil {
+				return nil, fmt.Errorf("JWT key %q: %v", k.ID, err)
+			}
+		} else if k.PublicKeyFile != "" {
+			pem, err := os.ReadFile(resolvePath(dir, k.PublicKeyFile))
+			if err != nil {
+				return nil, fmt.Errorf("JWT key %q: %v", k.ID, err)
+			}
+			if err := key.parsePublicKey(pem); err != nil {
+				return nil, fmt.Errorf("JWT key %q: %v", k.ID, err)
+			}
+		} else {
+			return nil, fmt.Errorf("JWT key %q: privateKeyFile or publicKeyFile is required", k.ID)
+		}
+
+		loaded = append(loaded, key)
+	}
+
+	return loaded, nil
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func (k *SigningKey) parsePrivateKey(pem []byte) error {
+	switch k.Method {
+	case jwt.SigningMethodRS256:
+		private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return err
+		}
+		k.private, k.public = private, &private.PublicKey
+	case jwt.SigningMethodEdDSA:
+		private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return err
+		}
+		k.private, k.public = private, private.(ed25519.PrivateKey).Public()
+	}
+	return nil
+}
+
+func (k *SigningKey) parsePublicKey(pem []byte) error {
+	var err error
+	switch k.Method {
+	case jwt.SigningMethodRS256:
+		k.public, err = jwt.ParseRSAPublicKeyFromPEM(pem)
+	case jwt.SigningMethodEdDSA:
+		k.public, err = jwt.ParseEdPublicKeyFromPEM(pem)
+	}
+	return err
+}
+
+func (k *SigningKey) canSign() bool {
+	return k.private != nil || k.secret != nil
+}
+
+func (k *SigningKey) signingMaterial() interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.private
+}
+
+func (k *SigningKey) verificationMaterial() interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.public
+}
+
+func (k *SigningKey) activeAt(t time.Time) bool {
+	return !t.Before(k.NotBefore) && (k.NotAfter.IsZero() || t.Before(k.NotAfter))
+}
+
+// verifiesAt reports whether tokens signed with the key are still accepted.
+// A retired key keeps verifying for one token lifetime after NotAfter.
+func (k *SigningKey) verifiesAt(t time.Time) bool {
+	return k.NotAfter.IsZero() || t.Before(k.NotAfter.Add(tokenLifetime))
+}
+
+func hasActiveSigningKey(set []*SigningKey, t time.Time) bool {
+	for _, k := range set {
+		if k.canSign() && k.activeAt(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// signingKey picks the active key with the most recent NotBefore, so a new key
+// takes over automatically once its scheduled start time has passed.
+func signingKey(t time.Time) *SigningKey {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	var current *SigningKey
+	for _, k := range keys {
+		if !k.canSign() || !k.activeAt(t) {
+			continue
+		}
+		if current == nil || k.NotBefore.After(current.NotBefore) {
+			current = k
+		}
+	}
+	return current
+}
+
+func verificationKey(kid string) *SigningKey {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	for _, k := range keys {
+		if k.ID == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+type JSONWebKey struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public halves of all asymmetric keys that can still
+// verify tokens, including keys scheduled to become active later, so other
+// services can pick them up before the rotation happens.
+func JWKS() JSONWebKeySet {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	now := time.Now()
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, k := range keys {
+		if k.public == nil || !k.verifiesAt(now) {
+			continue
+		}
+
+		jwk := JSONWebKey{KeyID: k.ID, Use: "sig", Algorithm: k.Method.Alg()}
+		switch public := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// ReloadKeysEvery re-reads the key configuration periodically so keys added
+// for a scheduled rotation are picked up without a restart. A failed reload
+// keeps the previous key set.
+func ReloadKeysEvery(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			if err := LoadKeys(); err != nil {
+				slog.Error("Failed to reload JWT keys", "error", err)
+			}
+		}
+	}()
+}
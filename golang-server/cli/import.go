@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/example/golang-postgres-crud/storage"
+)
+
+var importExtensions = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".bmp": true, ".tif": true, ".tiff": true, ".webp": true,
+}
+
+type importer struct {
+	readings repository.ReadingRepository
+	storage  storage.Storage
+	folder   *uint
+	queueOCR bool
+	dryRun   bool
+	stats    importStats
+}
+
+type importStats struct {
+	withTranscript int
+	queued         int
+	duplicates     int
+	noTranscript   int
+	failed         int
+}
+
+// RunImport walks a directory of images and stores each one like an upload.
+// An image with a transcript next to it (scan.png and scan.txt) gets that
+// text; any other image is queued for the OCR worker. Images whose content
+// was already imported are skipped, so an interrupted import can simply be
+// run again. The images are stored in store.
+func RunImport(repos repository.Repositories, store storage.Storage, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dir := flags.String("dir", "", "directory to import images from (required)")
+	queueOCR := flags.Bool("ocr", true, "queue OCR for images without a transcript; when false they are skipped")
+	folderID := flags.Uint("folder", 0, "ID of the folder to put imported readings in")
+	dryRun := flags.Bool("dry-run", false, "only report what would be imported")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s import -dir <directory> [flags]\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *dir == "" {
+		flags.Usage()
+		return errors.New("-dir is required")
+	}
+
+	var folder *uint
+	if *folderID != 0 {
+		_, err := repos.Folders.Get(context.Background(), *folderID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("folder %d not found", *folderID)
+		}
+		if err != nil {
+			return err
+		}
+		id := *folderID
+		folder = &id
+	}
+
+	// Collect the files first: importing into the directory being walked
+	// must not pick up the copies that are written along the way.
+	var images []string
+	err := filepath.WalkDir(*dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && importExtensions[strings.ToLower(filepath.Ext(path))] {
+			images = append(images, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	imp := importer{
+		readings: repos.Readings,
+		storage:  store,
+		folder:   folder,
+		queueOCR: *queueOCR,
+		dryRun:   *dryRun,
+	}
+	for i, path := range images {
+		result, err := imp.importImage(path)
+		if err != nil {
+			imp.stats.failed++
+			result = "failed: " + err.Error()
+		}
+		fmt.Printf("[%d/%d] %s: %s\n", i+1, len(images), path, result)
+	}
+
+	stats := imp.stats
+
+	fmt.Printf("Imported %d with transcript, queued %d for OCR, skipped %d already imported and %d without transcript, %d failed\n",
+		stats.withTranscript, stats.queued, stats.duplicates, stats.noTranscript, stats.failed)
+	if stats.failed > 0 {
+		return fmt.Errorf("%d of %d images failed to import", stats.failed, len(images))
+	}
+	return nil
+}
+
+func (imp *importer) importImage(path string) (string, error) {
+	ctx := context.Background()
+	stats := &imp.stats
+
+	imageBytes, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	contentHash := storage.ContentHash(imageBytes)
+
+	existing, err := imp.readings.FindByContentHash(ctx, contentHash)
+	if err == nil {
+		stats.duplicates++
+		return fmt.Sprintf("already imported as reading %d", existing.ID), nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return "", err
+	}
+
+	transcript, hasTranscript, err := readTranscript(path)
+	if err != nil {
+		return "", err
+	}
+	if !hasTranscript && !imp.queueOCR {
+		stats.noTranscript++
+		return "skipped, no transcript", nil
+	}
+
+	outcome := "imported with transcript"
+	if !hasTranscript {
+		outcome = "queued for OCR"
+	}
+	if imp.dryRun {
+		return "would be " + outcome, nil
+	}
+
+	filePath, err := imp.storage.Save(filepath.Base(path), bytes.NewReader(imageBytes))
+	if err != nil {
+		return "", err
+	}
+
+	textReading := models.TextReadings{
+		FileSize:    int64(len(imageBytes)),
+		FilePath:    filePath,
+		OcrText:     transcript,
+		OcrStatus:   models.OcrStatusDone,
+		ContentHash: contentHash,
+		FolderID:    imp.folder,
+	}
+	if !hasTranscript {
+		textReading.OcrStatus = models.OcrStatusPending
+	}
+
+	if err := imp.readings.Create(ctx, &textReading); err != nil {
+		if removeErr := imp.storage.Delete(filePath); removeErr != nil {
+			return "", fmt.Errorf("%v (and failed to remove %s: %v)", err, filePath, removeErr)
+		}
+		return "", err
+	}
+
+	if hasTranscript {
+		stats.withTranscript++
+	} else {
+		stats.queued++
+	}
+	return fmt.Sprintf("%s as reading %d", outcome, textReading.ID), nil
+}
+
+// readTranscript returns the contents of the .txt file next to the image, if
+// there is one.
+func readTranscript(imagePath string) (string, bool, error) {
+	base := strings.TrimSuffix(imagePath, filepath.Ext(imagePath))
+	for _, ext := range []string{".txt", ".TXT"} {
+		data, err := os.ReadFile(base + ext)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return string(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), true, nil
+	}
+	return "", false, nil
+}
@@ -0,0 +1,92 @@
+package cli_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/cli"
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRunImport(t *testing.T) {
+	ctx := context.Background()
+	f := fakes.New()
+	folder := models.Folder{Name: "Archive"}
+	if err := f.Folders.Create(ctx, &folder); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"letter.png": "letter image",
+		"letter.txt": "\xef\xbb\xbfDear Ann",
+		"scan.jpg":   "scan image",
+		"notes.md":   "not an image",
+	})
+
+	if err := cli.RunImport(f.Repositories(), f.Storage, []string{"-dir", dir, "-folder", "1"}); err != nil {
+		t.Fatal(err)
+	}
+	readings, _ := f.Readings.List(ctx, repository.ReadingFilter{FolderID: &folder.ID})
+	if len(readings) != 2 {
+		t.Fatalf("imported %d readings into the folder, want 2", len(readings))
+	}
+	byText := map[string]models.TextReadings{}
+	for _, reading := range readings {
+		byText[reading.OcrText] = reading
+	}
+	if letter, ok := byText["Dear Ann"]; !ok || letter.OcrStatus != models.OcrStatusDone {
+		t.Errorf("letter: %+v, want the transcript without its BOM and status done", byText)
+	}
+	if scan, ok := byText[""]; !ok || scan.OcrStatus != models.OcrStatusPending {
+		t.Errorf("scan: %+v, want it queued for OCR", byText)
+	}
+	if files := f.Storage.Files(); len(files) != 2 {
+		t.Errorf("stored %v, want the two images", files)
+	}
+
+	// Running again skips what was imported.
+	if err := cli.RunImport(f.Repositories(), f.Storage, []string{"-dir", dir}); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := f.Readings.Count(ctx, repository.ReadingFilter{}); count != 2 {
+		t.Errorf("%d readings after importing again, want 2", count)
+	}
+}
+
+func TestRunImportOptions(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"letter.png": "letter image", "letter.txt": "Dear Ann", "scan.png": "scan image"})
+
+	f := fakes.New()
+	if err := cli.RunImport(f.Repositories(), f.Storage, []string{"-dir", dir, "-folder", "9"}); err == nil {
+		t.Error("import into a missing folder succeeded")
+	}
+	if err := cli.RunImport(f.Repositories(), f.Storage, []string{"-dir", dir, "-dry-run"}); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := f.Readings.Count(ctx, repository.ReadingFilter{}); count != 0 || len(f.Storage.Files()) != 0 {
+		t.Errorf("dry run stored %d readings and files %v", count, f.Storage.Files())
+	}
+
+	if err := cli.RunImport(f.Repositories(), f.Storage, []string{"-dir", dir, "-ocr=false"}); err != nil {
+		t.Fatal(err)
+	}
+	readings, _ := f.Readings.List(ctx, repository.ReadingFilter{})
+	if len(readings) != 1 || readings[0].OcrText != "Dear Ann" {
+		t.Errorf("imported %+v without OCR, want only the letter", readings)
+	}
+}
@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/example/golang-postgres-crud/db"
+)
+
+// RunMigrate applies, rolls back or lists the embedded schema migrations:
+//
+//	migrate up [-to version]
+//	migrate down [-steps n]
+//	migrate status
+func RunMigrate(args []string) error {
+	usage := fmt.Sprintf("usage: %s migrate up [-to version] | down [-steps n] | status", filepath.Base(os.Args[0]))
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	switch args[0] {
+	case "up":
+		flags := flag.NewFlagSet("migrate up", flag.ContinueOnError)
+		to := flags.Int("to", 0, "apply migrations up to this version (default: all)")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		applied, err := db.MigrateUp(*to)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Database is up to date")
+		}
+		for _, m := range applied {
+			fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+		}
+		return nil
+
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		steps := flags.Int("steps", 1, "number of migrations to roll back")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *steps < 1 {
+			return errors.New("-steps must be at least 1")
+		}
+		reverted, err := db.MigrateDown(*steps)
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No migrations to roll back")
+		}
+		for _, m := range reverted {
+			fmt.Printf("Rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		return nil
+
+	case "status":
+		statuses, err := db.MigrationStatuses()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			if status.Unknown {
+				state += " (unknown to this binary)"
+			}
+			fmt.Printf("%04d_%-30s %s\n", status.Version, status.Name, state)
+		}
+		return db.CheckSchemaVersion()
+
+	default:
+		return errors.New(usage)
+	}
+}
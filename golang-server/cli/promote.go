@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+)
+
+// RunPromote grants the admin role to an existing account, or takes it away
+// with -revoke. Registration only ever creates plain users, so this is how
+// the first administrator is made.
+func RunPromote(repos repository.Repositories, args []string) error {
+	flags := flag.NewFlagSet("promote", flag.ContinueOnError)
+	username := flags.String("user", "", "username of the account to change (required)")
+	revoke := flags.Bool("revoke", false, "make the account a plain user again")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s promote -user <username> [-revoke]\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *username == "" {
+		flags.Usage()
+		return errors.New("-user is required")
+	}
+
+	role := models.RoleAdmin
+	if *revoke {
+		role = models.RoleUser
+	}
+	return setRole(context.Background(), repos.Users, *username, role)
+}
+
+func setRole(ctx context.Context, users repository.UserRepository, username, role string) error {
+	user, err := users.GetByUsername(ctx, username)
+	if errors.Is(err, repository.ErrNotFound) {
+		return fmt.Errorf("no user named %q", username)
+	}
+	if err != nil {
+		return err
+	}
+	if user.Role == role {
+		fmt.Printf("%s already has the %s role\n", username, role)
+		return nil
+	}
+
+	user.Role = role
+	if err := users.Update(ctx, &user); err != nil {
+		return err
+	}
+	// Admin routes check the stored role, so this applies to tokens already
+	// issued too.
+	fmt.Printf("%s now has the %s role\n", username, role)
+	return nil
+}
@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/example/golang-postgres-crud/storage"
+)
+
+type reconciler struct {
+	readings   repository.ReadingRepository
+	storage    storage.Storage
+	repair     bool
+	deleteRows bool
+	cutoff     time.Time
+	stats      reconcileStats
+}
+
+type reconcileStats struct {
+	orphanFiles   int
+	trashedFiles  int
+	restorable    int
+	missingImages int
+	repaired      int
+	failed        int
+}
+
+// RunReconcile compares the stored images with the text readings and reports
+// images that no reading refers to, leftovers in the trash, and readings
+// whose image is gone. With -repair it removes the stray files and moves
+// trashed images of surviving readings back; readings whose image is lost for
+// good are only deleted when -delete-rows is given too.
+func RunReconcile(repos repository.Repositories, store storage.Storage, args []string) error {
+	flags := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "fix the problems found instead of only reporting them")
+	deleteRows := flags.Bool("delete-rows", false, "with -repair, also delete readings whose image is lost")
+	minAge := flags.Duration("min-age", time.Hour, "ignore files changed more recently than this, so uploads and deletes in progress are left alone")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s reconcile [flags]\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *deleteRows && !*repair {
+		return errors.New("-delete-rows requires -repair")
+	}
+
+	r := reconciler{
+		readings:   repos.Readings,
+		storage:    store,
+		repair:     *repair,
+		deleteRows: *deleteRows,
+		cutoff:     time.Now().Add(-*minAge),
+	}
+
+	// Rows are read before files: an upload saves its file before inserting
+	// its row, so a file newer than the snapshot may look orphaned, and
+	// -min-age keeps such files out of the comparison.
+	rows, err := repos.Readings.ListFiles(context.Background())
+	if err != nil {
+		return err
+	}
+	files, err := r.storage.List()
+	if err != nil {
+		return err
+	}
+	trash, err := r.storage.ListTrash()
+	if err != nil {
+		return err
+	}
+
+	if err := r.reconcile(context.Background(), rows, files, trash); err != nil {
+		return err
+	}
+
+	stats := r.stats
+	fmt.Printf("Found %d orphaned files, %d trashed files of deleted readings, %d readings with a trashed image and %d readings with a lost image\n",
+		stats.orphanFiles, stats.trashedFiles, stats.restorable, stats.missingImages)
+	if r.repair {
+		fmt.Printf("Repaired %d, %d failed\n", stats.repaired, stats.failed)
+	}
+	if stats.failed > 0 {
+		return fmt.Errorf("%d repairs failed", stats.failed)
+	}
+	return nil
+}
+
+func (r *reconciler) reconcile(ctx context.Context, rows []repository.ReadingFiles, files, trash []storage.Object) error {
+	byPath := make(map[string]repository.ReadingFiles, len(rows))
+	// Processed images count as referenced, but a missing one is no
+	// problem: the original is what matters.
+	processed := make(map[string]bool)
+	for _, row := range rows {
+		byPath[filepath.Clean(row.FilePath)] = row
+		if row.ProcessedPath != "" {
+			processed[filepath.Clean(row.ProcessedPath)] = true
+		}
+	}
+	stored := make(map[string]bool, len(files))
+	for _, file := range files {
+		stored[filepath.Clean(file.Path)] = true
+	}
+	trashed := make(map[string]storage.Object, len(trash))
+	for _, file := range trash {
+		trashed[filepath.Clean(file.Path)] = file
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	for _, file := range files {
+		if _, ok := byPath[filepath.Clean(file.Path)]; ok || processed[filepath.Clean(file.Path)] || file.ModTime.After(r.cutoff) {
+			continue
+		}
+		r.stats.orphanFiles++
+		r.fix(fmt.Sprintf("%s: no reading refers to this file", file.Path), "removed", func() error {
+			return r.storage.Delete(file.Path)
+		})
+	}
+
+	sort.Slice(trash, func(i, j int) bool { return trash[i].Path < trash[j].Path })
+	for _, file := range trash {
+		if _, ok := byPath[filepath.Clean(file.Path)]; ok || file.ModTime.After(r.cutoff) {
+			continue
+		}
+		r.stats.trashedFiles++
+		r.fix(fmt.Sprintf("%s: left in the trash by a deleted reading", file.Path), "purged", func() error {
+			return r.storage.Purge(file.Path)
+		})
+	}
+
+	for _, row := range rows {
+		path := filepath.Clean(row.FilePath)
+		if stored[path] {
+			continue
+		}
+		if file, ok := trashed[path]; ok {
+			if file.ModTime.After(r.cutoff) {
+				continue
+			}
+			r.stats.restorable++
+			r.fix(fmt.Sprintf("reading %d: image %s is in the trash", row.ID, row.FilePath), "restored", func() error {
+				return r.storage.Restore(row.FilePath)
+			})
+			continue
+		}
+
+		// Readings may point outside the image directory, e.g. after it was
+		// moved, so only an image that can't be opened at all is lost.
+		image, err := r.storage.Open(row.FilePath)
+		if err == nil {
+			image.Close()
+			continue
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		r.stats.missingImages++
+		if !r.deleteRows {
+			fmt.Printf("reading %d: image %s is lost\n", row.ID, row.FilePath)
+			continue
+		}
+		r.fix(fmt.Sprintf("reading %d: image %s is lost", row.ID, row.FilePath), "reading deleted", func() error {
+			err := r.readings.Delete(ctx, row.ID)
+			if errors.Is(err, repository.ErrNotFound) {
+				return nil
+			}
+			return err
+		})
+	}
+	return nil
+}
+
+// fix reports a problem and, when repairing, applies the repair.
+func (r *reconciler) fix(problem, repaired string, repair func() error) {
+	if !r.repair {
+		fmt.Println(problem)
+		return
+	}
+	if err := repair(); err != nil {
+		r.stats.failed++
+		fmt.Printf("%s: repair failed: %v\n", problem, err)
+		return
+	}
+	r.stats.repaired++
+	fmt.Printf("%s: %s\n", problem, repaired)
+}
@@ -1,9 +1,299 @@
+// Package config holds the server configuration. Load builds it from
+// defaults, an optional YAML or TOML file, environment variables and
+// command-line flags, in increasing order of precedence, and validates it.
+//
+// Every setting has a key, used in the file and as the flag name (http.addr
+// is "addr" in the file's "http" section and the -http.addr flag), and an
+// environment variable.
 package config
 
-import "os"
+import (
+	"path/filepath"
+	"strings"
+	"time"
 
-var DB_URL string
+	"github.com/example/golang-postgres-crud/preprocess"
+)
 
-func LoadConfig() {
-	DB_URL = os.Getenv("DATABASE_URL")
+// Current is the configuration the process runs with. It is set by Load.
+var Current = Defaults()
+
+type Config struct {
+	HTTP       HTTP       `key:"http"`
+	Limits     Limits     `key:"limits"`
+	RateLimit  RateLimit  `key:"rateLimit"`
+	Quota      Quota      `key:"quota"`
+	DB         DB         `key:"db"`
+	Auth       Auth       `key:"auth"`
+	OIDC       OIDC       `key:"oidc"`
+	OCR        OCR        `key:"ocr"`
+	OCRCache   OCRCache   `key:"ocrCache"`
+	Preprocess Preprocess `key:"preprocess"`
+	Storage    Storage    `key:"storage"`
+	Log        Log        `key:"log"`
+	Tracing    Tracing    `key:"tracing"`
+}
+
+type HTTP struct {
+	// Addr falls back to ":$PORT", the variable gin's Run used to listen on.
+	Addr              string        `key:"addr" env:"HTTP_ADDR" help:"address to listen on"`
+	ReadHeaderTimeout time.Duration `key:"readHeaderTimeout" env:"HTTP_READ_HEADER_TIMEOUT" help:"time allowed to read request headers"`
+	// Reading a request includes receiving the upload, and writing a
+	// response includes streaming an export, so both allow for large files.
+	ReadTimeout     time.Duration `key:"readTimeout" env:"HTTP_READ_TIMEOUT" help:"time allowed to read a whole request"`
+	WriteTimeout    time.Duration `key:"writeTimeout" env:"HTTP_WRITE_TIMEOUT" help:"time allowed to write a response"`
+	IdleTimeout     time.Duration `key:"idleTimeout" env:"HTTP_IDLE_TIMEOUT" help:"how long idle keep-alive connections stay open"`
+	ShutdownTimeout time.Duration `key:"shutdownTimeout" env:"SHUTDOWN_TIMEOUT" help:"how long to drain requests on shutdown"`
+	// TrustedProxies decides whose X-Forwarded-For is believed when
+	// logging, auditing and rate limiting by client IP.
+	TrustedProxies string `key:"trustedProxies" env:"HTTP_TRUSTED_PROXIES" help:"comma-separated IPs or CIDRs of reverse proxies to take the client IP from, empty for none"`
+}
+
+// TrustedProxyList splits TrustedProxies into its entries.
+func (h HTTP) TrustedProxyList() []string {
+	return splitList(h.TrustedProxies)
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+type Limits struct {
+	MaxHeaderBytes int   `key:"maxHeaderBytes" env:"HTTP_MAX_HEADER_BYTES" help:"largest accepted request header"`
+	MaxBodyBytes   int64 `key:"maxBodyBytes" env:"HTTP_MAX_BODY_BYTES" help:"largest accepted request body or WebSocket message, 0 for no limit"`
+	// Uploads beyond MultipartMemory are spooled to temporary files, so
+	// large images don't take up memory.
+	MultipartMemory int64 `key:"multipartMemory" env:"HTTP_MULTIPART_MEMORY" help:"bytes of a multipart upload kept in memory"`
+}
+
+// RateLimit sets the token buckets requests draw from. Each allows PerMinute
+// requests a minute on average and Burst at once; a PerMinute of 0 turns the
+// limit off.
+type RateLimit struct {
+	// Store is "memory", which limits each replica on its own, or
+	// "database", which shares the limits between replicas.
+	Store           string `key:"store" env:"RATE_LIMIT_STORE" help:"where limiter state is kept, memory or database"`
+	IPPerMinute     int    `key:"ipPerMinute" env:"RATE_LIMIT_IP_PER_MINUTE" help:"requests per minute per client IP, 0 for no limit"`
+	IPBurst         int    `key:"ipBurst" env:"RATE_LIMIT_IP_BURST" help:"requests a client IP may make at once"`
+	UserPerMinute   int    `key:"userPerMinute" env:"RATE_LIMIT_USER_PER_MINUTE" help:"API requests per minute per user token, 0 for no limit"`
+	UserBurst       int    `key:"userBurst" env:"RATE_LIMIT_USER_BURST" help:"API requests a user token may make at once"`
+	APIKeyPerMinute int    `key:"apiKeyPerMinute" env:"RATE_LIMIT_API_KEY_PER_MINUTE" help:"API requests per minute per API key, 0 for no limit"`
+	APIKeyBurst     int    `key:"apiKeyBurst" env:"RATE_LIMIT_API_KEY_BURST" help:"API requests an API key may make at once"`
+	OCRPerMinute    int    `key:"ocrPerMinute" env:"RATE_LIMIT_OCR_PER_MINUTE" help:"OCR calls per minute per user, 0 for no limit"`
+	OCRBurst        int    `key:"ocrBurst" env:"RATE_LIMIT_OCR_BURST" help:"OCR calls a user may make at once"`
+}
+
+// Quota caps what each user may use per UTC day and month. 0 means no cap.
+type Quota struct {
+	OCRDaily           int64 `key:"ocrDaily" env:"QUOTA_OCR_DAILY" help:"OCR calls per user per day, 0 for no limit"`
+	OCRMonthly         int64 `key:"ocrMonthly" env:"QUOTA_OCR_MONTHLY" help:"OCR calls per user per month, 0 for no limit"`
+	UploadBytesDaily   int64 `key:"uploadBytesDaily" env:"QUOTA_UPLOAD_BYTES_DAILY" help:"bytes of images a user may store per day, 0 for no limit"`
+	UploadBytesMonthly int64 `key:"uploadBytesMonthly" env:"QUOTA_UPLOAD_BYTES_MONTHLY" help:"bytes of images a user may store per month, 0 for no limit"`
+}
+
+type DB struct {
+	// Driver is "postgres" or "sqlite". With SQLite, URL is the database
+	// file, so the server runs locally without Postgres.
+	Driver          string        `key:"driver" env:"DB_DRIVER" help:"database driver, postgres or sqlite"`
+	URL             string        `key:"url" env:"DATABASE_URL" secret:"true" help:"Postgres connection string or SQLite file"`
+	AutoMigrate     bool          `key:"autoMigrate" env:"DB_AUTO_MIGRATE" help:"apply pending migrations at startup"`
+	MaxOpenConns    int           `key:"maxOpenConns" env:"DB_MAX_OPEN_CONNS" help:"maximum open connections, 0 for no limit"`
+	MaxIdleConns    int           `key:"maxIdleConns" env:"DB_MAX_IDLE_CONNS" help:"maximum idle connections kept in the pool"`
+	ConnMaxLifetime time.Duration `key:"connMaxLifetime" env:"DB_CONN_MAX_LIFETIME" help:"how long a connection is reused, 0 for ever"`
+	ConnMaxIdleTime time.Duration `key:"connMaxIdleTime" env:"DB_CONN_MAX_IDLE_TIME" help:"how long a connection may sit idle, 0 for ever"`
+}
+
+type Auth struct {
+	KeysFile string `key:"keysFile" env:"JWT_KEYS_FILE" help:"JSON file listing the JWT signing keys"`
+	// SecretKey also reads JWT-SECRET-KEY, the name older deployments used,
+	// which most shells cannot export.
+	SecretKey          string        `key:"secretKey" env:"JWT_SECRET_KEY,JWT-SECRET-KEY" secret:"true" help:"HS256 secret, used when no keys file is set"`
+	KeysReloadInterval time.Duration `key:"keysReloadInterval" env:"JWT_KEYS_RELOAD_INTERVAL" help:"how often to reload the keys file, 0 to never"`
+	Issuer             string        `key:"issuer" env:"JWT_ISSUER" help:"issuer of the tokens this server signs"`
+	Audience           string        `key:"audience" env:"JWT_AUDIENCE" help:"audience of the tokens this server signs"`
+}
+
+type OIDC struct {
+	IssuerURL     string `key:"issuerUrl" env:"OIDC_ISSUER_URL" help:"OpenID Connect provider, empty to disable OIDC login"`
+	ClientID      string `key:"clientId" env:"OIDC_CLIENT_ID" help:"OIDC client ID"`
+	ClientSecret  string `key:"clientSecret" env:"OIDC_CLIENT_SECRET" secret:"true" help:"OIDC client secret"`
+	RedirectURL   string `key:"redirectUrl" env:"OIDC_REDIRECT_URL" help:"URL of /auth/oidc/callback as the provider sees it"`
+	Scopes        string `key:"scopes" env:"OIDC_SCOPES" help:"extra scopes to request, space separated"`
+	AutoProvision bool   `key:"autoProvision" env:"OIDC_AUTO_PROVISION" help:"create accounts for unknown OIDC users"`
+}
+
+type OCR struct {
+	// Engine is used by requests that don't pick one and by the worker.
+	// Engines lists more that requests may pick; Engine is always enabled.
+	Engine             string `key:"engine" env:"OCR_ENGINE" help:"default OCR engine: easyocr, tesseract or fake"`
+	Engines            string `key:"engines" env:"OCR_ENGINES" help:"comma-separated engines requests may choose besides the default"`
+	TesseractPath      string `key:"tesseractPath" env:"OCR_TESSERACT_PATH" help:"tesseract executable of the tesseract engine"`
+	TesseractLanguages string `key:"tesseractLanguages" env:"OCR_TESSERACT_LANGUAGES" help:"languages of the tesseract engine, joined by +"`
+
+	// Address may list several OCR servers, or be a dns:/// target naming
+	// all of them; calls are balanced over those that pass health checks.
+	Address  string `key:"address" env:"OCR_ADDRESS" help:"host:port of the OCR gRPC server, a comma-separated list of them, or dns:///host:port to use every address of a name"`
+	Balancer string `key:"balancer" env:"OCR_BALANCER" help:"how calls are spread over OCR servers: round_robin or least_request"`
+	// Calls are retried on another server when one is unavailable or busy.
+	// Retries count against Timeout.
+	Retries      int           `key:"retries" env:"OCR_RETRIES" help:"times an OCR call is retried on an unavailable or busy server, at most 4"`
+	RetryBackoff time.Duration `key:"retryBackoff" env:"OCR_RETRY_BACKOFF" help:"wait before the first retry, growing with each one"`
+	// After BreakerFailures calls in a row fail with the servers down or too
+	// slow, calls fail at once for BreakerCooldown before one is let through
+	// to try again.
+	BreakerFailures int           `key:"breakerFailures" env:"OCR_BREAKER_FAILURES" help:"failed OCR calls in a row that open the circuit breaker, 0 to disable it"`
+	BreakerCooldown time.Duration `key:"breakerCooldown" env:"OCR_BREAKER_COOLDOWN" help:"how long the open circuit breaker fails calls before trying again"`
+
+	Timeout        time.Duration `key:"timeout" env:"OCR_TIMEOUT" help:"time allowed for one OCR call"`
+	WorkerInterval time.Duration `key:"workerInterval" env:"OCR_WORKER_INTERVAL" help:"how often the worker polls for queued readings, 0 to disable it"`
+	MaxAttempts    int           `key:"maxAttempts" env:"OCR_MAX_ATTEMPTS" help:"OCR attempts per queued reading before it is marked failed"`
+}
+
+// AddressList splits Address into the servers it lists. A dns:/// target is
+// returned whole.
+func (o OCR) AddressList() []string {
+	if strings.HasPrefix(o.Address, "dns:") {
+		return []string{o.Address}
+	}
+	return splitList(o.Address)
+}
+
+// EngineList splits Engines into its entries.
+func (o OCR) EngineList() []string {
+	return splitList(o.Engines)
+}
+
+// OCRCache keeps OCR results by image hash, so images sent again are not
+// recognized again.
+type OCRCache struct {
+	Enabled  bool          `key:"enabled" env:"OCR_CACHE_ENABLED" help:"cache OCR results by image hash"`
+	MaxBytes int64         `key:"maxBytes" env:"OCR_CACHE_MAX_BYTES" help:"memory the cached results may take up"`
+	TTL      time.Duration `key:"ttl" env:"OCR_CACHE_TTL" help:"how long a result stays cached"`
+	// Persist also keeps results in the database, where they survive
+	// restarts and are shared by replicas.
+	Persist bool `key:"persist" env:"OCR_CACHE_PERSIST" help:"also keep cached results in the database"`
+}
+
+// Preprocess prepares images in Go before they are sent to OCR. Requests
+// choose steps with the preprocess parameter; Steps applies to those that
+// don't and to the worker.
+type Preprocess struct {
+	Steps        string `key:"steps" env:"PREPROCESS_STEPS" help:"comma-separated preprocessing steps: orient, downscale, grayscale, deskew, binarize, or none"`
+	MaxDimension int    `key:"maxDimension" env:"PREPROCESS_MAX_DIMENSION" help:"longest side, in pixels, of downscaled images"`
+	MaxPixels    int64  `key:"maxPixels" env:"PREPROCESS_MAX_PIXELS" help:"largest image, in pixels, that is preprocessed or embedded in PDF exports"`
+}
+
+// StepList parses Steps, which Validate has checked.
+func (p Preprocess) StepList() []preprocess.Step {
+	steps, _ := preprocess.ParseSteps(p.Steps)
+	return steps
+}
+
+// Options returns the limits preprocessing runs with.
+func (p Preprocess) Options() preprocess.Options {
+	return preprocess.Options{MaxDimension: p.MaxDimension, MaxPixels: p.MaxPixels}
+}
+
+type Storage struct {
+	ImageDir string `key:"imageDir" env:"STORAGE_IMAGE_DIR" help:"directory uploaded and imported images are kept in"`
+}
+
+type Log struct {
+	Level  string `key:"level" env:"LOG_LEVEL" help:"debug, info, warn or error"`
+	Format string `key:"format" env:"LOG_FORMAT" help:"json or text"`
+}
+
+type Tracing struct {
+	Exporter    string  `key:"exporter" env:"TRACING_EXPORTER" help:"none, stdout, file or otlp"`
+	File        string  `key:"file" env:"TRACING_FILE" help:"file the file exporter writes to"`
+	ServiceName string  `key:"serviceName" env:"TRACING_SERVICE_NAME" help:"service name reported in traces"`
+	SampleRatio float64 `key:"sampleRatio" env:"TRACING_SAMPLE_RATIO" help:"fraction of traces to sample, 0 to 1"`
+}
+
+// Defaults returns the configuration used for settings no source sets.
+func Defaults() Config {
+	return Config{
+		HTTP: HTTP{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       time.Minute,
+			WriteTimeout:      5 * time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Limits: Limits{
+			MaxHeaderBytes:  1 << 20,
+			MaxBodyBytes:    32 << 20,
+			MultipartMemory: 4 << 20,
+		},
+		RateLimit: RateLimit{
+			Store:           "memory",
+			IPPerMinute:     600,
+			IPBurst:         100,
+			UserPerMinute:   300,
+			UserBurst:       100,
+			APIKeyPerMinute: 300,
+			APIKeyBurst:     100,
+			OCRPerMinute:    20,
+			OCRBurst:        5,
+		},
+		DB: DB{
+			Driver:          "postgres",
+			AutoMigrate:     true,
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Auth: Auth{
+			Issuer:   "golang-postgres-crud",
+			Audience: "golang-postgres-crud",
+		},
+		OIDC: OIDC{
+			AutoProvision: true,
+		},
+		OCR: OCR{
+			Engine:             "easyocr",
+			TesseractPath:      "tesseract",
+			TesseractLanguages: "eng",
+			Address:            "python-server:50051",
+			Balancer:           "round_robin",
+			Retries:            2,
+			RetryBackoff:       200 * time.Millisecond,
+			BreakerFailures:    5,
+			BreakerCooldown:    30 * time.Second,
+			Timeout:            30 * time.Second,
+			WorkerInterval:     10 * time.Second,
+			MaxAttempts:        3,
+		},
+		OCRCache: OCRCache{
+			Enabled:  true,
+			MaxBytes: 64 << 20,
+			TTL:      24 * time.Hour,
+		},
+		Preprocess: Preprocess{
+			Steps:        "none",
+			MaxDimension: 3000,
+			MaxPixels:    50_000_000,
+		},
+		Storage: Storage{
+			ImageDir: filepath.Join("static", "images"),
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			File:        "traces.jsonl",
+			ServiceName: "golang-postgres-crud",
+			SampleRatio: 1,
+		},
+	}
 }
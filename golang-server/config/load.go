@@ -0,0 +1,282 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// sources records where each setting of Current came from, by key. Settings
+// missing from it have their default value.
+var sources = map[string]string{}
+
+// setting is one leaf of Config.
+type setting struct {
+	key    string
+	env    []string
+	help   string
+	secret bool
+	value  reflect.Value
+}
+
+// settings lists the settings of c in declaration order. Their values point
+// into c.
+func settings(c *Config) []setting {
+	var all []setting
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		sectionKey := sections.Type().Field(i).Tag.Get("key")
+		section := sections.Field(i)
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			all = append(all, setting{
+				key:    sectionKey + "." + field.Tag.Get("key"),
+				env:    strings.Split(field.Tag.Get("env"), ","),
+				help:   field.Tag.Get("help"),
+				secret: field.Tag.Get("secret") == "true",
+				value:  section.Field(j),
+			})
+		}
+	}
+	return all
+}
+
+// Load builds the configuration and makes it Current. Each source overrides
+// the ones before it:
+//
+//  1. the defaults,
+//  2. the file named by -config or CONFIG_FILE (.yaml, .yml or .toml),
+//  3. environment variables,
+//  4. flags.
+//
+// args are the command-line arguments without the program name. Flags end at
+// the first argument that isn't one; Load returns that argument and the rest.
+// Every invalid value is reported, not just the first. With -h, Load prints
+// the usage and returns flag.ErrHelp.
+func Load(args []string) ([]string, error) {
+	cfg := Defaults()
+	all := settings(&cfg)
+	loaded := make(map[string]string)
+
+	type flagValue struct {
+		setting setting
+		raw     string
+	}
+	var flagValues []flagValue
+
+	flags := flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file (env CONFIG_FILE)")
+	for _, s := range all {
+		record := func(raw string) error {
+			flagValues = append(flagValues, flagValue{s, raw})
+			return nil
+		}
+		usage := fmt.Sprintf("%s (env %s)", s.help, s.env[0])
+		if s.value.Kind() == reflect.Bool {
+			flags.BoolFunc(s.key, usage, record)
+		} else {
+			flags.Func(s.key, usage, record)
+		}
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s [flags] [serve | import | reconcile | migrate | promote | config] [command flags]\n\nFlags override environment variables, which override the config file.\n\n", flags.Name())
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, all, loaded); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range all {
+		for _, name := range s.env {
+			raw := os.Getenv(name)
+			if raw == "" {
+				continue
+			}
+			if err := set(s.value, raw); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+			loaded[s.key] = "env " + name
+			break
+		}
+	}
+	if port := os.Getenv("PORT"); port != "" && os.Getenv("HTTP_ADDR") == "" {
+		cfg.HTTP.Addr = ":" + port
+		loaded["http.addr"] = "env PORT"
+	}
+
+	for _, f := range flagValues {
+		if err := set(f.setting.value, f.raw); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", f.setting.key, err))
+		}
+		loaded[f.setting.key] = "flag"
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if cfg.DB.Driver == "sqlite" && cfg.DB.URL == "" {
+		cfg.DB.URL = "local.db"
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	Current = cfg
+	sources = loaded
+	return flags.Args(), nil
+}
+
+// loadFile applies the settings in a YAML or TOML file. The file has one
+// table per section, keyed like the flags: addr under http for -http.addr.
+func loadFile(path string, all []setting, loaded map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	var doc map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return fmt.Errorf("config file %s: unknown format, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	byKey := make(map[string]setting, len(all))
+	for _, s := range all {
+		byKey[s.key] = s
+	}
+
+	var errs []error
+	for _, section := range sortedKeys(doc) {
+		values, ok := doc[section].(map[string]any)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: expected a section of settings", section))
+			continue
+		}
+		for _, name := range sortedKeys(values) {
+			key := section + "." + name
+			s, ok := byKey[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown setting", key))
+				continue
+			}
+			raw, err := scalar(values[name])
+			if err == nil {
+				err = set(s.value, raw)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				continue
+			}
+			loaded[key] = "file"
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("config file %s:\n%w", path, err)
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scalar turns a value decoded from a config file into the text form
+// environment variables and flags use, so all sources parse alike.
+func scalar(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("expected a single value, got %T", value)
+	}
+}
+
+func set(v reflect.Value, raw string) error {
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, expected a value like 30s or 5m", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q, expected true or false", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(f)
+	default:
+		panic("config: unsupported setting type " + v.Type().String())
+	}
+	return nil
+}
+
+// Print writes every setting of Current with its value and where it came
+// from. Secrets are only shown as set or not.
+func Print(w io.Writer) error {
+	cfg := Current
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range settings(&cfg) {
+		value := fmt.Sprint(s.value.Interface())
+		if s.secret && value != "" {
+			value = "(set)"
+		}
+		source := sources[s.key]
+		if source == "" {
+			source = "default"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.key, value, source)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	secret      = "0123456789abcdef0123456789abcdef"
+	otherSecret = "fedcba9876543210fedcba9876543210"
+)
+
+// isolate clears every variable Load reads and restores Current and sources
+// when the test ends.
+func isolate(t *testing.T) {
+	t.Helper()
+	current, loaded := Current, sources
+	t.Cleanup(func() { Current, sources = current, loaded })
+
+	cfg := Defaults()
+	for _, s := range settings(&cfg) {
+		for _, name := range s.env {
+			t.Setenv(name, "")
+		}
+	}
+	t.Setenv("PORT", "")
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_DRIVER", "sqlite")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	const yamlFile = "http:\n  addr: \":1000\"\nlog:\n  level: warn\n"
+	const tomlFile = "[http]\naddr = \":1000\"\n\n[log]\nlevel = \"warn\"\n"
+
+	tests := []struct {
+		name       string
+		file       string
+		content    string
+		env        map[string]string
+		args       []string
+		wantAddr   string
+		wantSource string
+	}{
+		{name: "defaults", wantAddr: ":8080", wantSource: "default"},
+		{name: "yaml file", file: "app.yaml", content: yamlFile, wantAddr: ":1000", wantSource: "file"},
+		{name: "toml file", file: "app.toml", content: tomlFile, wantAddr: ":1000", wantSource: "file"},
+		{
+			name: "env over file", file: "app.yml", content: yamlFile,
+			env:      map[string]string{"HTTP_ADDR": ":2000"},
+			wantAddr: ":2000", wantSource: "env HTTP_ADDR",
+		},
+		{
+			name: "flag over env", file: "app.yaml", content: yamlFile,
+			env:      map[string]string{"HTTP_ADDR": ":2000"},
+			args:     []string{"-http.addr=:3000"},
+			wantAddr: ":3000", wantSource: "flag",
+		},
+		{
+			name: "file from CONFIG_FILE", file: "app.yaml", content: yamlFile,
+			env:      map[string]string{"CONFIG_FILE": "{file}"},
+			wantAddr: ":1000", wantSource: "file",
+		},
+		{
+			name:     "PORT",
+			env:      map[string]string{"PORT": "9000"},
+			wantAddr: ":9000", wantSource: "env PORT",
+		},
+		{
+			name:     "HTTP_ADDR over PORT",
+			env:      map[string]string{"PORT": "9000", "HTTP_ADDR": ":2000"},
+			wantAddr: ":2000", wantSource: "env HTTP_ADDR",
+		},
+		{
+			name: "PORT over file", file: "app.yaml", content: yamlFile,
+			env:      map[string]string{"PORT": "9000"},
+			wantAddr: ":9000", wantSource: "env PORT",
+		},
+		{
+			name:     "flag over PORT",
+			env:      map[string]string{"PORT": "9000"},
+			args:     []string{"-http.addr", ":3000"},
+			wantAddr: ":3000", wantSource: "flag",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			args := tt.args
+			var path string
+			if tt.file != "" {
+				path = writeFile(t, tt.file, tt.content)
+				if tt.env["CONFIG_FILE"] == "" {
+					args = append([]string{"-config", path}, args...)
+				}
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, strings.ReplaceAll(value, "{file}", path))
+			}
+
+			rest, err := Load(append(args, "serve", "-http.addr=:1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rest) != 2 || rest[0] != "serve" {
+				t.Errorf("Load returned %q, want the command and its flags", rest)
+			}
+			if Current.HTTP.Addr != tt.wantAddr {
+				t.Errorf("http.addr is %q, want %q", Current.HTTP.Addr, tt.wantAddr)
+			}
+			source := sources["http.addr"]
+			if source == "" {
+				source = "default"
+			}
+			if source != tt.wantSource {
+				t.Errorf("http.addr came from %q, want %q", source, tt.wantSource)
+			}
+			if tt.file != "" && (Current.Log.Level != "warn" || sources["log.level"] != "file") {
+				t.Errorf("log.level is %q from %q, want warn from the file", Current.Log.Level, sources["log.level"])
+			}
+		})
+	}
+}
+
+func TestLoadSecretKeyFallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		want       string
+		wantSource string
+	}{
+		{name: "JWT_SECRET_KEY", env: map[string]string{"JWT_SECRET_KEY": secret}, want: secret, wantSource: "env JWT_SECRET_KEY"},
+		{name: "JWT-SECRET-KEY", env: map[string]string{"JWT-SECRET-KEY": secret}, want: secret, wantSource: "env JWT-SECRET-KEY"},
+		{
+			name:       "JWT_SECRET_KEY first",
+			env:        map[string]string{"JWT_SECRET_KEY": secret, "JWT-SECRET-KEY": otherSecret},
+			want:       secret,
+			wantSource: "env JWT_SECRET_KEY",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if _, err := Load(nil); err != nil {
+				t.Fatal(err)
+			}
+			if Current.Auth.SecretKey != tt.want || sources["auth.secretKey"] != tt.wantSource {
+				t.Errorf("auth.secretKey is %q from %q, want %q from %q", Current.Auth.SecretKey, sources["auth.secretKey"], tt.want, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		env     map[string]string
+		args    []string
+		// want are the parts of the error, all of which must be reported.
+		want []string
+	}{
+		{
+			name: "invalid values",
+			env:  map[string]string{"DB_MAX_OPEN_CONNS": "many", "OCR_TIMEOUT": "forever"},
+			args: []string{"-db.autoMigrate=maybe", "-log.level=debug"},
+			want: []string{
+				`DB_MAX_OPEN_CONNS: invalid integer "many"`,
+				`OCR_TIMEOUT: invalid duration "forever"`,
+				`-db.autoMigrate: invalid boolean "maybe"`,
+			},
+		},
+		{
+			name: "invalid settings",
+			env:  map[string]string{"HTTP_ADDR": "localhost", "LOG_FORMAT": "xml", "JWT-SECRET-KEY": "short"},
+			want: []string{"invalid configuration:", "http.addr (HTTP_ADDR)", "log.format (LOG_FORMAT)", "auth.secretKey (JWT_SECRET_KEY) must be at least 32 bytes long"},
+		},
+		{
+			name:    "unknown file keys",
+			file:    "app.yaml",
+			content: "http:\n  adress: \":1000\"\n  readTimeout: soon\ndebug: true\n",
+			want: []string{
+				"http.adress: unknown setting",
+				`http.readTimeout: invalid duration "soon"`,
+				"debug: expected a section of settings",
+			},
+		},
+		{
+			name:    "unknown toml keys",
+			file:    "app.toml",
+			content: "[log]\nlevel = \"warn\"\ncolour = true\n",
+			want:    []string{"log.colour: unknown setting"},
+		},
+		{
+			name:    "nested values",
+			file:    "app.yaml",
+			content: "ocr:\n  address: [a, b]\n",
+			want:    []string{"ocr.address: expected a single value"},
+		},
+		{
+			name:    "unknown format",
+			file:    "app.json",
+			content: "{}",
+			want:    []string{"unknown format"},
+		},
+		{
+			name: "missing file",
+			args: []string{"-config", "missing.yaml"},
+			want: []string{"config file:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolate(t)
+			current := Current
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file, tt.content)}, args...)
+			}
+
+			_, err := Load(args)
+			if err == nil {
+				t.Fatal("Load succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not report %q", err, want)
+				}
+			}
+			if Current != current {
+				t.Error("a failed Load changed Current")
+			}
+		})
+	}
+}
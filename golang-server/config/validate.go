@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/example/golang-postgres-crud/preprocess"
+)
+
+// minSecretKeyLength is the shortest HS256 secret accepted; RFC 7518 asks for
+// a key at least as long as the hash.
+const minSecretKeyLength = 32
+
+var ocrEngines = map[string]bool{"easyocr": true, "tesseract": true, "fake": true}
+
+var ocrBalancers = map[string]bool{"round_robin": true, "least_request": true}
+
+// Validate reports every invalid setting, naming it the way the file and
+// flags do along with its environment variable.
+func (c Config) Validate() error {
+	var v validator
+
+	if _, port, err := net.SplitHostPort(c.HTTP.Addr); err != nil {
+		v.fail("http.addr", "%q is not a host:port address such as :8080", c.HTTP.Addr)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		v.fail("http.addr", "%q does not end in a port number", c.HTTP.Addr)
+	}
+	v.check(c.HTTP.ReadHeaderTimeout >= 0, "http.readHeaderTimeout", "must not be negative")
+	v.check(c.HTTP.ReadTimeout >= 0, "http.readTimeout", "must not be negative")
+	v.check(c.HTTP.WriteTimeout >= 0, "http.writeTimeout", "must not be negative")
+	v.check(c.HTTP.IdleTimeout >= 0, "http.idleTimeout", "must not be negative")
+	v.check(c.HTTP.ShutdownTimeout > 0, "http.shutdownTimeout", "must be positive")
+
+	v.check(c.Limits.MaxHeaderBytes > 0, "limits.maxHeaderBytes", "must be positive")
+	v.check(c.Limits.MaxBodyBytes >= 0, "limits.maxBodyBytes", "must not be negative")
+	v.check(c.Limits.MultipartMemory > 0, "limits.multipartMemory", "must be positive")
+	for _, proxy := range c.HTTP.TrustedProxyList() {
+		v.check(net.ParseIP(proxy) != nil || validCIDR(proxy), "http.trustedProxies", "%q is not an IP address or CIDR", proxy)
+	}
+
+	v.check(c.RateLimit.Store == "memory" || c.RateLimit.Store == "database", "rateLimit.store", "%q is not memory or database", c.RateLimit.Store)
+	v.rate(c.RateLimit.IPPerMinute, c.RateLimit.IPBurst, "rateLimit.ipPerMinute", "rateLimit.ipBurst")
+	v.rate(c.RateLimit.UserPerMinute, c.RateLimit.UserBurst, "rateLimit.userPerMinute", "rateLimit.userBurst")
+	v.rate(c.RateLimit.APIKeyPerMinute, c.RateLimit.APIKeyBurst, "rateLimit.apiKeyPerMinute", "rateLimit.apiKeyBurst")
+	v.rate(c.RateLimit.OCRPerMinute, c.RateLimit.OCRBurst, "rateLimit.ocrPerMinute", "rateLimit.ocrBurst")
+
+	v.check(c.Quota.OCRDaily >= 0, "quota.ocrDaily", "must not be negative")
+	v.check(c.Quota.OCRMonthly >= 0, "quota.ocrMonthly", "must not be negative")
+	v.check(c.Quota.UploadBytesDaily >= 0, "quota.uploadBytesDaily", "must not be negative")
+	v.check(c.Quota.UploadBytesMonthly >= 0, "quota.uploadBytesMonthly", "must not be negative")
+
+	switch c.DB.Driver {
+	case "postgres":
+		v.check(c.DB.URL != "", "db.url", "is required with the postgres driver")
+	case "sqlite":
+	default:
+		v.fail("db.driver", "%q is not supported, expected postgres or sqlite", c.DB.Driver)
+	}
+	v.check(c.DB.MaxOpenConns >= 0, "db.maxOpenConns", "must not be negative")
+	v.check(c.DB.MaxIdleConns >= 0, "db.maxIdleConns", "must not be negative")
+	v.check(c.DB.MaxOpenConns == 0 || c.DB.MaxIdleConns <= c.DB.MaxOpenConns, "db.maxIdleConns", "must not exceed db.maxOpenConns (%d)", c.DB.MaxOpenConns)
+	v.check(c.DB.ConnMaxLifetime >= 0, "db.connMaxLifetime", "must not be negative")
+	v.check(c.DB.ConnMaxIdleTime >= 0, "db.connMaxIdleTime", "must not be negative")
+
+	if c.Auth.KeysFile != "" {
+		if _, err := os.Stat(c.Auth.KeysFile); err != nil {
+			v.fail("auth.keysFile", "%v", err)
+		}
+	}
+	v.check(c.Auth.SecretKey == "" || len(c.Auth.SecretKey) >= minSecretKeyLength, "auth.secretKey", "must be at least %d bytes long", minSecretKeyLength)
+	v.check(c.Auth.KeysReloadInterval >= 0, "auth.keysReloadInterval", "must not be negative")
+	v.check(c.Auth.Issuer != "", "auth.issuer", "must not be empty")
+	v.check(c.Auth.Audience != "", "auth.audience", "must not be empty")
+
+	if c.OIDC.IssuerURL != "" {
+		v.check(absoluteURL(c.OIDC.IssuerURL), "oidc.issuerUrl", "%q is not an absolute URL", c.OIDC.IssuerURL)
+		v.check(c.OIDC.ClientID != "", "oidc.clientId", "is required when OIDC login is enabled")
+		v.check(absoluteURL(c.OIDC.RedirectURL), "oidc.redirectUrl", "must be an absolute URL when OIDC login is enabled")
+	}
+
+	v.check(ocrEngines[c.OCR.Engine], "ocr.engine", "%q is not one of easyocr, tesseract or fake", c.OCR.Engine)
+	for _, engine := range c.OCR.EngineList() {
+		v.check(ocrEngines[engine], "ocr.engines", "%q is not one of easyocr, tesseract or fake", engine)
+	}
+	v.check(c.OCR.TesseractPath != "", "ocr.tesseractPath", "must not be empty")
+	v.check(c.OCR.TesseractLanguages != "", "ocr.tesseractLanguages", "must not be empty")
+	addresses := c.OCR.AddressList()
+	v.check(len(addresses) > 0, "ocr.address", "must not be empty")
+	for _, address := range addresses {
+		if _, _, err := net.SplitHostPort(strings.TrimPrefix(address, "dns:///")); err != nil {
+			v.fail("ocr.address", "%q is not a host:port address", address)
+		}
+	}
+	v.check(ocrBalancers[c.OCR.Balancer], "ocr.balancer", "%q is not one of round_robin or least_request", c.OCR.Balancer)
+	v.check(c.OCR.Retries >= 0 && c.OCR.Retries <= 4, "ocr.retries", "must be between 0 and 4")
+	v.check(c.OCR.RetryBackoff > 0, "ocr.retryBackoff", "must be positive")
+	v.check(c.OCR.BreakerFailures >= 0, "ocr.breakerFailures", "must not be negative")
+	v.check(c.OCR.BreakerCooldown > 0, "ocr.breakerCooldown", "must be positive")
+	v.check(c.OCR.Timeout > 0, "ocr.timeout", "must be positive")
+	v.check(c.OCR.WorkerInterval >= 0, "ocr.workerInterval", "must not be negative")
+	v.check(c.OCR.MaxAttempts >= 1, "ocr.maxAttempts", "must be at least 1")
+
+	v.check(c.OCRCache.MaxBytes > 0, "ocrCache.maxBytes", "must be positive")
+	v.check(c.OCRCache.TTL > 0, "ocrCache.ttl", "must be positive")
+
+	if _, err := preprocess.ParseSteps(c.Preprocess.Steps); err != nil {
+		v.fail("preprocess.steps", "%v", err)
+	}
+	v.check(c.Preprocess.MaxDimension > 0, "preprocess.maxDimension", "must be positive")
+	v.check(c.Preprocess.MaxPixels > 0, "preprocess.maxPixels", "must be positive")
+
+	v.check(c.Storage.ImageDir != "", "storage.imageDir", "must not be empty")
+
+	var level slog.Level
+	v.check(level.UnmarshalText([]byte(c.Log.Level)) == nil, "log.level", "%q is not one of debug, info, warn or error", c.Log.Level)
+	v.check(c.Log.Format == "json" || c.Log.Format == "text", "log.format", "%q is not json or text", c.Log.Format)
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	case "file":
+		v.check(c.Tracing.File != "", "tracing.file", "is required with the file exporter")
+	default:
+		v.fail("tracing.exporter", "%q is not one of none, stdout, file or otlp", c.Tracing.Exporter)
+	}
+	v.check(c.Tracing.ServiceName != "", "tracing.serviceName", "must not be empty")
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sampleRatio", "must be between 0 and 1")
+
+	if err := errors.Join(v.errs...); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+	return nil
+}
+
+func validCIDR(raw string) bool {
+	_, _, err := net.ParseCIDR(raw)
+	return err == nil
+}
+
+func absoluteURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) check(ok bool, key, format string, args ...any) {
+	if !ok {
+		v.fail(key, format, args...)
+	}
+}
+
+// rate checks a rate limit: no negative rate, and a burst of at least one
+// request when the limit is on.
+func (v *validator) rate(perMinute, burst int, perMinuteKey, burstKey string) {
+	v.check(perMinute >= 0, perMinuteKey, "must not be negative")
+	v.check(perMinute == 0 || burst >= 1, burstKey, "must be at least 1 while %s is set", perMinuteKey)
+}
+
+func (v *validator) fail(key, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("  %s (%s) %s", key, envName(key), fmt.Sprintf(format, args...)))
+}
+
+// envName returns the environment variable of the setting with the given key.
+func envName(key string) string {
+	cfg := Defaults()
+	for _, s := range settings(&cfg) {
+		if s.key == key {
+			return s.env[0]
+		}
+	}
+	return ""
+}
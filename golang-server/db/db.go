@@ -2,21 +2,87 @@ package db
 
 import (
 	"log"
+	"strings"
+	"time"
 
 	"github.com/example/golang-postgres-crud/config"
-	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/tracing"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
+// sqlitePragmas are applied to every SQLite connection unless DATABASE_URL
+// sets its own. SQLite leaves foreign keys off by default, and the busy
+// timeout lets the OCR worker and requests wait for each other's writes.
+var sqlitePragmas = []string{"foreign_keys(1)", "busy_timeout(5000)", "journal_mode(WAL)"}
+
 func ConnectDatabase() {
-	database, err := gorm.Open(postgres.Open(config.DB_URL), &gorm.Config{})
+	var dialector gorm.Dialector
+	switch config.Current.DB.Driver {
+	case "postgres":
+		dialector = postgres.Open(config.Current.DB.URL)
+	case "sqlite":
+		dialector = sqlite.Open(sqliteDSN(config.Current.DB.URL))
+	default:
+		log.Fatalf("Unknown database driver %q, expected postgres or sqlite", config.Current.DB.Driver)
+	}
+
+	// GORM logs through the standard logger, which logging.Setup routes to
+	// slog. A lookup finding nothing is not worth a warning.
+	gormLogger := logger.New(log.Default(), logger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  logger.Warn,
+		IgnoreRecordNotFoundError: true,
+	})
+	database, err := gorm.Open(dialector, &gorm.Config{Logger: gormLogger})
 	if err != nil {
 		log.Fatal("Failed to connect to database")
 	}
+	if err := database.Use(tracing.GormPlugin()); err != nil {
+		log.Fatalf("Failed to set up query tracing: %v", err)
+	}
+
+	sqlDB, err := database.DB()
+	if err != nil {
+		log.Fatalf("Failed to configure the connection pool: %v", err)
+	}
+	pool := config.Current.DB
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
 	DB = database
-	DB.AutoMigrate(&models.User{})
-	DB.AutoMigrate(&models.TextReadings{})
+}
+
+func sqliteDSN(dsn string) string {
+	if strings.Contains(dsn, "_pragma=") {
+		return dsn
+	}
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	for _, pragma := range sqlitePragmas {
+		dsn += separator + "_pragma=" + pragma
+		separator = "&"
+	}
+	return dsn
+}
+
+// EnsureSchema applies pending migrations when DB_AUTO_MIGRATE is enabled and
+// then refuses to continue unless the schema matches this binary.
+func EnsureSchema() {
+	if config.Current.DB.AutoMigrate {
+		if _, err := MigrateUp(0); err != nil {
+			log.Fatalf("Failed to migrate database: %v", err)
+		}
+	}
+	if err := CheckSchemaVersion(); err != nil {
+		log.Fatalf("Refusing to start: %v. Run the migrate command to update the database.", err)
+	}
 }
@@ -0,0 +1,35 @@
+// Package dbtest gives tests of the code that queries the database a
+// migrated SQLite database of their own.
+package dbtest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/example/golang-postgres-crud/db"
+	"gorm.io/gorm"
+)
+
+// Open connects db.DB to a new SQLite database in a temporary directory,
+// applies the migrations and returns it. The connection is closed and the
+// previous one restored when the test ends.
+func Open(t testing.TB) *gorm.DB {
+	t.Helper()
+	dir := t.TempDir()
+	savedConfig, savedDB := config.Current, db.DB
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+		config.Current, db.DB = savedConfig, savedDB
+	})
+
+	config.Current.DB.Driver = "sqlite"
+	config.Current.DB.URL = filepath.Join(dir, "test.db")
+	db.ConnectDatabase()
+	if _, err := db.MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+	return db.DB
+}
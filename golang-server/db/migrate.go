@@ -0,0 +1,302 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Each dialect has its own directory of migrations under migrations/, with
+// the same versions in each.
+//
+//go:embed migrations/*/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the Postgres advisory lock held while migrations run, so
+// two instances starting at the same time don't apply them twice.
+const migrationLockID = 4721305
+
+var createSchemaMigrations = map[string]string{
+	"postgres": `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    bigint PRIMARY KEY,
+    name       text NOT NULL,
+    applied_at timestamptz NOT NULL DEFAULT now()
+)`,
+	"sqlite": `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    integer PRIMARY KEY,
+    name       text NOT NULL,
+    applied_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP
+)`,
+}
+
+// Migration is one embedded schema change. Files are named
+// <version>_<name>.up.sql and <version>_<name>.down.sql.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes a migration and, if it has been applied, when.
+// Unknown marks versions recorded in the database that this binary doesn't
+// ship.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	Unknown   bool
+}
+
+type schemaMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// LoadMigrations returns the embedded migrations for a dialect ("postgres"
+// or "sqlite") ordered by version.
+func LoadMigrations(dialect string) ([]Migration, error) {
+	files, err := fs.Glob(migrationFiles, path.Join("migrations", dialect, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no migrations for database dialect %q", dialect)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		base := path.Base(file)
+		name, direction, ok := strings.Cut(strings.TrimSuffix(base, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: expected <version>_<name>.up.sql or .down.sql", base)
+		}
+		versionText, name, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(versionText)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: invalid version %q", base, versionText)
+		}
+
+		content, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrateUp applies pending migrations up to and including the target
+// version, or all of them when target is 0. It returns the migrations that
+// were applied.
+func MigrateUp(target int) ([]Migration, error) {
+	migrations, err := LoadMigrations(DB.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	err = withMigrationLock(func(conn *gorm.DB) error {
+		done, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkKnownVersions(migrations, done); err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+			if target > 0 && m.Version > target {
+				break
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Up).Error; err != nil {
+					return err
+				}
+				return tx.Exec("INSERT INTO schema_migrations (version, name) VALUES (?, ?)", m.Version, m.Name).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			slog.Info("Applied migration", "version", m.Version, "name", m.Name)
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// MigrateDown rolls back the given number of most recently applied
+// migrations and returns them in the order they were undone.
+func MigrateDown(steps int) ([]Migration, error) {
+	migrations, err := LoadMigrations(DB.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	err = withMigrationLock(func(conn *gorm.DB) error {
+		done, err := appliedMigrations(conn)
+		if err != nil {
+			return err
+		}
+		if err := checkKnownVersions(migrations, done); err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+			m := migrations[i]
+			if _, ok := done[m.Version]; !ok {
+				continue
+			}
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(m.Down).Error; err != nil {
+					return err
+				}
+				return tx.Exec("DELETE FROM schema_migrations WHERE version = ?", m.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rolling back migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			slog.Info("Rolled back migration", "version", m.Version, "name", m.Name)
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// MigrationStatuses lists every embedded migration together with any
+// versions the database knows about but this binary doesn't.
+func MigrationStatuses() ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations(DB.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	done := make(map[int]schemaMigration)
+	if DB.Migrator().HasTable("schema_migrations") {
+		if done, err = appliedMigrations(DB); err != nil {
+			return nil, err
+		}
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if row, ok := done[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+			delete(done, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range done {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, MigrationStatus{Version: row.Version, Name: row.Name, AppliedAt: &appliedAt, Unknown: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// CheckSchemaVersion makes sure the database schema is exactly the one this
+// binary was built for: every embedded migration is applied and the database
+// records no version the binary doesn't know.
+func CheckSchemaVersion() error {
+	statuses, err := MigrationStatuses()
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, status := range statuses {
+		if status.Unknown {
+			return fmt.Errorf("database schema version %d (%s) is unknown to this binary; it was probably migrated by a newer release", status.Version, status.Name)
+		}
+		if status.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%d_%s", status.Version, status.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("database schema is behind, pending migrations: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// withMigrationLock runs fn on a single connection holding the migration
+// advisory lock. SQLite has no advisory locks, but it serializes writers, so
+// a second process racing to apply the same migration fails and rolls back
+// instead of applying it twice.
+func withMigrationLock(fn func(conn *gorm.DB) error) error {
+	dialect := DB.Dialector.Name()
+	return DB.Connection(func(conn *gorm.DB) error {
+		if dialect == "postgres" {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockID).Error; err != nil {
+				return err
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockID)
+		}
+
+		if err := conn.Exec(createSchemaMigrations[dialect]).Error; err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+func appliedMigrations(conn *gorm.DB) (map[int]schemaMigration, error) {
+	var rows []schemaMigration
+	if err := conn.Table("schema_migrations").Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	done := make(map[int]schemaMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}
+
+func checkKnownVersions(migrations []Migration, done map[int]schemaMigration) error {
+	known := make(map[int]bool, len(migrations))
+	for _, m := range migrations {
+		known[m.Version] = true
+	}
+	for version, row := range done {
+		if !known[version] {
+			return fmt.Errorf("database schema version %d (%s) is unknown to this binary", version, row.Name)
+		}
+	}
+	return nil
+}
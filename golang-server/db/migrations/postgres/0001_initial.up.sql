@@ -0,0 +1,22 @@
+-- Schema of the first release. IF NOT EXISTS lets databases that were set up
+-- by GORM's AutoMigrate adopt the migrations without changes.
+CREATE TABLE IF NOT EXISTS users (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    username   text CONSTRAINT users_username_key UNIQUE,
+    password   text
+);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS text_readings (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    file_size  bigint,
+    file_path  text,
+    ocr_text   text
+);
+CREATE INDEX IF NOT EXISTS idx_text_readings_deleted_at ON text_readings (deleted_at);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_oidc_identity;
+ALTER TABLE users DROP COLUMN IF EXISTS oidc_subject;
+ALTER TABLE users DROP COLUMN IF EXISTS oidc_issuer;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,34 @@
+CREATE TABLE IF NOT EXISTS tags (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    name       text NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags (name);
+
+CREATE TABLE IF NOT EXISTS folders (
+    id         bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    name       text NOT NULL,
+    parent_id  bigint
+);
+CREATE INDEX IF NOT EXISTS idx_folders_name ON folders (name);
+CREATE INDEX IF NOT EXISTS idx_folders_parent_id ON folders (parent_id);
+
+ALTER TABLE text_readings ADD COLUMN IF NOT EXISTS folder_id bigint;
+CREATE INDEX IF NOT EXISTS idx_text_readings_folder_id ON text_readings (folder_id);
+
+CREATE TABLE IF NOT EXISTS text_reading_tags (
+    text_readings_id bigint CONSTRAINT fk_text_reading_tags_text_readings REFERENCES text_readings (id),
+    tag_id           bigint CONSTRAINT fk_text_reading_tags_tag REFERENCES tags (id),
+    PRIMARY KEY (text_readings_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS reading_metadata (
+    id              bigserial PRIMARY KEY,
+    text_reading_id bigint NOT NULL CONSTRAINT fk_text_readings_metadata_entries REFERENCES text_readings (id),
+    key             text NOT NULL,
+    value           text
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reading_metadata_key ON reading_metadata (text_reading_id, key);
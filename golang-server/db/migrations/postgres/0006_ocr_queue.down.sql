@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_text_readings_content_hash;
+DROP INDEX IF EXISTS idx_text_readings_ocr_status;
+ALTER TABLE text_readings DROP COLUMN IF EXISTS content_hash;
+ALTER TABLE text_readings DROP COLUMN IF EXISTS ocr_attempts;
+ALTER TABLE text_readings DROP COLUMN IF EXISTS ocr_error;
+ALTER TABLE text_readings DROP COLUMN IF EXISTS ocr_status;
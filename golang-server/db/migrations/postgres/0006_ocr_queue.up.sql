@@ -0,0 +1,6 @@
+ALTER TABLE text_readings ADD COLUMN IF NOT EXISTS ocr_status text NOT NULL DEFAULT 'done';
+ALTER TABLE text_readings ADD COLUMN IF NOT EXISTS ocr_error text;
+ALTER TABLE text_readings ADD COLUMN IF NOT EXISTS ocr_attempts bigint NOT NULL DEFAULT 0;
+ALTER TABLE text_readings ADD COLUMN IF NOT EXISTS content_hash text;
+CREATE INDEX IF NOT EXISTS idx_text_readings_ocr_status ON text_readings (ocr_status);
+CREATE INDEX IF NOT EXISTS idx_text_readings_content_hash ON text_readings (content_hash);
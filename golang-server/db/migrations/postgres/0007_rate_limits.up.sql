@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key        text PRIMARY KEY,
+    tokens     double precision NOT NULL,
+    updated_at timestamptz NOT NULL,
+    full_at    timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_full_at ON rate_limit_buckets (full_at);
+
+CREATE TABLE IF NOT EXISTS usage_counters (
+    key        text PRIMARY KEY,
+    value      bigint NOT NULL DEFAULT 0,
+    expires_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_usage_counters_expires_at ON usage_counters (expires_at);
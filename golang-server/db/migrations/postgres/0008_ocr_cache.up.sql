@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS ocr_cache (
+    key        text PRIMARY KEY,
+    text       text NOT NULL,
+    created_at timestamptz NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_ocr_cache_expires_at ON ocr_cache (expires_at);
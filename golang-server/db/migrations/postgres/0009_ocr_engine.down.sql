@@ -0,0 +1 @@
+ALTER TABLE text_readings DROP COLUMN IF EXISTS ocr_engine;
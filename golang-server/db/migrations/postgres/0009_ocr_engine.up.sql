@@ -0,0 +1 @@
+ALTER TABLE text_readings ADD COLUMN IF NOT EXISTS ocr_engine text;
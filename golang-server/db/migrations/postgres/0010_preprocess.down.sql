@@ -0,0 +1,2 @@
+ALTER TABLE text_readings DROP COLUMN IF EXISTS preprocess_steps;
+ALTER TABLE text_readings DROP COLUMN IF EXISTS processed_path;
@@ -0,0 +1,2 @@
+ALTER TABLE text_readings ADD COLUMN IF NOT EXISTS processed_path text;
+ALTER TABLE text_readings ADD COLUMN IF NOT EXISTS preprocess_steps text;
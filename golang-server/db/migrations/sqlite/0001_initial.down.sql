@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS text_readings;
+DROP TABLE IF EXISTS users;
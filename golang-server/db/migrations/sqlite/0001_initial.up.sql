@@ -0,0 +1,22 @@
+-- SQLite counterpart of postgres/0001_initial.up.sql. Versions match across
+-- dialects, so both describe the same schema.
+CREATE TABLE users (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    username   text CONSTRAINT users_username_key UNIQUE,
+    password   text
+);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE text_readings (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    deleted_at datetime,
+    file_size  integer,
+    file_path  text,
+    ocr_text   text
+);
+CREATE INDEX idx_text_readings_deleted_at ON text_readings (deleted_at);
@@ -0,0 +1,17 @@
+CREATE TABLE api_keys (
+    id           integer PRIMARY KEY AUTOINCREMENT,
+    created_at   datetime,
+    updated_at   datetime,
+    deleted_at   datetime,
+    user_id      integer,
+    name         text,
+    prefix       text,
+    key_hash     text,
+    scopes       text,
+    expires_at   datetime,
+    last_used_at datetime,
+    revoked_at   datetime
+);
+CREATE INDEX idx_api_keys_deleted_at ON api_keys (deleted_at);
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
+CREATE UNIQUE INDEX idx_api_keys_prefix ON api_keys (prefix);
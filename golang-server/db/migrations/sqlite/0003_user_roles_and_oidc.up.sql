@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN role text NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN oidc_issuer text;
+ALTER TABLE users ADD COLUMN oidc_subject text;
+CREATE UNIQUE INDEX idx_users_oidc_identity ON users (oidc_issuer, oidc_subject);
@@ -0,0 +1,31 @@
+CREATE TABLE audit_events (
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    created_at  datetime,
+    actor_id    integer,
+    actor_name  text,
+    action      text,
+    target_type text,
+    target_id   text,
+    success     numeric,
+    ip          text,
+    user_agent  text,
+    details     text
+);
+CREATE INDEX idx_audit_events_created_at ON audit_events (created_at);
+CREATE INDEX idx_audit_events_actor_id ON audit_events (actor_id);
+CREATE INDEX idx_audit_events_action ON audit_events (action);
+CREATE INDEX idx_audit_events_target ON audit_events (target_type, target_id);
+
+-- SQLite has no TRUNCATE, so rejecting updates and deletes is enough to keep
+-- the log append-only.
+CREATE TRIGGER audit_events_no_update
+    BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit events are append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete
+    BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit events are append-only');
+END;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS reading_metadata;
+DROP TABLE IF EXISTS text_reading_tags;
+DROP INDEX IF EXISTS idx_text_readings_folder_id;
+ALTER TABLE text_readings DROP COLUMN folder_id;
+DROP TABLE IF EXISTS folders;
+DROP TABLE IF EXISTS tags;
@@ -0,0 +1,34 @@
+CREATE TABLE tags (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    name       text NOT NULL
+);
+CREATE UNIQUE INDEX idx_tags_name ON tags (name);
+
+CREATE TABLE folders (
+    id         integer PRIMARY KEY AUTOINCREMENT,
+    created_at datetime,
+    updated_at datetime,
+    name       text NOT NULL,
+    parent_id  integer
+);
+CREATE INDEX idx_folders_name ON folders (name);
+CREATE INDEX idx_folders_parent_id ON folders (parent_id);
+
+ALTER TABLE text_readings ADD COLUMN folder_id integer;
+CREATE INDEX idx_text_readings_folder_id ON text_readings (folder_id);
+
+CREATE TABLE text_reading_tags (
+    text_readings_id integer CONSTRAINT fk_text_reading_tags_text_readings REFERENCES text_readings (id),
+    tag_id           integer CONSTRAINT fk_text_reading_tags_tag REFERENCES tags (id),
+    PRIMARY KEY (text_readings_id, tag_id)
+);
+
+CREATE TABLE reading_metadata (
+    id              integer PRIMARY KEY AUTOINCREMENT,
+    text_reading_id integer NOT NULL CONSTRAINT fk_text_readings_metadata_entries REFERENCES text_readings (id),
+    key             text NOT NULL,
+    value           text
+);
+CREATE UNIQUE INDEX idx_reading_metadata_key ON reading_metadata (text_reading_id, key);
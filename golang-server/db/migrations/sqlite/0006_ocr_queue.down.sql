@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_text_readings_content_hash;
+DROP INDEX IF EXISTS idx_text_readings_ocr_status;
+ALTER TABLE text_readings DROP COLUMN content_hash;
+ALTER TABLE text_readings DROP COLUMN ocr_attempts;
+ALTER TABLE text_readings DROP COLUMN ocr_error;
+ALTER TABLE text_readings DROP COLUMN ocr_status;
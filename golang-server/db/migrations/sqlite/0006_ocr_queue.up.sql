@@ -0,0 +1,6 @@
+ALTER TABLE text_readings ADD COLUMN ocr_status text NOT NULL DEFAULT 'done';
+ALTER TABLE text_readings ADD COLUMN ocr_error text;
+ALTER TABLE text_readings ADD COLUMN ocr_attempts integer NOT NULL DEFAULT 0;
+ALTER TABLE text_readings ADD COLUMN content_hash text;
+CREATE INDEX idx_text_readings_ocr_status ON text_readings (ocr_status);
+CREATE INDEX idx_text_readings_content_hash ON text_readings (content_hash);
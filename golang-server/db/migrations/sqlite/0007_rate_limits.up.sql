@@ -0,0 +1,14 @@
+CREATE TABLE rate_limit_buckets (
+    key        text PRIMARY KEY,
+    tokens     real NOT NULL,
+    updated_at datetime NOT NULL,
+    full_at    datetime NOT NULL
+);
+CREATE INDEX idx_rate_limit_buckets_full_at ON rate_limit_buckets (full_at);
+
+CREATE TABLE usage_counters (
+    key        text PRIMARY KEY,
+    value      integer NOT NULL DEFAULT 0,
+    expires_at datetime NOT NULL
+);
+CREATE INDEX idx_usage_counters_expires_at ON usage_counters (expires_at);
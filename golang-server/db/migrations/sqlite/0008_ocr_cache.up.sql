@@ -0,0 +1,7 @@
+CREATE TABLE ocr_cache (
+    key        text PRIMARY KEY,
+    text       text NOT NULL,
+    created_at datetime NOT NULL,
+    expires_at datetime NOT NULL
+);
+CREATE INDEX idx_ocr_cache_expires_at ON ocr_cache (expires_at);
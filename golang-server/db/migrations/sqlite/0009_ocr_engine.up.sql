@@ -0,0 +1 @@
+ALTER TABLE text_readings ADD COLUMN ocr_engine text;
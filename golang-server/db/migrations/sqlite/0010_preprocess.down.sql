@@ -0,0 +1,2 @@
+ALTER TABLE text_readings DROP COLUMN preprocess_steps;
+ALTER TABLE text_readings DROP COLUMN processed_path;
@@ -0,0 +1,2 @@
+ALTER TABLE text_readings ADD COLUMN processed_path text;
+ALTER TABLE text_readings ADD COLUMN preprocess_steps text;
@@ -0,0 +1,77 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/golang-postgres-crud/models"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	header := []string{"id", "createdAt", "updatedAt", "filePath", "fileSize", "folderId", "tags", "metadata", "ocrText"}
+	if err := cw.w.Write(header); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) Write(reading models.TextReadings) error {
+	folderID := ""
+	if reading.FolderID != nil {
+		folderID = strconv.FormatUint(uint64(*reading.FolderID), 10)
+	}
+
+	tags := make([]string, len(reading.Tags))
+	for i, tag := range reading.Tags {
+		tags[i] = tag.Name
+	}
+
+	metadata := ""
+	if len(reading.Metadata) > 0 {
+		encoded, err := json.Marshal(reading.Metadata)
+		if err != nil {
+			return err
+		}
+		metadata = string(encoded)
+	}
+
+	return cw.w.Write([]string{
+		strconv.FormatUint(uint64(reading.ID), 10),
+		reading.CreatedAt.Format(time.RFC3339),
+		reading.UpdatedAt.Format(time.RFC3339),
+		csvText(reading.FilePath),
+		strconv.FormatInt(reading.FileSize, 10),
+		folderID,
+		csvText(strings.Join(tags, ";")),
+		csvText(metadata),
+		csvText(reading.OcrText),
+	})
+}
+
+// csvText keeps spreadsheets from running text as a formula, prefixing the
+// characters a formula starts with by an apostrophe.
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func (cw *csvWriter) Flush() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
@@ -0,0 +1,71 @@
+package export_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/example/golang-postgres-crud/export"
+	"github.com/example/golang-postgres-crud/models"
+	"gorm.io/gorm"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	folder := uint(7)
+	readings := []models.TextReadings{
+		{
+			Model:    gorm.Model{ID: 1, CreatedAt: created, UpdatedAt: created.Add(time.Hour)},
+			FilePath: "images/1_invoice.png",
+			FileSize: 2048,
+			FolderID: &folder,
+			Tags:     []models.Tag{{Name: "draft"}, {Name: "urgent"}},
+			Metadata: map[string]string{"client": "ACME, Inc."},
+			OcrText:  "Total: \"42\", due\nnext week",
+		},
+		{
+			Model:    gorm.Model{ID: 2, CreatedAt: created, UpdatedAt: created},
+			FilePath: "=HYPERLINK(\"http://example.com\").png",
+			Tags:     []models.Tag{{Name: "+tag"}},
+			OcrText:  "@SUM(A1:A9)",
+		},
+		{
+			Model:   gorm.Model{ID: 3, CreatedAt: created, UpdatedAt: created},
+			OcrText: "-1 is not a formula here",
+		},
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(exportReadings(t, export.FormatCSV, export.Options{}, readings...))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"id", "createdAt", "updatedAt", "filePath", "fileSize", "folderId", "tags", "metadata", "ocrText"},
+		{"1", "2026-03-01T12:00:00Z", "2026-03-01T13:00:00Z", "images/1_invoice.png", "2048", "7", "draft;urgent", `{"client":"ACME, Inc."}`, "Total: \"42\", due\nnext week"},
+		// Text a spreadsheet would run as a formula is quoted.
+		{"2", "2026-03-01T12:00:00Z", "2026-03-01T12:00:00Z", "'=HYPERLINK(\"http://example.com\").png", "0", "", "'+tag", "", "'@SUM(A1:A9)"},
+		{"3", "2026-03-01T12:00:00Z", "2026-03-01T12:00:00Z", "", "0", "", "", "", "'-1 is not a formula here"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got\n%q\nwant\n%q", records, want)
+	}
+}
+
+func TestCSVFlush(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := export.NewWriter(export.FormatCSV, &out, export.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Write(models.TextReadings{OcrText: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("first")) {
+		t.Errorf("flushed %q, want the first reading", out.String())
+	}
+}
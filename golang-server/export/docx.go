@@ -0,0 +1,105 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/example/golang-postgres-crud/models"
+)
+
+const docxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>
+</Types>`
+
+const docxRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="word/document.xml"/>
+</Relationships>`
+
+const docxDocumentStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>`
+
+const docxDocumentEnd = `<w:sectPr/></w:body></w:document>`
+
+// docxWriter produces a minimal WordprocessingML package. The document part
+// is the last zip entry, so readings can be appended to it as they arrive.
+type docxWriter struct {
+	zip      *zip.Writer
+	document io.Writer
+	written  int
+}
+
+func newDOCXWriter(w io.Writer) (*docxWriter, error) {
+	zw := zip.NewWriter(w)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxRelationships},
+	}
+	for _, part := range parts {
+		entry, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(entry, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	document, err := zw.Create("word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(document, docxDocumentStart); err != nil {
+		return nil, err
+	}
+
+	return &docxWriter{zip: zw, document: document}, nil
+}
+
+func (dw *docxWriter) Write(reading models.TextReadings) error {
+	var b strings.Builder
+
+	if dw.written > 0 {
+		b.WriteString(`<w:p><w:r><w:br w:type="page"/></w:r></w:p>`)
+	}
+	dw.written++
+
+	title := fmt.Sprintf("Reading %d: %s", reading.ID, filepath.Base(reading.FilePath))
+	b.WriteString(`<w:p><w:r><w:rPr><w:b/><w:sz w:val="28"/></w:rPr>`)
+	writeDOCXText(&b, title)
+	b.WriteString(`</w:r></w:p>`)
+
+	for _, line := range strings.Split(reading.OcrText, "\n") {
+		b.WriteString(`<w:p><w:r>`)
+		writeDOCXText(&b, strings.TrimRight(line, "\r"))
+		b.WriteString(`</w:r></w:p>`)
+	}
+
+	_, err := io.WriteString(dw.document, b.String())
+	return err
+}
+
+func writeDOCXText(b *strings.Builder, text string) {
+	b.WriteString(`<w:t xml:space="preserve">`)
+	xml.EscapeText(b, []byte(text))
+	b.WriteString(`</w:t>`)
+}
+
+func (dw *docxWriter) Flush() error {
+	return dw.zip.Flush()
+}
+
+func (dw *docxWriter) Close() error {
+	if _, err := io.WriteString(dw.document, docxDocumentEnd); err != nil {
+		return err
+	}
+	return dw.zip.Close()
+}
@@ -0,0 +1,92 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/export"
+	"github.com/example/golang-postgres-crud/models"
+	"gorm.io/gorm"
+)
+
+// docxParagraphs returns the text of each paragraph in the document part,
+// with page breaks as "---".
+func docxParagraphs(t *testing.T, docx []byte) []string {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(docx), int64(len(docx)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	var document io.ReadCloser
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+		if file.Name == "word/document.xml" {
+			if document, err = file.Open(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if want := []string{"[Content_Types].xml", "_rels/.rels", "word/document.xml"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("parts %v, want %v", names, want)
+	}
+	defer document.Close()
+
+	var paragraphs []string
+	var text *strings.Builder
+	decoder := xml.NewDecoder(document)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return paragraphs
+		}
+		if err != nil {
+			t.Fatalf("document is not XML: %v", err)
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			switch token.Name.Local {
+			case "p":
+				text = &strings.Builder{}
+			case "br":
+				text.WriteString("---")
+			}
+		case xml.CharData:
+			if text != nil {
+				text.Write(token)
+			}
+		case xml.EndElement:
+			if token.Name.Local == "p" {
+				paragraphs = append(paragraphs, text.String())
+				text = nil
+			}
+		}
+	}
+}
+
+func TestDOCXRoundTrip(t *testing.T) {
+	docx := exportReadings(t, export.FormatDOCX, export.Options{},
+		models.TextReadings{Model: gorm.Model{ID: 1}, FilePath: "images/1_note.png", OcrText: "Fish & <chips>\r\n  indented"},
+		models.TextReadings{Model: gorm.Model{ID: 2}, OcrText: "Zażółć gęślą jaźń"},
+	)
+	want := []string{
+		"Reading 1: 1_note.png",
+		"Fish & <chips>",
+		"  indented",
+		"---",
+		"Reading 2: .",
+		"Zażółć gęślą jaźń",
+	}
+	if got := docxParagraphs(t, docx); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := docxParagraphs(t, exportReadings(t, export.FormatDOCX, export.Options{})); len(got) != 0 {
+		t.Errorf("empty export has paragraphs %q", got)
+	}
+}
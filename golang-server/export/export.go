@@ -0,0 +1,80 @@
+// Package export renders text readings into downloadable documents. Every
+// format is written incrementally, so callers can stream large sets of
+// readings without holding them in memory.
+package export
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/storage"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatZIP   = "zip"
+	FormatDOCX  = "docx"
+	FormatPDF   = "pdf"
+)
+
+// Writer receives readings one at a time. Flush passes what is buffered on
+// to the underlying io.Writer. Close must be called to finish the document;
+// it does not close the underlying io.Writer.
+type Writer interface {
+	Write(reading models.TextReadings) error
+	Flush() error
+	Close() error
+}
+
+// Options configures the formats that embed the images of readings.
+type Options struct {
+	// Images holds the image files of the readings.
+	Images storage.Storage
+	// MaxPixels bounds the size of the images decoded; larger ones are left
+	// out and their page shows the text only.
+	MaxPixels int64
+}
+
+func NewWriter(format string, w io.Writer, opts Options) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatJSONL:
+		return newJSONLWriter(w), nil
+	case FormatZIP:
+		return newZIPWriter(w), nil
+	case FormatDOCX:
+		return newDOCXWriter(w)
+	case FormatPDF:
+		return newPDFWriter(w, opts)
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSONL:
+		return "application/x-ndjson"
+	case FormatZIP:
+		return "application/zip"
+	case FormatDOCX:
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case FormatPDF:
+		return "application/pdf"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+func ValidFormat(format string) bool {
+	switch format {
+	case FormatCSV, FormatJSONL, FormatZIP, FormatDOCX, FormatPDF:
+		return true
+	}
+	return false
+}
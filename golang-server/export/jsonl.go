@@ -0,0 +1,28 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/example/golang-postgres-crud/models"
+)
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	return &jsonlWriter{encoder: json.NewEncoder(w)}
+}
+
+func (jw *jsonlWriter) Write(reading models.TextReadings) error {
+	return jw.encoder.Encode(reading)
+}
+
+func (jw *jsonlWriter) Flush() error {
+	return nil
+}
+
+func (jw *jsonlWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,314 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"strings"
+
+	"github.com/example/golang-postgres-crud/models"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+const (
+	pdfPageWidth     = 595.0 // A4 width in points
+	pdfTextPageRatio = 842.0 / 595.0
+	pdfMargin        = 36.0
+)
+
+// Object numbers reserved up front; everything else is allocated as pages are
+// written.
+const (
+	pdfCatalogObject = 1
+	pdfPagesObject   = 2
+	pdfFontObject    = 3
+	pdfFirstFreeObj  = 4
+)
+
+// pdfLatin2Differences maps the upper half of ISO-8859-2 to glyph names of the
+// standard Helvetica font, so Polish and other Central European text stays
+// searchable without embedding a font.
+var pdfLatin2Differences = strings.Join([]string{
+	"space", "Aogonek", "breve", "Lslash", "currency", "Lcaron", "Sacute", "section",
+	"dieresis", "Scaron", "Scedilla", "Tcaron", "Zacute", "hyphen", "Zcaron", "Zdotaccent",
+	"degree", "aogonek", "ogonek", "lslash", "acute", "lcaron", "sacute", "caron",
+	"cedilla", "scaron", "scedilla", "tcaron", "zacute", "hungarumlaut", "zcaron", "zdotaccent",
+	"Racute", "Aacute", "Acircumflex", "Abreve", "Adieresis", "Lacute", "Cacute", "Ccedilla",
+	"Ccaron", "Eacute", "Eogonek", "Edieresis", "Ecaron", "Iacute", "Icircumflex", "Dcaron",
+	"Dcroat", "Nacute", "Ncaron", "Oacute", "Ocircumflex", "Ohungarumlaut", "Odieresis", "multiply",
+	"Rcaron", "Uring", "Uacute", "Uhungarumlaut", "Udieresis", "Yacute", "Tcommaaccent", "germandbls",
+	"racute", "aacute", "acircumflex", "abreve", "adieresis", "lacute", "cacute", "ccedilla",
+	"ccaron", "eacute", "eogonek", "edieresis", "ecaron", "iacute", "icircumflex", "dcaron",
+	"dcroat", "nacute", "ncaron", "oacute", "ocircumflex", "ohungarumlaut", "odieresis", "divide",
+	"rcaron", "uring", "uacute", "uhungarumlaut", "udieresis", "yacute", "tcommaaccent", "dotaccent",
+}, " /")
+
+// pdfWriter writes a PDF with one page per reading. When the reading's image
+// is available the page shows the image with the OCR text as an invisible
+// layer on top; otherwise the text is printed visibly.
+type pdfWriter struct {
+	w       *countingWriter
+	offsets map[int]int64
+	nextObj int
+	pages   []int
+	encoder *encoding.Encoder
+	opts    Options
+}
+
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+func newPDFWriter(w io.Writer, opts Options) (*pdfWriter, error) {
+	pw := &pdfWriter{
+		w:       &countingWriter{w: bufio.NewWriter(w)},
+		offsets: make(map[int]int64),
+		nextObj: pdfFirstFreeObj,
+		encoder: encoding.ReplaceUnsupported(charmap.ISO8859_2.NewEncoder()),
+		opts:    opts,
+	}
+
+	if _, err := io.WriteString(pw.w, "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n"); err != nil {
+		return nil, err
+	}
+
+	font := fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica "+
+		"/Encoding << /Type /Encoding /BaseEncoding /WinAnsiEncoding /Differences [160 /%s] >> >>", pdfLatin2Differences)
+	if err := pw.writeObject(pdfFontObject, font); err != nil {
+		return nil, err
+	}
+
+	return pw, nil
+}
+
+func (pw *pdfWriter) allocate() int {
+	obj := pw.nextObj
+	pw.nextObj++
+	return obj
+}
+
+func (pw *pdfWriter) writeObject(obj int, body string) error {
+	pw.offsets[obj] = pw.w.n
+	_, err := fmt.Fprintf(pw.w, "%d 0 obj\n%s\nendobj\n", obj, body)
+	return err
+}
+
+func (pw *pdfWriter) writeStream(obj int, dict string, data []byte) error {
+	return pw.copyStream(obj, dict, bytes.NewReader(data), int64(len(data)))
+}
+
+// copyStream writes a stream object with the first length bytes of data.
+func (pw *pdfWriter) copyStream(obj int, dict string, data io.Reader, length int64) error {
+	pw.offsets[obj] = pw.w.n
+	if _, err := fmt.Fprintf(pw.w, "%d 0 obj\n<< %s /Length %d >>\nstream\n", obj, dict, length); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(pw.w, data, length); err != nil {
+		return err
+	}
+	_, err := io.WriteString(pw.w, "\nendstream\nendobj\n")
+	return err
+}
+
+func (pw *pdfWriter) Write(reading models.TextReadings) error {
+	imageObj, width, height, err := pw.writeImage(reading.FilePath)
+	if err != nil {
+		return err
+	}
+
+	var content bytes.Buffer
+	lines := strings.Split(strings.ReplaceAll(reading.OcrText, "\r", ""), "\n")
+
+	if imageObj != 0 {
+		fmt.Fprintf(&content, "q %.2f 0 0 %.2f 0 0 cm /Im0 Do Q\n", width, height)
+		pw.writeTextLayer(&content, lines, 0, 0, width, height, true)
+	} else {
+		width, height = pdfPageWidth, pdfPageWidth*pdfTextPageRatio
+		pw.writeTextLayer(&content, lines, pdfMargin, pdfMargin, width-2*pdfMargin, height-2*pdfMargin, false)
+	}
+
+	contentObj := pw.allocate()
+	if err := pw.writeStream(contentObj, "", content.Bytes()); err != nil {
+		return err
+	}
+
+	resources := fmt.Sprintf("/Font << /F0 %d 0 R >>", pdfFontObject)
+	if imageObj != 0 {
+		resources += fmt.Sprintf(" /XObject << /Im0 %d 0 R >>", imageObj)
+	}
+
+	pageObj := pw.allocate()
+	pw.pages = append(pw.pages, pageObj)
+	return pw.writeObject(pageObj, fmt.Sprintf(
+		"<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %.2f %.2f] /Resources << %s >> /Contents %d 0 R >>",
+		pdfPagesObject, width, height, resources, contentObj))
+}
+
+// writeTextLayer spreads the lines evenly over the given box. Invisible text
+// (render mode 3) is stretched horizontally to roughly cover the image width
+// so selections line up with the page.
+func (pw *pdfWriter) writeTextLayer(content *bytes.Buffer, lines []string, x, y, width, height float64, invisible bool) {
+	if len(lines) == 0 {
+		return
+	}
+
+	leading := height / float64(len(lines))
+	fontSize := leading * 0.8
+	if invisible && fontSize > 24 {
+		fontSize = 24
+	}
+	if !invisible && fontSize > 11 {
+		fontSize, leading = 11, 13
+	}
+
+	content.WriteString("BT\n")
+	if invisible {
+		content.WriteString("3 Tr\n")
+	}
+	fmt.Fprintf(content, "/F0 %.2f Tf\n", fontSize)
+
+	for i, line := range lines {
+		if line == "" {
+			continue
+		}
+		encoded, err := pw.encoder.String(line)
+		if err != nil {
+			continue
+		}
+
+		scale := 100.0
+		if invisible {
+			scale = width / (float64(len(encoded)) * fontSize * 0.5) * 100
+		}
+		baseline := y + height - float64(i+1)*leading + (leading-fontSize)/2
+		fmt.Fprintf(content, "%.2f Tz 1 0 0 1 %.2f %.2f Tm <%x> Tj\n", scale, x, baseline, encoded)
+	}
+
+	content.WriteString("ET\n")
+}
+
+// writeImage embeds the image as an XObject and returns its object number and
+// page size. JPEG data is copied through unchanged; other formats are decoded,
+// with transparent areas turned white, and stored as compressed RGB. Images
+// with more pixels than Options.MaxPixels are left out, as viewers decode
+// JPEGs too. A missing, unreadable or too large image returns 0.
+func (pw *pdfWriter) writeImage(path string) (int, float64, float64, error) {
+	if pw.opts.Images == nil || path == "" {
+		return 0, 0, 0, nil
+	}
+	file, err := pw.opts.Images.Open(path)
+	if err != nil {
+		return 0, 0, 0, nil
+	}
+	defer file.Close()
+
+	config, format, err := image.DecodeConfig(bufio.NewReader(file))
+	if err != nil || config.Width == 0 || config.Height == 0 {
+		return 0, 0, 0, nil
+	}
+	// Decoding takes 4 bytes or more per pixel, whatever the size of the file.
+	if int64(config.Width)*int64(config.Height) > pw.opts.MaxPixels {
+		return 0, 0, 0, nil
+	}
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, 0, 0, nil
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, 0, 0, nil
+	}
+
+	width := pdfPageWidth
+	height := pdfPageWidth * float64(config.Height) / float64(config.Width)
+
+	if format == "jpeg" {
+		colorSpace := "/DeviceRGB"
+		switch config.ColorModel {
+		case color.GrayModel:
+			colorSpace = "/DeviceGray"
+		case color.CMYKModel:
+			// Adobe applications write CMYK JPEGs with inverted values.
+			colorSpace = "/DeviceCMYK /Decode [1 0 1 0 1 0 1 0]"
+		}
+		obj := pw.allocate()
+		dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace %s /BitsPerComponent 8 /Filter /DCTDecode",
+			config.Width, config.Height, colorSpace)
+		return obj, width, height, pw.copyStream(obj, dict, file, size)
+	}
+
+	img, _, err := image.Decode(bufio.NewReader(file))
+	if err != nil {
+		return 0, 0, 0, nil
+	}
+
+	// PDF images have no alpha, so the image is laid on white paper first.
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Over)
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	row := make([]byte, bounds.Dx()*3)
+	for y := 0; y < bounds.Dy(); y++ {
+		pix := rgba.Pix[y*rgba.Stride : y*rgba.Stride+4*bounds.Dx()]
+		for x := 0; x < bounds.Dx(); x++ {
+			copy(row[3*x:3*x+3], pix[4*x:4*x+3])
+		}
+		if _, err := zw.Write(row); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return 0, 0, 0, err
+	}
+
+	obj := pw.allocate()
+	dict := fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode",
+		bounds.Dx(), bounds.Dy())
+	return obj, width, height, pw.writeStream(obj, dict, compressed.Bytes())
+}
+
+func (pw *pdfWriter) Flush() error {
+	return pw.w.w.Flush()
+}
+
+func (pw *pdfWriter) Close() error {
+	kids := make([]string, len(pw.pages))
+	for i, page := range pw.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+
+	if err := pw.writeObject(pdfPagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>",
+		strings.Join(kids, " "), len(pw.pages))); err != nil {
+		return err
+	}
+	if err := pw.writeObject(pdfCatalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObject)); err != nil {
+		return err
+	}
+
+	xrefOffset := pw.w.n
+	fmt.Fprintf(pw.w, "xref\n0 %d\n0000000000 65535 f \n", pw.nextObj)
+	for obj := 1; obj < pw.nextObj; obj++ {
+		fmt.Fprintf(pw.w, "%010d 00000 n \n", pw.offsets[obj])
+	}
+	fmt.Fprintf(pw.w, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", pw.nextObj, pdfCatalogObject, xrefOffset)
+
+	return pw.w.w.Flush()
+}
@@ -0,0 +1,220 @@
+package export_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/export"
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+	"golang.org/x/text/encoding/charmap"
+)
+
+type pdfStream struct {
+	dict string
+	data []byte
+}
+
+var pdfStreamStart = regexp.MustCompile(`\d+ 0 obj\n<< (.*) /Length (\d+) >>\nstream\n`)
+
+// pdfStreams returns the stream objects of a PDF written by the export.
+func pdfStreams(t *testing.T, pdf []byte) []pdfStream {
+	t.Helper()
+	var streams []pdfStream
+	for _, loc := range pdfStreamStart.FindAllSubmatchIndex(pdf, -1) {
+		length, _ := strconv.Atoi(string(pdf[loc[4]:loc[5]]))
+		if loc[1]+length > len(pdf) || !bytes.HasPrefix(pdf[loc[1]+length:], []byte("\nendstream\n")) {
+			t.Fatalf("stream at %d is not %d bytes long", loc[0], length)
+		}
+		streams = append(streams, pdfStream{dict: string(pdf[loc[2]:loc[3]]), data: pdf[loc[1] : loc[1]+length]})
+	}
+	return streams
+}
+
+func imageStreams(streams []pdfStream) []pdfStream {
+	var found []pdfStream
+	for _, stream := range streams {
+		if strings.Contains(stream.dict, "/Subtype /Image") {
+			found = append(found, stream)
+		}
+	}
+	return found
+}
+
+func exportReadings(t *testing.T, format string, opts export.Options, readings ...models.TextReadings) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	writer, err := export.NewWriter(format, &out, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, reading := range readings {
+		if err := writer.Write(reading); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func store(t *testing.T, images *fakes.Storage, name string, data []byte) string {
+	t.Helper()
+	filePath, err := images.Save(name, bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestPDFImages(t *testing.T) {
+	images := fakes.NewStorage()
+	var photo bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewGray(image.Rect(0, 0, 40, 30)), nil); err != nil {
+		t.Fatal(err)
+	}
+	jpegPath := store(t, images, "photo.jpg", photo.Bytes())
+
+	// A transparent PNG with one opaque black pixel.
+	scan := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	scan.Set(1, 0, color.Black)
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, scan); err != nil {
+		t.Fatal(err)
+	}
+	pngPath := store(t, images, "scan.png", encoded.Bytes())
+
+	pdf := exportReadings(t, export.FormatPDF, export.Options{Images: images, MaxPixels: 10000},
+		models.TextReadings{FilePath: jpegPath},
+		models.TextReadings{FilePath: pngPath},
+		models.TextReadings{FilePath: "memory/missing.png"},
+	)
+	found := imageStreams(pdfStreams(t, pdf))
+	if len(found) != 2 {
+		t.Fatalf("PDF has %d images, want 2", len(found))
+	}
+
+	if !strings.Contains(found[0].dict, "/Width 40 /Height 30 /ColorSpace /DeviceGray") || !strings.Contains(found[0].dict, "/DCTDecode") {
+		t.Errorf("JPEG image %q", found[0].dict)
+	}
+	if !bytes.Equal(found[0].data, photo.Bytes()) {
+		t.Error("JPEG was not embedded unchanged")
+	}
+
+	if !strings.Contains(found[1].dict, "/Width 2 /Height 1 /ColorSpace /DeviceRGB") {
+		t.Errorf("PNG image %q", found[1].dict)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(found[1].data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pixels, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The transparent pixel is laid on white.
+	if want := []byte{255, 255, 255, 0, 0, 0}; !bytes.Equal(pixels, want) {
+		t.Errorf("PNG pixels %v, want %v", pixels, want)
+	}
+}
+
+func TestPDFLeavesOutLargeImages(t *testing.T) {
+	images := fakes.NewStorage()
+	var photo, scan bytes.Buffer
+	if err := jpeg.Encode(&photo, image.NewGray(image.Rect(0, 0, 200, 100)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(&scan, image.NewGray(image.Rect(0, 0, 200, 100))); err != nil {
+		t.Fatal(err)
+	}
+
+	pdf := exportReadings(t, export.FormatPDF, export.Options{Images: images, MaxPixels: 10000},
+		models.TextReadings{FilePath: store(t, images, "photo.jpg", photo.Bytes()), OcrText: "photo"},
+		models.TextReadings{FilePath: store(t, images, "scan.png", scan.Bytes()), OcrText: "scan"},
+	)
+	if found := imageStreams(pdfStreams(t, pdf)); len(found) != 0 {
+		t.Errorf("PDF has %d images, want the large ones left out", len(found))
+	}
+	if pages := bytes.Count(pdf, []byte("/Type /Page ")); pages != 2 {
+		t.Errorf("PDF has %d pages, want a text page per reading", pages)
+	}
+}
+
+var pdfShowText = regexp.MustCompile(`<([0-9a-f]*)> Tj`)
+
+// pdfPageText returns the lines of text shown on each page of a PDF written
+// by the export.
+func pdfPageText(t *testing.T, pdf []byte) [][]string {
+	t.Helper()
+	var pages [][]string
+	for _, stream := range pdfStreams(t, pdf) {
+		if stream.dict != "" {
+			continue
+		}
+		var lines []string
+		for _, match := range pdfShowText.FindAllSubmatch(stream.data, -1) {
+			encoded, err := hex.DecodeString(string(match[1]))
+			if err != nil {
+				t.Fatal(err)
+			}
+			line, err := charmap.ISO8859_2.NewDecoder().Bytes(encoded)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines = append(lines, string(line))
+		}
+		pages = append(pages, lines)
+	}
+	return pages
+}
+
+func TestPDFRoundTrip(t *testing.T) {
+	images := fakes.NewStorage()
+	var scan bytes.Buffer
+	if err := png.Encode(&scan, image.NewGray(image.Rect(0, 0, 10, 10))); err != nil {
+		t.Fatal(err)
+	}
+
+	pdf := exportReadings(t, export.FormatPDF, export.Options{Images: images, MaxPixels: 10000},
+		models.TextReadings{OcrText: "Zażółć gęślą jaźń\r\n\nsecond line"},
+		models.TextReadings{FilePath: store(t, images, "scan.png", scan.Bytes()), OcrText: "over the image"},
+	)
+	want := [][]string{{"Zażółć gęślą jaźń", "second line"}, {"over the image"}}
+	if got := pdfPageText(t, pdf); !reflect.DeepEqual(got, want) {
+		t.Errorf("page text %q, want %q", got, want)
+	}
+
+	// The cross-reference table points at every object.
+	xref := bytes.LastIndex(pdf, []byte("startxref\n"))
+	offset, err := strconv.Atoi(strings.Fields(string(pdf[xref+len("startxref\n"):]))[0])
+	if err != nil || !bytes.HasPrefix(pdf[offset:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the table", offset)
+	}
+	table, _, _ := strings.Cut(string(pdf[offset:]), "trailer\n")
+	entries := strings.Split(strings.TrimSpace(table), "\n")[3:]
+	if len(entries) < 5 {
+		t.Fatalf("table has %d objects, want the pages and their contents", len(entries))
+	}
+	for obj, entry := range entries {
+		at, _ := strconv.Atoi(entry[:10])
+		if prefix := fmt.Sprintf("%d 0 obj\n", obj+1); !bytes.HasPrefix(pdf[at:], []byte(prefix)) {
+			t.Errorf("object %d is not at %d", obj+1, at)
+		}
+	}
+	if !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Error("PDF does not end with the end-of-file marker")
+	}
+}
@@ -0,0 +1,39 @@
+package export
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/example/golang-postgres-crud/models"
+)
+
+// zipWriter stores each reading's text as a separate .txt entry named after
+// the reading's ID and original image.
+type zipWriter struct {
+	w *zip.Writer
+}
+
+func newZIPWriter(w io.Writer) *zipWriter {
+	return &zipWriter{w: zip.NewWriter(w)}
+}
+
+func (zw *zipWriter) Write(reading models.TextReadings) error {
+	base := strings.TrimSuffix(filepath.Base(reading.FilePath), filepath.Ext(reading.FilePath))
+	entry, err := zw.w.Create(fmt.Sprintf("%d_%s.txt", reading.ID, base))
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(entry, reading.OcrText)
+	return err
+}
+
+func (zw *zipWriter) Flush() error {
+	return zw.w.Flush()
+}
+
+func (zw *zipWriter) Close() error {
+	return zw.w.Close()
+}
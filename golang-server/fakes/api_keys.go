@@ -0,0 +1,96 @@
+package fakes
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+)
+
+// APIKeys is an in-memory repository.APIKeyRepository.
+type APIKeys struct {
+	mu     sync.Mutex
+	nextID uint
+	keys   map[uint]models.APIKey
+}
+
+func NewAPIKeys() *APIKeys {
+	return &APIKeys{nextID: 1, keys: make(map[uint]models.APIKey)}
+}
+
+func (r *APIKeys) Create(ctx context.Context, apiKey *models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	apiKey.ID = r.nextID
+	r.nextID++
+	apiKey.CreatedAt = time.Now()
+	apiKey.UpdatedAt = apiKey.CreatedAt
+	r.keys[apiKey.ID] = *apiKey
+	return nil
+}
+
+func (r *APIKeys) ListByUser(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	apiKeys := []models.APIKey{}
+	for _, apiKey := range r.keys {
+		if apiKey.UserID == userID {
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+	sort.Slice(apiKeys, func(i, j int) bool { return apiKeys[i].ID < apiKeys[j].ID })
+	return apiKeys, nil
+}
+
+func (r *APIKeys) GetForUser(ctx context.Context, userID, id uint) (models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	apiKey, ok := r.keys[id]
+	if !ok || apiKey.UserID != userID {
+		return models.APIKey{}, repository.ErrNotFound
+	}
+	return apiKey, nil
+}
+
+func (r *APIKeys) GetByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, apiKey := range r.keys {
+		if apiKey.Prefix == prefix {
+			return apiKey, nil
+		}
+	}
+	return models.APIKey{}, repository.ErrNotFound
+}
+
+func (r *APIKeys) Update(ctx context.Context, apiKey *models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.keys[apiKey.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	apiKey.UpdatedAt = time.Now()
+	r.keys[apiKey.ID] = *apiKey
+	return nil
+}
+
+func (r *APIKeys) TouchLastUsed(ctx context.Context, id uint, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	apiKey, ok := r.keys[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	apiKey.LastUsedAt = &at
+	r.keys[id] = apiKey
+	return nil
+}
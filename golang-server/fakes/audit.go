@@ -0,0 +1,33 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/example/golang-postgres-crud/audit"
+	"github.com/gin-gonic/gin"
+)
+
+// Audit is an audit.Recorder that keeps the entries in memory.
+type Audit struct {
+	mu      sync.Mutex
+	Entries []audit.Entry
+}
+
+func (a *Audit) Record(c *gin.Context, entry audit.Entry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.Entries = append(a.Entries, entry)
+}
+
+// Actions returns the recorded actions in order.
+func (a *Audit) Actions() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	actions := make([]string, len(a.Entries))
+	for i, entry := range a.Entries {
+		actions[i] = entry.Action
+	}
+	return actions
+}
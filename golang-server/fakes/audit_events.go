@@ -0,0 +1,84 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+)
+
+// AuditEvents is an in-memory repository.AuditRepository.
+type AuditEvents struct {
+	mu     sync.Mutex
+	events []models.AuditEvent
+}
+
+func (r *AuditEvents) Create(ctx context.Context, event *models.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event.ID = uint(len(r.events) + 1)
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	r.events = append(r.events, *event)
+	return nil
+}
+
+func (r *AuditEvents) List(ctx context.Context, filter repository.AuditFilter, limit, offset int) ([]models.AuditEvent, error) {
+	matching := r.matching(filter)
+	events := []models.AuditEvent{}
+	for i := len(matching) - 1 - offset; i >= 0 && len(events) < limit; i-- {
+		events = append(events, matching[i])
+	}
+	return events, nil
+}
+
+func (r *AuditEvents) Each(ctx context.Context, filter repository.AuditFilter, fn func(models.AuditEvent) error) error {
+	for _, event := range r.matching(filter) {
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matching returns the events the filter selects, oldest first.
+func (r *AuditEvents) matching(filter repository.AuditFilter) []models.AuditEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var events []models.AuditEvent
+	for _, event := range r.events {
+		if auditMatches(event, filter) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func auditMatches(event models.AuditEvent, filter repository.AuditFilter) bool {
+	switch {
+	case filter.ActorID != nil && (event.ActorID == nil || *event.ActorID != *filter.ActorID):
+		return false
+	case filter.ActorName != "" && event.ActorName != filter.ActorName:
+		return false
+	case filter.Action != "" && event.Action != filter.Action:
+		return false
+	case filter.TargetType != "" && event.TargetType != filter.TargetType:
+		return false
+	case filter.TargetID != "" && event.TargetID != filter.TargetID:
+		return false
+	case filter.IP != "" && event.IP != filter.IP:
+		return false
+	case filter.Success != nil && event.Success != *filter.Success:
+		return false
+	case !filter.Since.IsZero() && event.CreatedAt.Before(filter.Since):
+		return false
+	case !filter.Until.IsZero() && !event.CreatedAt.Before(filter.Until):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,90 @@
+// Package fakes provides in-memory implementations of the application's
+// dependencies, so handlers can be exercised without Postgres, the OCR server
+// or signing keys.
+package fakes
+
+import (
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/example/golang-postgres-crud/handlers"
+	"github.com/example/golang-postgres-crud/health"
+	ocr "github.com/example/golang-postgres-crud/ocr_service"
+	"github.com/example/golang-postgres-crud/ratelimit"
+	"github.com/example/golang-postgres-crud/repository"
+)
+
+// Fakes is a set of fresh in-memory dependencies.
+type Fakes struct {
+	Readings    *Readings
+	Users       *Users
+	APIKeys     *APIKeys
+	Tags        *Tags
+	Folders     *Folders
+	Storage     *Storage
+	OCR         *OCR
+	Tokens      *Tokens
+	Audit       *Audit
+	AuditEvents *AuditEvents
+}
+
+func New() *Fakes {
+	tags, folders := NewTags(), NewFolders()
+	return &Fakes{
+		Readings:    NewReadings(tags, folders),
+		Users:       NewUsers(),
+		APIKeys:     NewAPIKeys(),
+		Tags:        tags,
+		Folders:     folders,
+		Storage:     NewStorage(),
+		OCR:         &OCR{Text: "recognized text"},
+		Tokens:      NewTokens(),
+		Audit:       &Audit{},
+		AuditEvents: &AuditEvents{},
+	}
+}
+
+// Repositories returns the fake repositories. Readings is the OCR queue too.
+func (f *Fakes) Repositories() repository.Repositories {
+	return repository.Repositories{
+		Readings: f.Readings,
+		Users:    f.Users,
+		APIKeys:  f.APIKeys,
+		Tags:     f.Tags,
+		Folders:  f.Folders,
+		Audit:    f.AuditEvents,
+		OCRQueue: f.Readings,
+	}
+}
+
+// App returns an App wired to the fakes. Audit entries are kept in Audit;
+// the audit log endpoints serve AuditEvents.
+//
+// Rate limits and quotas are kept in memory and, like preprocessing,
+// configured like the server.
+func (f *Fakes) App() *handlers.App {
+	limits := ratelimit.NewMemoryStore()
+	return &handlers.App{
+		Readings: f.Readings,
+		Users:    f.Users,
+		APIKeys:  f.APIKeys,
+		Storage:  f.Storage,
+		OCR:      ocr.NewEngines(f.OCR),
+		Tokens:   f.Tokens,
+		Tags:     f.Tags,
+		Folders:  f.Folders,
+		Audit:    f.Audit,
+
+		AuditEvents: f.AuditEvents,
+
+		PreprocessSteps:   config.Current.Preprocess.StepList(),
+		PreprocessOptions: config.Current.Preprocess.Options(),
+
+		RateLimits: limits,
+		OCRLimit:   handlers.OCRLimit(),
+		Quotas:     handlers.NewQuotas(limits),
+
+		Ready: map[string]health.Checker{
+			"ocr":     f.OCR,
+			"storage": f.Storage,
+		},
+	}
+}
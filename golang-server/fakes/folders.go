@@ -0,0 +1,138 @@
+package fakes
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+)
+
+// Folders is an in-memory repository.FolderRepository.
+type Folders struct {
+	mu      sync.Mutex
+	nextID  uint
+	folders map[uint]models.Folder
+}
+
+func NewFolders() *Folders {
+	return &Folders{nextID: 1, folders: make(map[uint]models.Folder)}
+}
+
+func (r *Folders) List(ctx context.Context, filter repository.FolderFilter) ([]models.Folder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	folders := []models.Folder{}
+	for _, folder := range r.folders {
+		switch {
+		case filter.RootOnly && folder.ParentID != nil:
+			continue
+		case filter.ParentID != nil && !sameParent(folder.ParentID, filter.ParentID):
+			continue
+		}
+		folders = append(folders, folder)
+	}
+	sort.Slice(folders, func(i, j int) bool {
+		if folders[i].Name != folders[j].Name {
+			return folders[i].Name < folders[j].Name
+		}
+		return folders[i].ID < folders[j].ID
+	})
+	return folders, nil
+}
+
+func (r *Folders) Get(ctx context.Context, id uint) (models.Folder, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	folder, ok := r.folders[id]
+	if !ok {
+		return models.Folder{}, repository.ErrNotFound
+	}
+	return folder, nil
+}
+
+func (r *Folders) Descendants(ctx context.Context, id uint) ([]uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := []uint{id}
+	for i := 0; i < len(ids); i++ {
+		for _, folder := range r.folders {
+			if folder.ParentID != nil && *folder.ParentID == ids[i] {
+				ids = append(ids, folder.ID)
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (r *Folders) NameTaken(ctx context.Context, name string, parentID *uint, exceptID uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, folder := range r.folders {
+		if folder.ID != exceptID && folder.Name == name && sameParent(folder.ParentID, parentID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *Folders) CountChildren(ctx context.Context, id uint) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var count int64
+	for _, folder := range r.folders {
+		if folder.ParentID != nil && *folder.ParentID == id {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *Folders) Create(ctx context.Context, folder *models.Folder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	folder.ID = r.nextID
+	r.nextID++
+	folder.CreatedAt = time.Now()
+	folder.UpdatedAt = folder.CreatedAt
+	r.folders[folder.ID] = *folder
+	return nil
+}
+
+func (r *Folders) Update(ctx context.Context, folder *models.Folder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.folders[folder.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	folder.UpdatedAt = time.Now()
+	r.folders[folder.ID] = *folder
+	return nil
+}
+
+func (r *Folders) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.folders[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.folders, id)
+	return nil
+}
+
+func sameParent(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,44 @@
+package fakes
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	ocr "github.com/example/golang-postgres-crud/ocr_service"
+)
+
+// OCR is an ocr.OCREngine named "fake" that returns Text, or Err when it is
+// set, and records the images it was given. Its health check fails with Err
+// too. Unlike ocr.FakeEngine, its answer doesn't depend on the image.
+type OCR struct {
+	mu     sync.Mutex
+	Text   string
+	Err    error
+	Images [][]byte
+}
+
+func (o *OCR) Name() string { return "fake" }
+
+func (o *OCR) PerformOcr(ctx context.Context, image ocr.Image) (string, error) {
+	imageBytes, err := io.ReadAll(image.Reader())
+	if err != nil {
+		return "", err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.Images = append(o.Images, imageBytes)
+	if o.Err != nil {
+		return "", o.Err
+	}
+	return o.Text, nil
+}
+
+func (o *OCR) Check(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.Err
+}
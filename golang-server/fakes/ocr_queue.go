@@ -0,0 +1,99 @@
+package fakes
+
+import (
+	"context"
+	"sort"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+)
+
+// The OCR queue of the fakes is Readings itself, like the queue of the GORM
+// repositories is the text_readings table.
+
+func (r *Readings) RequeueInterrupted(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, reading := range r.readings {
+		if reading.OcrStatus == models.OcrStatusProcessing {
+			reading.OcrStatus = models.OcrStatusPending
+			r.readings[id] = reading
+		}
+	}
+	return nil
+}
+
+func (r *Readings) Claim(ctx context.Context, afterID uint) (models.TextReadings, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]uint, 0, len(r.readings))
+	for id, reading := range r.readings {
+		if id > afterID && reading.OcrStatus == models.OcrStatusPending {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return models.TextReadings{}, repository.ErrNotFound
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	reading := r.readings[ids[0]]
+	reading.OcrStatus = models.OcrStatusProcessing
+	reading.OcrAttempts++
+	r.readings[reading.ID] = reading
+	return r.prepare(reading), nil
+}
+
+func (r *Readings) Complete(ctx context.Context, id uint, result repository.OCRResult) error {
+	return r.change(id, func(reading *models.TextReadings) {
+		reading.OcrStatus = models.OcrStatusDone
+		reading.OcrText = result.Text
+		reading.OcrEngine = result.Engine
+		reading.OcrError = ""
+		reading.ProcessedPath = result.ProcessedPath
+		reading.PreprocessSteps = result.PreprocessSteps
+	})
+}
+
+func (r *Readings) Fail(ctx context.Context, id uint, status string, cause error, result repository.OCRResult) error {
+	return r.change(id, func(reading *models.TextReadings) {
+		reading.OcrStatus = status
+		reading.OcrError = cause.Error()
+		recordDerivative(reading, result)
+	})
+}
+
+func (r *Readings) Release(ctx context.Context, id uint, cause error, result repository.OCRResult) error {
+	return r.change(id, func(reading *models.TextReadings) {
+		reading.OcrStatus = models.OcrStatusPending
+		reading.OcrAttempts--
+		reading.OcrError = cause.Error()
+		recordDerivative(reading, result)
+	})
+}
+
+func recordDerivative(reading *models.TextReadings, result repository.OCRResult) {
+	if result.ProcessedPath != "" {
+		reading.ProcessedPath = result.ProcessedPath
+		reading.PreprocessSteps = result.PreprocessSteps
+	}
+}
+
+func (r *Readings) Depth(ctx context.Context) (map[string]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := map[string]int64{
+		models.OcrStatusPending:    0,
+		models.OcrStatusProcessing: 0,
+		models.OcrStatusFailed:     0,
+	}
+	for _, reading := range r.readings {
+		if _, ok := counts[reading.OcrStatus]; ok {
+			counts[reading.OcrStatus]++
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,372 @@
+package fakes
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+)
+
+// Readings is an in-memory repository.ReadingRepository and
+// repository.OCRQueue. It keeps the tags of a reading by ID and looks them up
+// in tags, so renamed and deleted tags show the way they do in the database,
+// and follows the folder tree in folders.
+type Readings struct {
+	mu       sync.Mutex
+	nextID   uint
+	readings map[uint]models.TextReadings
+	tagIDs   map[uint][]uint
+
+	tags    *Tags
+	folders *Folders
+}
+
+func NewReadings(tags *Tags, folders *Folders) *Readings {
+	return &Readings{
+		nextID:   1,
+		readings: make(map[uint]models.TextReadings),
+		tagIDs:   make(map[uint][]uint),
+		tags:     tags,
+		folders:  folders,
+	}
+}
+
+func (r *Readings) List(ctx context.Context, filter repository.ReadingFilter) ([]models.TextReadings, error) {
+	var folderIDs []uint
+	if filter.FolderID != nil {
+		folderIDs = []uint{*filter.FolderID}
+		if filter.Recursive {
+			folderIDs, _ = r.folders.Descendants(ctx, *filter.FolderID)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	readings := []models.TextReadings{}
+	for _, reading := range r.readings {
+		reading = r.prepare(reading)
+		if matches(reading, filter, folderIDs) {
+			readings = append(readings, reading)
+		}
+	}
+	sort.Slice(readings, func(i, j int) bool { return readings[i].ID < readings[j].ID })
+	return readings, nil
+}
+
+func (r *Readings) ListBatches(ctx context.Context, filter repository.ReadingFilter, size int, fn func([]models.TextReadings) error) error {
+	readings, err := r.List(ctx, filter)
+	if err != nil {
+		return err
+	}
+	for start := 0; start < len(readings); start += size {
+		end := start + size
+		if end > len(readings) {
+			end = len(readings)
+		}
+		if err := fn(readings[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Readings) Count(ctx context.Context, filter repository.ReadingFilter) (int64, error) {
+	readings, err := r.List(ctx, filter)
+	return int64(len(readings)), err
+}
+
+func (r *Readings) ListFiles(ctx context.Context) ([]repository.ReadingFiles, error) {
+	readings, err := r.List(ctx, repository.ReadingFilter{})
+	files := make([]repository.ReadingFiles, len(readings))
+	for i, reading := range readings {
+		files[i] = repository.ReadingFiles{ID: reading.ID, FilePath: reading.FilePath, ProcessedPath: reading.ProcessedPath}
+	}
+	return files, err
+}
+
+func (r *Readings) ExistingIDs(ctx context.Context, ids []uint) ([]uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing := []uint{}
+	seen := make(map[uint]bool)
+	for _, id := range ids {
+		if _, ok := r.readings[id]; ok && !seen[id] {
+			seen[id] = true
+			existing = append(existing, id)
+		}
+	}
+	sort.Slice(existing, func(i, j int) bool { return existing[i] < existing[j] })
+	return existing, nil
+}
+
+func (r *Readings) Get(ctx context.Context, id uint) (models.TextReadings, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reading, ok := r.readings[id]
+	if !ok {
+		return models.TextReadings{}, repository.ErrNotFound
+	}
+	return r.prepare(reading), nil
+}
+
+func (r *Readings) FindByContentHash(ctx context.Context, hash string) (models.TextReadings, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, reading := range r.readings {
+		if reading.ContentHash == hash {
+			return r.prepare(reading), nil
+		}
+	}
+	return models.TextReadings{}, repository.ErrNotFound
+}
+
+// Create stores the reading with its tags, creating the ones that don't exist
+// yet, and metadata, like GORM saves associations.
+func (r *Readings) Create(ctx context.Context, reading *models.TextReadings) error {
+	names := make([]string, len(reading.Tags))
+	for i, tag := range reading.Tags {
+		names[i] = tag.Name
+	}
+	tags, err := r.tags.findOrCreate(names)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reading.ID = r.nextID
+	r.nextID++
+	reading.CreatedAt = time.Now()
+	reading.UpdatedAt = reading.CreatedAt
+	if reading.OcrStatus == "" {
+		reading.OcrStatus = models.OcrStatusDone
+	}
+	reading.Tags = tags
+	r.readings[reading.ID] = *reading
+	r.tagIDs[reading.ID] = tagIDs(tags)
+	return nil
+}
+
+// Update saves the fields of a reading. Like the GORM repository it leaves
+// the tags and metadata alone.
+func (r *Readings) Update(ctx context.Context, reading *models.TextReadings) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.readings[reading.ID]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	reading.UpdatedAt = time.Now()
+	saved := *reading
+	saved.MetadataEntries = stored.MetadataEntries
+	r.readings[reading.ID] = saved
+	return nil
+}
+
+func (r *Readings) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.readings[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.readings, id)
+	delete(r.tagIDs, id)
+	return nil
+}
+
+func (r *Readings) SetTags(ctx context.Context, id uint, names []string) ([]models.Tag, error) {
+	if _, err := r.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	tags, err := r.tags.findOrCreate(names)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tagIDs[id] = tagIDs(tags)
+	return tags, nil
+}
+
+func (r *Readings) AddTags(ctx context.Context, ids []uint, names []string) error {
+	tags, err := r.tags.findOrCreate(names)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		if _, ok := r.readings[id]; !ok {
+			continue
+		}
+		for _, tag := range tags {
+			if !containsID(r.tagIDs[id], tag.ID) {
+				r.tagIDs[id] = append(r.tagIDs[id], tag.ID)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Readings) RemoveTags(ctx context.Context, ids []uint, names []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range ids {
+		var kept []uint
+		for _, tag := range r.tags.resolve(r.tagIDs[id]) {
+			if !containsName(names, tag.Name) {
+				kept = append(kept, tag.ID)
+			}
+		}
+		r.tagIDs[id] = kept
+	}
+	return nil
+}
+
+func (r *Readings) SetFolder(ctx context.Context, id uint, folderID *uint) error {
+	return r.change(id, func(reading *models.TextReadings) {
+		reading.FolderID = folderID
+	})
+}
+
+func (r *Readings) SetMetadata(ctx context.Context, id uint, metadata map[string]string) error {
+	return r.change(id, func(reading *models.TextReadings) {
+		entries := make([]models.ReadingMetadata, 0, len(reading.MetadataEntries)+len(metadata))
+		for _, entry := range reading.MetadataEntries {
+			if _, ok := metadata[entry.Key]; !ok {
+				entries = append(entries, entry)
+			}
+		}
+		for key, value := range metadata {
+			entries = append(entries, models.ReadingMetadata{TextReadingID: id, Key: key, Value: value})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+		reading.MetadataEntries = entries
+	})
+}
+
+func (r *Readings) DeleteMetadata(ctx context.Context, id uint, key string) error {
+	found := false
+	err := r.change(id, func(reading *models.TextReadings) {
+		entries := []models.ReadingMetadata{}
+		for _, entry := range reading.MetadataEntries {
+			if entry.Key == key {
+				found = true
+			} else {
+				entries = append(entries, entry)
+			}
+		}
+		reading.MetadataEntries = entries
+	})
+	if err == nil && !found {
+		return repository.ErrNotFound
+	}
+	return err
+}
+
+// change applies fn to a stored reading.
+func (r *Readings) change(id uint, fn func(*models.TextReadings)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reading, ok := r.readings[id]
+	if !ok {
+		return repository.ErrNotFound
+	}
+	fn(&reading)
+	reading.UpdatedAt = time.Now()
+	r.readings[id] = reading
+	return nil
+}
+
+// prepare fills the tags and the metadata map the way loading through GORM
+// does.
+func (r *Readings) prepare(reading models.TextReadings) models.TextReadings {
+	reading.Tags = r.tags.resolve(r.tagIDs[reading.ID])
+	reading.AfterFind(nil)
+	return reading
+}
+
+func tagIDs(tags []models.Tag) []uint {
+	ids := make([]uint, len(tags))
+	for i, tag := range tags {
+		ids[i] = tag.ID
+	}
+	return ids
+}
+
+// matches reports whether the filter selects the reading. folderIDs are the
+// folders a FolderID filter selects.
+func matches(reading models.TextReadings, filter repository.ReadingFilter, folderIDs []uint) bool {
+	if len(filter.IDs) > 0 && !containsID(filter.IDs, reading.ID) {
+		return false
+	}
+
+	for _, name := range filter.Tags {
+		found := false
+		for _, tag := range reading.Tags {
+			if tag.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	switch {
+	case filter.RootOnly && reading.FolderID != nil:
+		return false
+	case filter.FolderID != nil && (reading.FolderID == nil || !containsID(folderIDs, *reading.FolderID)):
+		return false
+	}
+
+	for _, meta := range filter.Meta {
+		found := false
+		for _, entry := range reading.MetadataEntries {
+			if entry.Key == meta.Key && (!meta.HasValue || entry.Value == meta.Value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsName(names []string, name string) bool {
+	for _, candidate := range names {
+		if candidate == name {
+			return true
+		}
+	}
+	return false
+}
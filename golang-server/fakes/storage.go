@@ -0,0 +1,148 @@
+package fakes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sync"
+
+	"github.com/example/golang-postgres-crud/storage"
+)
+
+// Storage is an in-memory storage.Storage.
+type Storage struct {
+	mu    sync.Mutex
+	count int
+	files map[string][]byte
+	trash map[string][]byte
+}
+
+func NewStorage() *Storage {
+	return &Storage{files: make(map[string][]byte), trash: make(map[string][]byte)}
+}
+
+func (s *Storage) Save(filename string, data io.Reader) (string, error) {
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count++
+	filePath := fmt.Sprintf("memory/%d_%s", s.count, path.Base(filename))
+	s.files[filePath] = content
+	return filePath, nil
+}
+
+func (s *Storage) SaveDerivative(filePath, suffix string, data io.Reader) (string, error) {
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	derivativePath := storage.DerivativePath(filePath, suffix)
+	s.files[derivativePath] = content
+	return derivativePath, nil
+}
+
+func (s *Storage) Open(filePath string) (io.ReadSeekCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.files[filePath]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: filePath, Err: fs.ErrNotExist}
+	}
+	return nopCloser{bytes.NewReader(data)}, nil
+}
+
+func (s *Storage) Delete(filePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.files, filePath)
+	return nil
+}
+
+func (s *Storage) List() ([]storage.Object, error) {
+	var objects []storage.Object
+	for _, filePath := range s.Files() {
+		objects = append(objects, storage.Object{Path: filePath})
+	}
+	return objects, nil
+}
+
+func (s *Storage) Trash(filePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.files[filePath]
+	if !ok {
+		return &fs.PathError{Op: "trash", Path: filePath, Err: fs.ErrNotExist}
+	}
+	s.trash[filePath] = data
+	delete(s.files, filePath)
+	return nil
+}
+
+func (s *Storage) Restore(filePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.trash[filePath]
+	if !ok {
+		return &fs.PathError{Op: "restore", Path: filePath, Err: fs.ErrNotExist}
+	}
+	s.files[filePath] = data
+	delete(s.trash, filePath)
+	return nil
+}
+
+func (s *Storage) Purge(filePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.trash, filePath)
+	return nil
+}
+
+func (s *Storage) ListTrash() ([]storage.Object, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	objects := make([]storage.Object, 0, len(s.trash))
+	for filePath := range s.trash {
+		objects = append(objects, storage.Object{Path: filePath})
+	}
+	return objects, nil
+}
+
+func (s *Storage) Check(ctx context.Context) error {
+	return nil
+}
+
+// Files returns the paths of the stored files.
+func (s *Storage) Files() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	paths := make([]string, 0, len(s.files))
+	for filePath := range s.files {
+		paths = append(paths, filePath)
+	}
+	return paths
+}
+
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,150 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+)
+
+// Tags is an in-memory repository.TagRepository. Like the tags table it
+// rejects duplicate names.
+type Tags struct {
+	mu     sync.Mutex
+	nextID uint
+	tags   map[uint]models.Tag
+}
+
+func NewTags() *Tags {
+	return &Tags{nextID: 1, tags: make(map[uint]models.Tag)}
+}
+
+func (r *Tags) List(ctx context.Context) ([]models.Tag, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tags := []models.Tag{}
+	for _, tag := range r.tags {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+func (r *Tags) Get(ctx context.Context, id uint) (models.Tag, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tag, ok := r.tags[id]
+	if !ok {
+		return models.Tag{}, repository.ErrNotFound
+	}
+	return tag, nil
+}
+
+func (r *Tags) NameTaken(ctx context.Context, name string, exceptID uint) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.checkUnique(models.Tag{ID: exceptID, Name: name}) != nil, nil
+}
+
+func (r *Tags) Create(ctx context.Context, tag *models.Tag) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.create(tag)
+}
+
+func (r *Tags) create(tag *models.Tag) error {
+	if err := r.checkUnique(*tag); err != nil {
+		return err
+	}
+	tag.ID = r.nextID
+	r.nextID++
+	tag.CreatedAt = time.Now()
+	tag.UpdatedAt = tag.CreatedAt
+	r.tags[tag.ID] = *tag
+	return nil
+}
+
+func (r *Tags) Update(ctx context.Context, tag *models.Tag) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tags[tag.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	if err := r.checkUnique(*tag); err != nil {
+		return err
+	}
+	tag.UpdatedAt = time.Now()
+	r.tags[tag.ID] = *tag
+	return nil
+}
+
+// Delete deletes a tag. Readings refer to tags by ID, so it drops out of
+// them too.
+func (r *Tags) Delete(ctx context.Context, id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tags[id]; !ok {
+		return repository.ErrNotFound
+	}
+	delete(r.tags, id)
+	return nil
+}
+
+// findOrCreate returns the tags with the names, creating the missing ones.
+func (r *Tags) findOrCreate(names []string) ([]models.Tag, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tag, ok := r.byName(name)
+		if !ok {
+			tag = models.Tag{Name: name}
+			if err := r.create(&tag); err != nil {
+				return nil, err
+			}
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// resolve returns the tags that still exist among ids, in order.
+func (r *Tags) resolve(ids []uint) []models.Tag {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tags := []models.Tag{}
+	for _, id := range ids {
+		if tag, ok := r.tags[id]; ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (r *Tags) byName(name string) (models.Tag, bool) {
+	for _, tag := range r.tags {
+		if tag.Name == name {
+			return tag, true
+		}
+	}
+	return models.Tag{}, false
+}
+
+func (r *Tags) checkUnique(tag models.Tag) error {
+	if other, ok := r.byName(tag.Name); ok && other.ID != tag.ID {
+		return fmt.Errorf("duplicate tag name %q", tag.Name)
+	}
+	return nil
+}
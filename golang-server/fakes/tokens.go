@@ -0,0 +1,71 @@
+package fakes
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/golang-jwt/jwt"
+)
+
+// Tokens is an auth.TokenService that hands out opaque tokens and remembers
+// the claims behind each one. Access and link tokens are told apart by
+// audience, like the real ones.
+type Tokens struct {
+	mu     sync.Mutex
+	issued map[string]*auth.Claims
+}
+
+const linkAudience = "oidc-link"
+
+func NewTokens() *Tokens {
+	return &Tokens{issued: make(map[string]*auth.Claims)}
+}
+
+func (t *Tokens) CreateToken(user models.User) (string, error) {
+	return t.create(user, "fake-token", "")
+}
+
+func (t *Tokens) VerifyToken(tokenString string) (*auth.Claims, error) {
+	return t.verify(tokenString, "")
+}
+
+func (t *Tokens) CreateLinkToken(user models.User) (string, error) {
+	return t.create(user, "fake-link-token", linkAudience)
+}
+
+func (t *Tokens) VerifyLinkToken(tokenString string) (*auth.Claims, error) {
+	return t.verify(tokenString, linkAudience)
+}
+
+func (t *Tokens) create(user models.User, prefix, audience string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	token := fmt.Sprintf("%s-%d", prefix, len(t.issued)+1)
+	t.issued[token] = &auth.Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Roles:    []string{user.Role},
+		StandardClaims: jwt.StandardClaims{
+			Subject:  strconv.FormatUint(uint64(user.ID), 10),
+			Audience: audience,
+		},
+	}
+	return token, nil
+}
+
+func (t *Tokens) verify(tokenString, audience string) (*auth.Claims, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	claims, ok := t.issued[tokenString]
+	if !ok || claims.Audience != audience {
+		return nil, errors.New("unknown token")
+	}
+	copied := *claims
+	return &copied, nil
+}
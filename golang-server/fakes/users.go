@@ -0,0 +1,98 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+)
+
+// Users is an in-memory repository.UserRepository. Like the users table it
+// rejects duplicate usernames.
+type Users struct {
+	mu     sync.Mutex
+	nextID uint
+	users  map[uint]models.User
+}
+
+func NewUsers() *Users {
+	return &Users{nextID: 1, users: make(map[uint]models.User)}
+}
+
+func (r *Users) Get(ctx context.Context, id uint) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return models.User{}, repository.ErrNotFound
+	}
+	return user, nil
+}
+
+func (r *Users) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	return r.find(func(user models.User) bool { return user.Username == username })
+}
+
+func (r *Users) GetByOIDCIdentity(ctx context.Context, issuer, subject string) (models.User, error) {
+	return r.find(func(user models.User) bool {
+		return user.OIDCIssuer != nil && user.OIDCSubject != nil && *user.OIDCIssuer == issuer && *user.OIDCSubject == subject
+	})
+}
+
+func (r *Users) find(match func(models.User) bool) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, user := range r.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return models.User{}, repository.ErrNotFound
+}
+
+func (r *Users) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.checkUnique(*user); err != nil {
+		return err
+	}
+	user.ID = r.nextID
+	r.nextID++
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *Users) Update(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; !ok {
+		return repository.ErrNotFound
+	}
+	if err := r.checkUnique(*user); err != nil {
+		return err
+	}
+	user.UpdatedAt = time.Now()
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *Users) checkUnique(user models.User) error {
+	for _, other := range r.users {
+		if other.ID != user.ID && other.Username == user.Username {
+			return fmt.Errorf("duplicate username %q", user.Username)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/golang-postgres-crud/audit"
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/middleware"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAPIKeyInput struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+type CreateAPIKeyResponse struct {
+	Key    string        `json:"key"`
+	APIKey models.APIKey `json:"apiKey"`
+}
+
+// CreateAPIKey godoc
+// @Summary      Create an API key
+// @Description  Creates a long-lived, scoped API key for the calling user. The key is returned only once; only its hash is stored.
+// @Tags         api-keys
+// @Accept       json
+// @Produce      json
+// @Param        input body      CreateAPIKeyInput true "API key name, scopes and optional lifetime"
+// @Success      201   {object}  CreateAPIKeyResponse
+// @Failure      400   {object}  problem.Problem
+// @Failure      403   {object}  problem.Problem
+// @Failure      500   {object}  problem.Problem
+// @Router       /api/api-keys [post]
+func (a *App) CreateAPIKey(c *gin.Context) {
+	var input CreateAPIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
+		return
+	}
+
+	if len(input.Scopes) == 0 {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "At least one scope is required")
+		return
+	}
+	for _, scope := range input.Scopes {
+		if !auth.ValidScope(scope) {
+			p := problem.New(http.StatusBadRequest, problem.InvalidRequest, "Unknown scope")
+			p.Scope = scope
+			problem.Respond(c, p)
+			return
+		}
+	}
+
+	if input.ExpiresInDays < 0 {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "expiresInDays must not be negative")
+		return
+	}
+
+	user, ok := a.currentUser(c)
+	if !ok {
+		return
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to generate API key")
+		return
+	}
+
+	apiKey := models.APIKey{
+		UserID:  user.ID,
+		Name:    input.Name,
+		Prefix:  prefix,
+		KeyHash: hash,
+		Scopes:  strings.Join(input.Scopes, " "),
+	}
+	if input.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, input.ExpiresInDays)
+		apiKey.ExpiresAt = &expiresAt
+	}
+
+	if err := a.APIKeys.Create(c.Request.Context(), &apiKey); err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to create API key")
+		return
+	}
+
+	a.Audit.Record(c, audit.Entry{
+		Action:     audit.ActionAPIKeyCreate,
+		TargetType: audit.TargetAPIKey,
+		TargetID:   strconv.FormatUint(uint64(apiKey.ID), 10),
+		Success:    true,
+		Details:    map[string]interface{}{"name": apiKey.Name, "prefix": apiKey.Prefix, "scopes": input.Scopes},
+	})
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{Key: key, APIKey: apiKey})
+}
+
+// ListAPIKeys godoc
+// @Summary      List API keys
+// @Description  Lists the API keys of the calling user, including revoked ones. Secrets are never returned.
+// @Tags         api-keys
+// @Produce      json
+// @Success      200 {array}   models.APIKey
+// @Failure      403 {object}  problem.Problem
+// @Router       /api/api-keys [get]
+func (a *App) ListAPIKeys(c *gin.Context) {
+	user, ok := a.currentUser(c)
+	if !ok {
+		return
+	}
+
+	apiKeys, err := a.APIKeys.ListByUser(c.Request.Context(), user.ID)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to list API keys")
+		return
+	}
+
+	c.JSON(http.StatusOK, apiKeys)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Description  Revokes one of the calling user's API keys. Revoked keys stay listed but are rejected by the API.
+// @Tags         api-keys
+// @Produce      json
+// @Param        id   path      int  true  "API key ID"
+// @Success      200  {object}  models.APIKey
+// @Failure      404  {object}  problem.Problem
+// @Router       /api/api-keys/{id} [delete]
+func (a *App) RevokeAPIKey(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	user, ok := a.currentUser(c)
+	if !ok {
+		return
+	}
+
+	apiKey, err := a.APIKeys.GetForUser(c.Request.Context(), user.ID, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "API key not found")
+		return
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load API key")
+		return
+	}
+
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		apiKey.RevokedAt = &now
+		if err := a.APIKeys.Update(c.Request.Context(), &apiKey); err != nil {
+			problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to revoke API key")
+			return
+		}
+
+		a.Audit.Record(c, audit.Entry{
+			Action:     audit.ActionAPIKeyRevoke,
+			TargetType: audit.TargetAPIKey,
+			TargetID:   strconv.FormatUint(uint64(apiKey.ID), 10),
+			Success:    true,
+			Details:    map[string]interface{}{"prefix": apiKey.Prefix},
+		})
+	}
+
+	c.JSON(http.StatusOK, apiKey)
+}
+
+// currentUser loads the authenticated user. It writes an error response and
+// reports false if the user no longer exists.
+func (a *App) currentUser(c *gin.Context) (models.User, bool) {
+	user, err := a.Users.Get(c.Request.Context(), middleware.CurrentClaims(c).UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusUnauthorized, problem.InvalidToken, "User not found")
+		return user, false
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load user")
+		return user, false
+	}
+	return user, true
+}
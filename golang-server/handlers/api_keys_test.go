@@ -0,0 +1,71 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/handlers"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/gin-gonic/gin"
+)
+
+func callWithAPIKey(router *gin.Engine, key, method, path string) int {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-API-Key", key)
+	return serve(router, req).Code
+}
+
+func TestAPIKeys(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	var created handlers.CreateAPIKeyResponse
+	expect(t, call(router, token, http.MethodPost, "/api/api-keys", `{"name": "scanner", "scopes": ["text-readings:read"], "expiresInDays": 30}`), http.StatusCreated, &created)
+	if created.Key == "" || created.APIKey.Scopes != auth.ScopeTextReadingsRead || created.APIKey.ExpiresAt == nil {
+		t.Errorf("created %+v", created)
+	}
+	if created.APIKey.KeyHash != "" {
+		t.Error("returned the key hash")
+	}
+
+	expect(t, call(router, token, http.MethodPost, "/api/api-keys", `{"name": "x", "scopes": ["admin"]}`), http.StatusBadRequest, nil)
+	expect(t, call(router, token, http.MethodPost, "/api/api-keys", `{"name": "x", "scopes": []}`), http.StatusBadRequest, nil)
+	expect(t, call(router, token, http.MethodPost, "/api/api-keys", `{"name": "x", "scopes": ["ocr"], "expiresInDays": -1}`), http.StatusBadRequest, nil)
+
+	// The key works within its scopes, and can't manage keys.
+	if code := callWithAPIKey(router, created.Key, http.MethodGet, "/api/text-readings"); code != http.StatusOK {
+		t.Errorf("reading with the key: got %d, want 200", code)
+	}
+	if code := callWithAPIKey(router, created.Key, http.MethodDelete, "/api/text-readings/1"); code != http.StatusForbidden {
+		t.Errorf("writing with a read-only key: got %d, want 403", code)
+	}
+	if code := callWithAPIKey(router, created.Key, http.MethodGet, "/api/api-keys"); code != http.StatusForbidden {
+		t.Errorf("listing keys with a key: got %d, want 403", code)
+	}
+
+	// Other users neither see nor revoke the key.
+	other := signIn(t, f, "bob", models.RoleUser)
+	var keys []models.APIKey
+	expect(t, call(router, other, http.MethodGet, "/api/api-keys", ""), http.StatusOK, &keys)
+	if len(keys) != 0 {
+		t.Errorf("bob sees %+v", keys)
+	}
+	expect(t, call(router, other, http.MethodDelete, "/api/api-keys/1", ""), http.StatusNotFound, nil)
+
+	var revoked models.APIKey
+	expect(t, call(router, token, http.MethodDelete, "/api/api-keys/1", ""), http.StatusOK, &revoked)
+	if revoked.RevokedAt == nil {
+		t.Error("revoked key has no revocation time")
+	}
+	if code := callWithAPIKey(router, created.Key, http.MethodGet, "/api/text-readings"); code != http.StatusUnauthorized {
+		t.Errorf("reading with a revoked key: got %d, want 401", code)
+	}
+	expect(t, call(router, token, http.MethodGet, "/api/api-keys", ""), http.StatusOK, &keys)
+	if len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("listed %+v, want the revoked key", keys)
+	}
+}
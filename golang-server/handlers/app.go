@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/example/golang-postgres-crud/audit"
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/example/golang-postgres-crud/health"
+	ocr "github.com/example/golang-postgres-crud/ocr_service"
+	"github.com/example/golang-postgres-crud/preprocess"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/ratelimit"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/example/golang-postgres-crud/storage"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// App holds everything the handlers depend on. routes.SetupRouter registers
+// its methods, so handlers can be exercised with the in-memory
+// implementations from the fakes package instead of Postgres and gRPC.
+type App struct {
+	Readings repository.ReadingRepository
+	Users    repository.UserRepository
+	APIKeys  repository.APIKeyRepository
+	Tags     repository.TagRepository
+	Folders  repository.FolderRepository
+	Storage  storage.Storage
+	// OCR holds the engines requests may choose from.
+	OCR *ocr.Engines
+	// OCRCache is the cache in front of OCR, nil when caching is off.
+	OCRCache *ocr.Cache
+	Tokens   auth.TokenService
+	// Audit records the audit events that AuditEvents serves to admins.
+	Audit       audit.Recorder
+	AuditEvents repository.AuditRepository
+
+	// PreprocessSteps are applied to images before OCR when a request
+	// doesn't choose its own.
+	PreprocessSteps   []preprocess.Step
+	PreprocessOptions preprocess.Options
+
+	// RateLimits keeps the rate limit buckets. OCRLimit is the limit on OCR
+	// calls per user, applied by middleware to the HTTP endpoints and by
+	// the WebSocket handler to every message.
+	RateLimits ratelimit.Store
+	OCRLimit   ratelimit.Limit
+	Quotas     *ratelimit.Quotas
+
+	// Ready holds the dependency checks run by /readyz, by name.
+	Ready map[string]health.Checker
+
+	webSockets webSockets
+}
+
+// NewApp wires the production implementations around a database connection
+// and the OCR engines.
+func NewApp(database *gorm.DB, engines *ocr.Engines) *App {
+	repos := repository.NewGorm(database)
+	disk := storage.NewDisk(config.Current.Storage.ImageDir)
+	ready := map[string]health.Checker{
+		"database": health.Database(database),
+		"storage":  disk,
+	}
+	// Only the default engine is required; the others are extras.
+	if checker, ok := engines.Default().(health.Checker); ok {
+		ready["ocr"] = checker
+	}
+	var ocrCache *ocr.Cache
+	if cfg := config.Current.OCRCache; cfg.Enabled {
+		var cacheDB *gorm.DB
+		if cfg.Persist {
+			cacheDB = database
+		}
+		ocrCache = ocr.NewCache(cfg.MaxBytes, cfg.TTL, cacheDB)
+		engines = engines.Wrap(func(engine ocr.OCREngine) ocr.OCREngine {
+			return &ocr.CachedEngine{Engine: engine, Cache: ocrCache}
+		})
+	}
+	var limits ratelimit.Store = ratelimit.NewMemoryStore()
+	if config.Current.RateLimit.Store == "database" {
+		limits = ratelimit.NewDBStore(database)
+	}
+	return &App{
+		Readings: repos.Readings,
+		Users:    repos.Users,
+		APIKeys:  repos.APIKeys,
+		Tags:     repos.Tags,
+		Folders:  repos.Folders,
+		Storage:  disk,
+		OCR:      engines,
+		OCRCache: ocrCache,
+		Tokens:   auth.JWTService{},
+
+		Audit:       audit.RepositoryRecorder{Events: repos.Audit},
+		AuditEvents: repos.Audit,
+
+		PreprocessSteps:   config.Current.Preprocess.StepList(),
+		PreprocessOptions: config.Current.Preprocess.Options(),
+
+		RateLimits: limits,
+		OCRLimit:   OCRLimit(),
+		Quotas:     NewQuotas(limits),
+
+		Ready: ready,
+	}
+}
+
+// OCRLimit returns the configured limit on OCR calls per user.
+func OCRLimit() ratelimit.Limit {
+	return ratelimit.Limit{
+		PerMinute: config.Current.RateLimit.OCRPerMinute,
+		Burst:     config.Current.RateLimit.OCRBurst,
+	}
+}
+
+// NewQuotas returns the configured quotas, counted in store.
+func NewQuotas(store ratelimit.Store) *ratelimit.Quotas {
+	q := config.Current.Quota
+	return &ratelimit.Quotas{
+		Store: store,
+		Limits: map[ratelimit.Metric]ratelimit.QuotaLimit{
+			ratelimit.OCRCalls:    {Daily: q.OCRDaily, Monthly: q.OCRMonthly},
+			ratelimit.UploadBytes: {Daily: q.UploadBytesDaily, Monthly: q.UploadBytesMonthly},
+		},
+	}
+}
+
+// idParam parses the :id path parameter. It writes an error response and
+// reports false if it is not a valid ID.
+func idParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidID, "Invalid ID format")
+		return 0, false
+	}
+	return uint(id), true
+}
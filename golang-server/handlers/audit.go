@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditPageSize = 100
+	maxAuditPageSize     = 1000
+	exportFlushEvery     = 100
+)
+
+// auditFilter parses the filters shared by the list and export endpoints.
+func auditFilter(c *gin.Context) (repository.AuditFilter, error) {
+	filter := repository.AuditFilter{
+		ActorName:  c.Query("actorName"),
+		Action:     c.Query("action"),
+		TargetType: c.Query("targetType"),
+		TargetID:   c.Query("targetId"),
+		IP:         c.Query("ip"),
+	}
+
+	if actorID := c.Query("actorId"); actorID != "" {
+		id, err := strconv.ParseUint(actorID, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid actorId")
+		}
+		actor := uint(id)
+		filter.ActorID = &actor
+	}
+	if success := c.Query("success"); success != "" {
+		value, err := strconv.ParseBool(success)
+		if err != nil {
+			return filter, fmt.Errorf("invalid success")
+		}
+		filter.Success = &value
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return filter, fmt.Errorf("invalid since, expected RFC 3339")
+		}
+		filter.Since = t
+	}
+	if until := c.Query("until"); until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return filter, fmt.Errorf("invalid until, expected RFC 3339")
+		}
+		filter.Until = t
+	}
+
+	return filter, nil
+}
+
+// GetAuditEvents godoc
+// @Summary      Query the audit log
+// @Description  Lists audit events, newest first. Admin only.
+// @Tags         audit
+// @Produce      json
+// @Param        actorId     query  int     false  "Actor user ID"
+// @Param        actorName   query  string  false  "Actor username"
+// @Param        action      query  string  false  "Action, e.g. auth.login"
+// @Param        targetType  query  string  false  "Target type, e.g. text_reading"
+// @Param        targetId    query  string  false  "Target ID"
+// @Param        ip          query  string  false  "Client IP"
+// @Param        success     query  bool    false  "Only successful or failed events"
+// @Param        since       query  string  false  "Start time (RFC 3339, inclusive)"
+// @Param        until       query  string  false  "End time (RFC 3339, exclusive)"
+// @Param        limit       query  int     false  "Page size (default 100, max 1000)"
+// @Param        offset      query  int     false  "Number of events to skip"
+// @Success      200 {array}  models.AuditEvent
+// @Failure      400 {object} problem.Problem
+// @Failure      403 {object} problem.Problem
+// @Router       /api/admin/audit-events [get]
+func (a *App) GetAuditEvents(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, err.Error())
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAuditPageSize)))
+	if err != nil || limit <= 0 || limit > maxAuditPageSize {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid limit")
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid offset")
+		return
+	}
+
+	events, err := a.AuditEvents.List(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to query audit events")
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+// ExportAuditEvents godoc
+// @Summary      Export the audit log
+// @Description  Streams the matching audit events as JSON Lines, oldest first. Admin only.
+// @Tags         audit
+// @Produce      application/x-ndjson
+// @Param        actorId     query  int     false  "Actor user ID"
+// @Param        actorName   query  string  false  "Actor username"
+// @Param        action      query  string  false  "Action, e.g. auth.login"
+// @Param        targetType  query  string  false  "Target type, e.g. text_reading"
+// @Param        targetId    query  string  false  "Target ID"
+// @Param        ip          query  string  false  "Client IP"
+// @Param        success     query  bool    false  "Only successful or failed events"
+// @Param        since       query  string  false  "Start time (RFC 3339, inclusive)"
+// @Param        until       query  string  false  "End time (RFC 3339, exclusive)"
+// @Success      200 {file}   file
+// @Failure      400 {object} problem.Problem
+// @Failure      403 {object} problem.Problem
+// @Router       /api/admin/audit-events/export [get]
+func (a *App) ExportAuditEvents(c *gin.Context) {
+	filter, err := auditFilter(c)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, err.Error())
+		return
+	}
+
+	// The response starts with the first event, so a query that fails
+	// right away still gets an error response.
+	count := 0
+	start := func() {
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", `attachment; filename="audit-events.jsonl"`)
+		c.Status(http.StatusOK)
+	}
+	encoder := json.NewEncoder(c.Writer)
+	err = a.AuditEvents.Each(c.Request.Context(), filter, func(event models.AuditEvent) error {
+		if count == 0 {
+			start()
+		}
+		count++
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+		if count%exportFlushEvery == 0 {
+			c.Writer.Flush()
+		}
+		return nil
+	})
+	switch {
+	case err != nil && count == 0:
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to query audit events")
+	case err != nil:
+		c.Error(err)
+	case count == 0:
+		start()
+	}
+}
@@ -0,0 +1,125 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+)
+
+func recordAuditEvents(t *testing.T, f *fakes.Fakes, start time.Time, events ...models.AuditEvent) {
+	t.Helper()
+	for i, event := range events {
+		event.CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		if err := f.AuditEvents.Create(context.Background(), &event); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func eventIDs(events []models.AuditEvent) []uint {
+	ids := make([]uint, len(events))
+	for i, event := range events {
+		ids[i] = event.ID
+	}
+	return ids
+}
+
+func sameIDs(got, want []uint) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGetAuditEvents(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "root", models.RoleAdmin)
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	recordAuditEvents(t, f, start,
+		models.AuditEvent{ActorName: "ann", Action: "auth.login", Success: true},
+		models.AuditEvent{ActorName: "bob", Action: "auth.login", Success: false},
+		models.AuditEvent{ActorName: "ann", Action: "text_reading.delete", Success: true},
+		models.AuditEvent{ActorName: "ann", Action: "auth.login", Success: true},
+	)
+
+	tests := []struct {
+		query string
+		want  []uint
+	}{
+		{"", []uint{4, 3, 2, 1}},
+		{"limit=2&offset=1", []uint{3, 2}},
+		{"actorName=ann&action=auth.login", []uint{4, 1}},
+		{"success=false", []uint{2}},
+		{"since=2024-05-01T12:01:00Z&until=2024-05-01T12:03:00Z", []uint{3, 2}},
+	}
+	for _, tt := range tests {
+		var events []models.AuditEvent
+		expect(t, call(router, token, http.MethodGet, "/api/admin/audit-events?"+tt.query, ""), http.StatusOK, &events)
+		if got := eventIDs(events); !sameIDs(got, tt.want) {
+			t.Errorf("%q: got events %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"actorId=ann", "success=maybe", "since=yesterday", "limit=0"} {
+		expect(t, call(router, token, http.MethodGet, "/api/admin/audit-events?"+query, ""), http.StatusBadRequest, nil)
+	}
+}
+
+func TestAuditEventsRequireAdmin(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	expect(t, call(router, token, http.MethodGet, "/api/admin/audit-events", ""), http.StatusForbidden, nil)
+	expect(t, call(router, token, http.MethodGet, "/api/admin/audit-events/export", ""), http.StatusForbidden, nil)
+}
+
+func TestExportAuditEvents(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "root", models.RoleAdmin)
+
+	recordAuditEvents(t, f, time.Now(),
+		models.AuditEvent{ActorName: "ann", Action: "auth.login", Success: true},
+		models.AuditEvent{ActorName: "bob", Action: "auth.login", Success: true},
+		models.AuditEvent{ActorName: "ann", Action: "auth.logout", Success: true},
+	)
+
+	w := call(router, token, http.MethodGet, "/api/admin/audit-events/export?actorName=ann", "")
+	expect(t, w, http.StatusOK, nil)
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("Content-Type %q, want application/x-ndjson", got)
+	}
+	var events []models.AuditEvent
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var event models.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("decode %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	if got := eventIDs(events); !sameIDs(got, []uint{1, 3}) {
+		t.Errorf("exported events %v, want 1 and 3, oldest first", got)
+	}
+
+	w = call(router, token, http.MethodGet, "/api/admin/audit-events/export?actorName=nobody", "")
+	expect(t, w, http.StatusOK, nil)
+	if w.Body.Len() != 0 || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("empty export: Content-Type %q, body %q", w.Header().Get("Content-Type"), w.Body)
+	}
+	expect(t, call(router, token, http.MethodGet, "/api/admin/audit-events/export?success=maybe", ""), http.StatusBadRequest, nil)
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
-	"github.com/example/golang-postgres-crud/auth"
-	"github.com/example/golang-postgres-crud/db"
+	"github.com/example/golang-postgres-crud/audit"
 	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/repository"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
@@ -19,36 +22,51 @@ import (
 // @Produce      json
 // @Param        user  body      models.User  true  "User Registration Info"
 // @Success      201   {object}  map[string]string
-// @Failure      400   {object}  map[string]string
-// @Failure      409   {object}  map[string]string
-// @Failure      500   {object}  map[string]string
+// @Failure      400   {object}  problem.Problem
+// @Failure      409   {object}  problem.Problem
+// @Failure      500   {object}  problem.Problem
 // @Router       /register [post]
-func RegisterHandler(c *gin.Context) {
+func (a *App) RegisterHandler(c *gin.Context) {
 	var u models.User
 
 	if err := c.ShouldBindJSON(&u); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
 		return
 	}
 
-	var existingUser models.User
-	if err := db.DB.Where("username = ?", u.Username).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "User with this name already exists"})
+	ctx := c.Request.Context()
+	_, err := a.Users.GetByUsername(ctx, u.Username)
+	if err == nil {
+		problem.Write(c, http.StatusConflict, problem.AlreadyExists, "User with this name already exists")
+		return
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to register user")
 		return
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while hashing password"})
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Error while hashing password")
 		return
 	}
 	u.Password = string(hashedPassword)
+	u.Role = models.RoleUser
 
-	if err := db.DB.Create(&u).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+	if err := a.Users.Create(ctx, &u); err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to register user")
 		return
 	}
 
+	a.Audit.Record(c, audit.Entry{
+		ActorID:    &u.ID,
+		ActorName:  u.Username,
+		Action:     audit.ActionRegister,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.FormatUint(uint64(u.ID), 10),
+		Success:    true,
+	})
+
 	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
 }
 
@@ -60,34 +78,60 @@ func RegisterHandler(c *gin.Context) {
 // @Produce      json
 // @Param        user  body      models.User  true  "User Login Credentials"
 // @Success      200   {object}  map[string]string
-// @Failure      400   {object}  map[string]string
-// @Failure      401   {object}  map[string]string
-// @Failure      500   {object}  map[string]string
+// @Failure      400   {object}  problem.Problem
+// @Failure      401   {object}  problem.Problem
+// @Failure      500   {object}  problem.Problem
 // @Router       /login [post]
-func LoginHandler(c *gin.Context) {
+func (a *App) LoginHandler(c *gin.Context) {
 	var u models.User
-	var foundUser models.User
 
 	if err := c.ShouldBindJSON(&u); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
 		return
 	}
 
-	if err := db.DB.Where("username = ?", u.Username).First(&foundUser).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	foundUser, err := a.Users.GetByUsername(c.Request.Context(), u.Username)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to look up user")
+		return
+	}
+	if err != nil {
+		a.Audit.Record(c, audit.Entry{
+			ActorName: u.Username,
+			Action:    audit.ActionLoginFailed,
+			Details:   map[string]interface{}{"reason": "unknown user"},
+		})
+		problem.Write(c, http.StatusUnauthorized, problem.InvalidCredentials, "Invalid credentials")
 		return
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(foundUser.Password), []byte(u.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+		a.Audit.Record(c, audit.Entry{
+			ActorID:    &foundUser.ID,
+			ActorName:  foundUser.Username,
+			Action:     audit.ActionLoginFailed,
+			TargetType: audit.TargetUser,
+			TargetID:   strconv.FormatUint(uint64(foundUser.ID), 10),
+			Details:    map[string]interface{}{"reason": "wrong password"},
+		})
+		problem.Write(c, http.StatusUnauthorized, problem.InvalidCredentials, "Invalid credentials")
 		return
 	}
 
-	tokenString, err := auth.CreateToken(foundUser.Username)
+	tokenString, err := a.Tokens.CreateToken(foundUser)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to create token")
 		return
 	}
 
+	a.Audit.Record(c, audit.Entry{
+		ActorID:    &foundUser.ID,
+		ActorName:  foundUser.Username,
+		Action:     audit.ActionLogin,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.FormatUint(uint64(foundUser.ID), 10),
+		Success:    true,
+	})
+
 	c.JSON(http.StatusOK, gin.H{"token": tokenString})
 }
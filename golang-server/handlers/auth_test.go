@@ -0,0 +1,64 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/audit"
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/gin-gonic/gin"
+)
+
+func post(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return serve(router, req)
+}
+
+func TestRegister(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+
+	expect(t, post(router, "/register", `{"username": "ann", "password": "secret123", "role": "admin"}`), http.StatusCreated, nil)
+	user, err := f.Users.GetByUsername(context.Background(), "ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleUser {
+		t.Errorf("registered with role %q, want %q whatever the request says", user.Role, models.RoleUser)
+	}
+	if user.Password == "secret123" {
+		t.Error("stored the password in clear")
+	}
+
+	expect(t, post(router, "/register", `{"username": "ann", "password": "other"}`), http.StatusConflict, nil)
+	expect(t, post(router, "/register", `{"username": `), http.StatusBadRequest, nil)
+	if got := f.Audit.Actions(); len(got) != 1 || got[0] != audit.ActionRegister {
+		t.Errorf("audited %v, want one registration", got)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	expect(t, post(router, "/register", `{"username": "ann", "password": "secret123"}`), http.StatusCreated, nil)
+
+	var login struct{ Token string }
+	expect(t, post(router, "/login", `{"username": "ann", "password": "secret123"}`), http.StatusOK, &login)
+	claims, err := f.Tokens.VerifyToken(login.Token)
+	if err != nil || claims.Username != "ann" || !claims.HasRole(models.RoleUser) {
+		t.Errorf("login token has claims %+v, error %v", claims, err)
+	}
+	expect(t, call(router, login.Token, http.MethodGet, "/api/me", ""), http.StatusOK, nil)
+
+	expect(t, post(router, "/login", `{"username": "ann", "password": "wrong"}`), http.StatusUnauthorized, nil)
+	expect(t, post(router, "/login", `{"username": "bob", "password": "secret123"}`), http.StatusUnauthorized, nil)
+	want := []string{audit.ActionRegister, audit.ActionLogin, audit.ActionLoginFailed, audit.ActionLoginFailed}
+	if got := f.Audit.Actions(); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("audited %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/golang-postgres-crud/export"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const exportBatchSize = 100
+
+// ExportTextReadings godoc
+// @Summary      Export text readings
+// @Description  Streams the selected readings as CSV, JSON Lines, a ZIP of plain-text files, DOCX, or a searchable PDF with the text as an invisible layer over each image. Accepts the same filters as the list endpoint and an optional list of IDs.
+// @Tags         text-readings
+// @Produce      octet-stream
+// @Param        format    query  string    true   "csv, jsonl, zip, docx or pdf"
+// @Param        ids       query  string    false  "Comma-separated reading IDs"
+// @Param        tag       query  []string  false  "Tag the reading must have (repeatable)"  collectionFormat(multi)
+// @Param        folderId  query  string    false  "Folder ID, or 'root' for readings outside any folder"
+// @Param        recursive query  bool      false  "Include readings in subfolders of folderId"
+// @Param        meta      query  []string  false  "Metadata filter as key or key:value (repeatable)"  collectionFormat(multi)
+// @Success      200 {file}   file
+// @Failure      400 {object} problem.Problem
+// @Router       /api/text-readings/export [get]
+func (a *App) ExportTextReadings(c *gin.Context) {
+	filter, err := readingFilter(c)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, err.Error())
+		return
+	}
+
+	if ids := c.Query("ids"); ids != "" {
+		for _, id := range strings.Split(ids, ",") {
+			value, err := strconv.ParseUint(strings.TrimSpace(id), 10, 64)
+			if err != nil {
+				problem.Write(c, http.StatusBadRequest, problem.InvalidID, "Invalid ids")
+				return
+			}
+			filter.IDs = append(filter.IDs, uint(value))
+		}
+	}
+
+	a.streamExport(c, filter, "text-readings")
+}
+
+// ExportTextReading godoc
+// @Summary      Export one text reading
+// @Description  Exports a single reading in the requested format.
+// @Tags         text-readings
+// @Produce      octet-stream
+// @Param        id      path   int     true  "Text Reading ID"
+// @Param        format  query  string  true  "csv, jsonl, zip, docx or pdf"
+// @Success      200 {file}   file
+// @Failure      400 {object} problem.Problem
+// @Failure      404 {object} problem.Problem
+// @Router       /api/text-readings/{id}/export [get]
+func (a *App) ExportTextReading(c *gin.Context) {
+	textReading, ok := a.loadTextReading(c)
+	if !ok {
+		return
+	}
+
+	a.streamExport(c, repository.ReadingFilter{IDs: []uint{textReading.ID}},
+		fmt.Sprintf("text-reading-%d", textReading.ID))
+}
+
+// streamExport writes the readings matched by the filter in batches, so memory
+// use does not grow with the size of the export.
+func (a *App) streamExport(c *gin.Context, filter repository.ReadingFilter, name string) {
+	format := c.Query("format")
+	if !export.ValidFormat(format) {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid format, expected csv, jsonl, zip, docx or pdf")
+		return
+	}
+
+	// The response starts with the first batch, so a query that fails
+	// right away still gets an error response.
+	var writer export.Writer
+	start := func() error {
+		c.Header("Content-Type", export.ContentType(format))
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`, name, time.Now().Format("20060102-150405"), format))
+		c.Status(http.StatusOK)
+		w, err := export.NewWriter(format, c.Writer, export.Options{
+			Images:    a.Storage,
+			MaxPixels: a.PreprocessOptions.MaxPixels,
+		})
+		if err != nil {
+			return err
+		}
+		writer = w
+		return nil
+	}
+
+	err := a.Readings.ListBatches(c.Request.Context(), filter, exportBatchSize, func(textReadings []models.TextReadings) error {
+		if writer == nil {
+			if err := start(); err != nil {
+				return err
+			}
+		}
+		for _, textReading := range textReadings {
+			if err := writer.Write(textReading); err != nil {
+				return err
+			}
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	})
+	if err == nil && writer == nil {
+		err = start()
+	}
+	switch {
+	case err != nil && !c.Writer.Written():
+		slog.ErrorContext(c.Request.Context(), "Failed to export text readings", "format", format, "error", err)
+		c.Writer.Header().Del("Content-Disposition")
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to export text readings")
+		return
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "Failed to write export", "format", format, "error", err)
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to finish export", "format", format, "error", err)
+	}
+}
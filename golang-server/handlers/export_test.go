@@ -0,0 +1,56 @@
+package handlers_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/problem"
+)
+
+func TestExportTextReadings(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+	createReadings(t, f, 3)
+
+	w := call(router, token, http.MethodGet, "/api/text-readings/export?format=csv&ids=1,3", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("got %d %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Disposition"), `attachment; filename="text-readings-`) {
+		t.Errorf("Content-Disposition %q", w.Header().Get("Content-Disposition"))
+	}
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 3 {
+		t.Errorf("exported %d lines, want a header and 2 readings: %s", len(lines), w.Body)
+	}
+
+	// An empty export is still a document.
+	w = call(router, token, http.MethodGet, "/api/text-readings/export?format=csv&ids=9", "")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "id,") {
+		t.Errorf("empty export: got %d: %s", w.Code, w.Body)
+	}
+
+	expect(t, call(router, token, http.MethodGet, "/api/text-readings/export?format=rtf", ""), http.StatusBadRequest, nil)
+	expect(t, call(router, token, http.MethodGet, "/api/text-readings/export?format=csv&ids=x", ""), http.StatusBadRequest, nil)
+	expect(t, call(router, token, http.MethodGet, "/api/text-readings/9/export?format=csv", ""), http.StatusNotFound, nil)
+}
+
+func TestExportTextReadingsReportsFailures(t *testing.T) {
+	f := fakes.New()
+	router := routerWithFailingReadings(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	// Nothing was sent when the query failed, so the error can be.
+	w := call(router, token, http.MethodGet, "/api/text-readings/export?format=pdf", "")
+	var body problem.Problem
+	expect(t, w, http.StatusInternalServerError, &body)
+	if w.Header().Get("Content-Type") != problem.ContentType || body.Code != problem.InternalError {
+		t.Errorf("got %q with code %q, want a problem", w.Header().Get("Content-Type"), body.Code)
+	}
+	if w.Header().Get("Content-Disposition") != "" {
+		t.Errorf("error offered as a download: %q", w.Header().Get("Content-Disposition"))
+	}
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type FolderInput struct {
+	Name     string `json:"name" binding:"required"`
+	ParentID *uint  `json:"parentId"`
+}
+
+// validateFolderParent checks that the parent exists, that it would not turn
+// the tree into a cycle, and that no sibling already uses the name. It writes
+// an error response and reports false on failure.
+func (a *App) validateFolderParent(c *gin.Context, folderID uint, name string, parentID *uint) bool {
+	ctx := c.Request.Context()
+	if parentID != nil {
+		_, err := a.Folders.Get(ctx, *parentID)
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Parent folder not found")
+			return false
+		}
+		if err != nil {
+			problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load folder")
+			return false
+		}
+
+		if folderID != 0 {
+			descendants, err := a.Folders.Descendants(ctx, folderID)
+			if err != nil {
+				problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to check folder tree")
+				return false
+			}
+			for _, id := range descendants {
+				if id == *parentID {
+					problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "A folder cannot be moved into itself or its subfolders")
+					return false
+				}
+			}
+		}
+	}
+
+	taken, err := a.Folders.NameTaken(ctx, name, parentID, folderID)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to check folder names")
+		return false
+	}
+	if taken {
+		problem.Write(c, http.StatusConflict, problem.AlreadyExists, "A folder with this name already exists here")
+		return false
+	}
+
+	return true
+}
+
+// GetFolders godoc
+// @Summary      List folders
+// @Description  Lists folders. Without parentId all folders are returned; parentId=root lists the top level.
+// @Tags         folders
+// @Produce      json
+// @Param        parentId query    string false "Parent folder ID or 'root'"
+// @Success      200      {array}  models.Folder
+// @Failure      400      {object} problem.Problem
+// @Router       /api/folders [get]
+func (a *App) GetFolders(c *gin.Context) {
+	var filter repository.FolderFilter
+	switch parentID := c.Query("parentId"); parentID {
+	case "":
+	case "root":
+		filter.RootOnly = true
+	default:
+		id, err := strconv.ParseUint(parentID, 10, 64)
+		if err != nil {
+			problem.Write(c, http.StatusBadRequest, problem.InvalidID, "Invalid parentId")
+			return
+		}
+		parent := uint(id)
+		filter.ParentID = &parent
+	}
+
+	folders, err := a.Folders.List(c.Request.Context(), filter)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to list folders")
+		return
+	}
+	c.JSON(http.StatusOK, folders)
+}
+
+// GetFolder godoc
+// @Summary      Get a folder
+// @Description  Retrieves a folder by its ID.
+// @Tags         folders
+// @Produce      json
+// @Param        id  path     int true "Folder ID"
+// @Success      200 {object} models.Folder
+// @Failure      404 {object} problem.Problem
+// @Router       /api/folders/{id} [get]
+func (a *App) GetFolder(c *gin.Context) {
+	folder, ok := a.loadFolder(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, folder)
+}
+
+// loadFolder fetches the folder named by the :id parameter. It writes an
+// error response and reports false if the ID is invalid or unknown.
+func (a *App) loadFolder(c *gin.Context) (models.Folder, bool) {
+	id, ok := idParam(c)
+	if !ok {
+		return models.Folder{}, false
+	}
+
+	folder, err := a.Folders.Get(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Folder not found")
+		return folder, false
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load folder")
+		return folder, false
+	}
+	return folder, true
+}
+
+// CreateFolder godoc
+// @Summary      Create a folder
+// @Description  Creates a folder, optionally inside another folder.
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        input body     FolderInput true "Folder name and optional parent"
+// @Success      201   {object} models.Folder
+// @Failure      400   {object} problem.Problem
+// @Failure      409   {object} problem.Problem
+// @Router       /api/folders [post]
+func (a *App) CreateFolder(c *gin.Context) {
+	var input FolderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
+		return
+	}
+
+	folder := models.Folder{Name: strings.TrimSpace(input.Name), ParentID: input.ParentID}
+	if folder.Name == "" {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Folder name must not be empty")
+		return
+	}
+	if !a.validateFolderParent(c, 0, folder.Name, folder.ParentID) {
+		return
+	}
+
+	if err := a.Folders.Create(c.Request.Context(), &folder); err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to create folder")
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+// UpdateFolder godoc
+// @Summary      Rename or move a folder
+// @Description  Changes a folder's name and parent. Moving a folder into its own subtree is rejected.
+// @Tags         folders
+// @Accept       json
+// @Produce      json
+// @Param        id    path     int         true "Folder ID"
+// @Param        input body     FolderInput true "New name and parent"
+// @Success      200   {object} models.Folder
+// @Failure      400   {object} problem.Problem
+// @Failure      404   {object} problem.Problem
+// @Failure      409   {object} problem.Problem
+// @Router       /api/folders/{id} [put]
+func (a *App) UpdateFolder(c *gin.Context) {
+	var input FolderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
+		return
+	}
+
+	folder, ok := a.loadFolder(c)
+	if !ok {
+		return
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Folder name must not be empty")
+		return
+	}
+	if !a.validateFolderParent(c, folder.ID, name, input.ParentID) {
+		return
+	}
+
+	folder.Name = name
+	folder.ParentID = input.ParentID
+	if err := a.Folders.Update(c.Request.Context(), &folder); err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to update folder")
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// DeleteFolder godoc
+// @Summary      Delete a folder
+// @Description  Deletes an empty folder. Folders that still contain subfolders or readings are rejected.
+// @Tags         folders
+// @Produce      json
+// @Param        id  path     int true "Folder ID"
+// @Success      200 {object} map[string]string
+// @Failure      404 {object} problem.Problem
+// @Failure      409 {object} problem.Problem
+// @Router       /api/folders/{id} [delete]
+func (a *App) DeleteFolder(c *gin.Context) {
+	folder, ok := a.loadFolder(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	subfolders, err := a.Folders.CountChildren(ctx, folder.ID)
+	var readings int64
+	if err == nil {
+		readings, err = a.Readings.Count(ctx, repository.ReadingFilter{FolderID: &folder.ID})
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to check folder contents")
+		return
+	}
+	if subfolders > 0 || readings > 0 {
+		problem.Write(c, http.StatusConflict, problem.NotEmpty, "Folder is not empty")
+		return
+	}
+
+	if err := a.Folders.Delete(ctx, folder.ID); err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to delete folder")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Folder deleted"})
+}
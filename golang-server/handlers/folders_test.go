@@ -0,0 +1,64 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+)
+
+func TestFolders(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	expect(t, call(router, token, http.MethodPost, "/api/folders", `{"name": "Archive"}`), http.StatusCreated, nil)
+	expect(t, call(router, token, http.MethodPost, "/api/folders", `{"name": "2024", "parentId": 1}`), http.StatusCreated, nil)
+	expect(t, call(router, token, http.MethodPost, "/api/folders", `{"name": "Archive"}`), http.StatusConflict, nil)
+	expect(t, call(router, token, http.MethodPost, "/api/folders", `{"name": "Archive", "parentId": 1}`), http.StatusCreated, nil)
+	expect(t, call(router, token, http.MethodPost, "/api/folders", `{"name": "Lost", "parentId": 9}`), http.StatusBadRequest, nil)
+
+	var folders []models.Folder
+	expect(t, call(router, token, http.MethodGet, "/api/folders?parentId=root", ""), http.StatusOK, &folders)
+	if len(folders) != 1 || folders[0].Name != "Archive" {
+		t.Errorf("top level has %+v, want only Archive", folders)
+	}
+	expect(t, call(router, token, http.MethodGet, "/api/folders?parentId=1", ""), http.StatusOK, &folders)
+	if len(folders) != 2 || folders[0].Name != "2024" || folders[1].Name != "Archive" {
+		t.Errorf("folder 1 has %+v, want 2024 and Archive", folders)
+	}
+
+	// Archive can't move below itself, but its subfolder can move up.
+	expect(t, call(router, token, http.MethodPut, "/api/folders/1", `{"name": "Archive", "parentId": 2}`), http.StatusBadRequest, nil)
+	expect(t, call(router, token, http.MethodPut, "/api/folders/1", `{"name": "Archive", "parentId": 1}`), http.StatusBadRequest, nil)
+	expect(t, call(router, token, http.MethodPut, "/api/folders/3", `{"name": "Archive"}`), http.StatusConflict, nil)
+	var folder models.Folder
+	expect(t, call(router, token, http.MethodPut, "/api/folders/2", `{"name": "2024"}`), http.StatusOK, &folder)
+	if folder.ParentID != nil {
+		t.Errorf("moved folder has parent %d, want the top level", *folder.ParentID)
+	}
+}
+
+func TestDeleteFolderOnlyWhenEmpty(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	expect(t, call(router, token, http.MethodPost, "/api/folders", `{"name": "Archive"}`), http.StatusCreated, nil)
+	expect(t, call(router, token, http.MethodPost, "/api/folders", `{"name": "2024", "parentId": 1}`), http.StatusCreated, nil)
+	folderID := uint(2)
+	reading := models.TextReadings{FolderID: &folderID}
+	if err := f.Readings.Create(context.Background(), &reading); err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, call(router, token, http.MethodDelete, "/api/folders/1", ""), http.StatusConflict, nil)
+	expect(t, call(router, token, http.MethodDelete, "/api/folders/2", ""), http.StatusConflict, nil)
+
+	expect(t, call(router, token, http.MethodPut, "/api/text-readings/1/folder", `{"folderId": null}`), http.StatusOK, nil)
+	expect(t, call(router, token, http.MethodDelete, "/api/folders/2", ""), http.StatusOK, nil)
+	expect(t, call(router, token, http.MethodDelete, "/api/folders/1", ""), http.StatusOK, nil)
+	expect(t, call(router, token, http.MethodGet, "/api/folders/1", ""), http.StatusNotFound, nil)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/example/golang-postgres-crud/health"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthzHandler godoc
+// @Summary      Liveness
+// @Description  Reports that the server process is up. It checks no dependencies.
+// @Tags         health
+// @Produce      json
+// @Success      200 {object} map[string]string
+// @Router       /healthz [get]
+func (a *App) HealthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// ReadyzHandler godoc
+// @Summary      Readiness
+// @Description  Checks the database, the OCR server and image storage, and reports the status and latency of each. Responds with 503 if any of them fails.
+// @Tags         health
+// @Produce      json
+// @Success      200 {object} health.Report
+// @Failure      503 {object} health.Report
+// @Router       /readyz [get]
+func (a *App) ReadyzHandler(c *gin.Context) {
+	report := health.Run(c.Request.Context(), a.Ready)
+	status := http.StatusOK
+	if report.Status != health.StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler godoc
+// @Summary      JSON Web Key Set
+// @Description  Publishes the public keys used to sign the API's tokens so other services can verify them.
+// @Tags         auth
+// @Produce      json
+// @Success      200 {object} auth.JSONWebKeySet
+// @Router       /.well-known/jwks.json [get]
+func (a *App) JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, auth.JWKS())
+}
@@ -0,0 +1,73 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/routes"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// newRouter serves the whole API, middleware included, from the fakes.
+func newRouter(f *fakes.Fakes) *gin.Engine {
+	return routes.SetupRouter(f.App())
+}
+
+func serve(router *gin.Engine, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// signIn creates a user with the role and returns a token for it.
+func signIn(t *testing.T, f *fakes.Fakes, username, role string) string {
+	t.Helper()
+	user := models.User{Username: username, Password: "hash", Role: role}
+	if err := f.Users.Create(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	token, err := f.Tokens.CreateToken(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// call sends an authenticated request with body, if it isn't empty, as JSON.
+func call(router *gin.Engine, token, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return serve(router, req)
+}
+
+// expect fails the test unless the response has the status, and decodes its
+// body into v when v isn't nil.
+func expect(t *testing.T, w *httptest.ResponseRecorder, status int, v interface{}) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("got %d, want %d: %s", w.Code, status, w.Body)
+	}
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("decode %s: %v", w.Body, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/golang-postgres-crud/audit"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type UserProfile struct {
+	ID         uint      `json:"id"`
+	Username   string    `json:"username"`
+	Role       string    `json:"role"`
+	OIDCLinked bool      `json:"oidcLinked"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type UpdateMeInput struct {
+	Username string `json:"username" binding:"required"`
+}
+
+type UpdateMeResponse struct {
+	Profile UserProfile `json:"profile"`
+	Token   string      `json:"token"`
+}
+
+func newUserProfile(user models.User) UserProfile {
+	return UserProfile{
+		ID:         user.ID,
+		Username:   user.Username,
+		Role:       user.Role,
+		OIDCLinked: user.OIDCSubject != nil,
+		CreatedAt:  user.CreatedAt,
+	}
+}
+
+// GetMe godoc
+// @Summary      Get the current user
+// @Description  Returns the profile of the authenticated caller.
+// @Tags         me
+// @Produce      json
+// @Success      200 {object} UserProfile
+// @Failure      401 {object} problem.Problem
+// @Router       /api/me [get]
+func (a *App) GetMe(c *gin.Context) {
+	user, ok := a.currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newUserProfile(user))
+}
+
+// UpdateMe godoc
+// @Summary      Update the current user
+// @Description  Changes the caller's username and returns a new token carrying it.
+// @Tags         me
+// @Accept       json
+// @Produce      json
+// @Param        input body      UpdateMeInput true "New username"
+// @Success      200   {object}  UpdateMeResponse
+// @Failure      400   {object}  problem.Problem
+// @Failure      401   {object}  problem.Problem
+// @Failure      409   {object}  problem.Problem
+// @Failure      500   {object}  problem.Problem
+// @Router       /api/me [put]
+func (a *App) UpdateMe(c *gin.Context) {
+	var input UpdateMeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
+		return
+	}
+
+	username := strings.TrimSpace(input.Username)
+	if username == "" {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Username must not be empty")
+		return
+	}
+
+	user, ok := a.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if username != user.Username {
+		_, err := a.Users.GetByUsername(c.Request.Context(), username)
+		if err == nil {
+			problem.Write(c, http.StatusConflict, problem.AlreadyExists, "User with this name already exists")
+			return
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to update user")
+			return
+		}
+
+		previousUsername := user.Username
+		user.Username = username
+		if err := a.Users.Update(c.Request.Context(), &user); err != nil {
+			problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to update user")
+			return
+		}
+
+		a.Audit.Record(c, audit.Entry{
+			Action:     audit.ActionUserUpdate,
+			TargetType: audit.TargetUser,
+			TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+			Success:    true,
+			Details:    map[string]interface{}{"previousUsername": previousUsername, "username": username},
+		})
+	}
+
+	tokenString, err := a.Tokens.CreateToken(user)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to create token")
+		return
+	}
+
+	c.JSON(http.StatusOK, UpdateMeResponse{Profile: newUserProfile(user), Token: tokenString})
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/example/golang-postgres-crud/audit"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/gin-gonic/gin"
+)
+
+type OCRCachePurgeResponse struct {
+	// Memory counts the results dropped from this replica's memory;
+	// other replicas keep theirs until they expire.
+	Memory   int   `json:"memory" example:"120"`
+	Database int64 `json:"database" example:"4500"`
+}
+
+// PurgeOCRCache godoc
+// @Summary      Purge the OCR cache
+// @Description  Drops every cached OCR result from the memory of the replica serving the request and from the database, so images are recognized again. Requires the admin role.
+// @Tags         admin
+// @Produce      json
+// @Success      200 {object} OCRCachePurgeResponse
+// @Failure      403 {object} problem.Problem
+// @Failure      404 {object} problem.Problem
+// @Failure      500 {object} problem.Problem
+// @Router       /api/admin/ocr-cache [delete]
+func (a *App) PurgeOCRCache(c *gin.Context) {
+	if a.OCRCache == nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "The OCR cache is disabled")
+		return
+	}
+
+	memory, database, err := a.OCRCache.Purge(c.Request.Context())
+	a.Audit.Record(c, audit.Entry{
+		Action:     audit.ActionOCRCachePurge,
+		TargetType: audit.TargetOCRCache,
+		Success:    err == nil,
+		Details:    map[string]interface{}{"memory": memory, "database": database},
+	})
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to purge the OCR cache", "error", err)
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to purge the OCR cache")
+		return
+	}
+
+	c.JSON(http.StatusOK, OCRCachePurgeResponse{Memory: memory, Database: database})
+}
@@ -1,11 +1,21 @@
 package handlers
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/example/golang-postgres-crud/metrics"
 	ocr "github.com/example/golang-postgres-crud/ocr_service"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/ratelimit"
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // PerformOcr godoc
@@ -14,45 +24,126 @@ import (
 // @Tags         ocr
 // @Accept       multipart/form-data
 // @Produce      json
-// @Param        image  formData  file  true  "Image file for OCR processing"
+// @Param        image  formData  file    true   "Image file for OCR processing"
+// @Param        engine query     string  false  "OCR engine to use, also accepted as a form field; defaults to the configured engine"
+// @Param        preprocess query string  false  "Comma-separated preprocessing steps before OCR: orient, downscale, grayscale, deskew, binarize, or none; also accepted as a form field; defaults to the configured steps"
 // @Success      200    {object}  map[string]string
-// @Failure      400    {object}  map[string]string
-// @Failure      500    {object}  map[string]string
+// @Failure      400    {object}  problem.Problem
+// @Failure      422    {object}  problem.Problem
+// @Failure      429    {object}  problem.Problem
+// @Failure      500    {object}  problem.Problem
 // @Router       /api/ocr [post]
-func PerformOcr(c *gin.Context) {
+func (a *App) PerformOcr(c *gin.Context) {
 	file, err := c.FormFile("image")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get image file"})
+		if uploadTooLarge(err) {
+			problem.Write(c, http.StatusRequestEntityTooLarge, problem.PayloadTooLarge, "File too large")
+			return
+		}
+		problem.Write(c, http.StatusBadRequest, problem.FileMissing, "Failed to get image file")
+		return
+	}
+	engine, ok := a.ocrEngine(c)
+	if !ok {
+		return
+	}
+	steps, ok := a.preprocessSteps(c)
+	if !ok {
 		return
 	}
 
 	fileContent, err := file.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		problem.Write(c, http.StatusInternalServerError, problem.StorageError, "Failed to open file")
 		return
 	}
 	defer fileContent.Close()
 
-	imageBytes := make([]byte, file.Size)
-	_, err = fileContent.Read(imageBytes)
+	image, err := ocr.ReadImage(fileContent, file.Size)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file content"})
+		problem.Write(c, http.StatusInternalServerError, problem.StorageError, "Failed to read file content")
 		return
 	}
+	metrics.ObserveUpload("ocr", file.Size)
 
-	ocrService, err := ocr.NewOcrService()
-	if err != nil {
-		log.Printf("Error connecting to gRPC server: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not connect to OCR server"})
+	consumption, ok := a.consumeQuota(c, ratelimit.Charge{Metric: ratelimit.OCRCalls, Amount: 1})
+	if !ok {
 		return
 	}
-	defer ocrService.Close()
-
-	extractedText, err := ocrService.PerformOcr(imageBytes)
+	if len(steps) > 0 {
+		processed, ok := a.preprocessImage(c, image, steps)
+		if !ok {
+			consumption.Refund(c.Request.Context())
+			return
+		}
+		image = ocr.NewImage(processed)
+	}
+	extractedText, err := engine.PerformOcr(c.Request.Context(), image)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not perform OCR operation"})
+		consumption.Refund(c.Request.Context())
+		ocrFailed(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"extracted_text": extractedText})
+	c.JSON(http.StatusOK, gin.H{"extracted_text": extractedText, "engine": engine.Name()})
+}
+
+// ocrEngine returns the engine named by the engine query parameter or form
+// field, or the default engine if there is none. It writes an error response
+// and reports false if no such engine is enabled.
+func (a *App) ocrEngine(c *gin.Context) (ocr.OCREngine, bool) {
+	name := c.Query("engine")
+	if name == "" {
+		name = c.PostForm("engine")
+	}
+	engine, ok := a.OCR.Get(name)
+	if !ok {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest,
+			fmt.Sprintf("Unknown OCR engine %q, expected one of %s", name, strings.Join(a.OCR.Names(), ", ")))
+	}
+	return engine, ok
+}
+
+// ocrFailed writes the problem for a failed OCR call.
+func ocrFailed(c *gin.Context, err error) {
+	failure := classifyOCRError(err)
+	if failure.retryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(retryAfterSeconds(failure.retryAfter)))
+	}
+	problem.Write(c, failure.status, failure.code, failure.detail)
+}
+
+// ocrFailure is how a failed OCR call is reported to clients.
+type ocrFailure struct {
+	status int
+	code   problem.Code
+	detail string
+	// retryAfter is when the client may try again, zero if unknown.
+	retryAfter time.Duration
+}
+
+// classifyOCRError maps the gRPC status of a failed OCR call to the HTTP
+// status that says best whether the client should retry.
+func classifyOCRError(err error) ocrFailure {
+	var open *ocr.CircuitOpenError
+	if errors.As(err, &open) {
+		return ocrFailure{http.StatusServiceUnavailable, problem.OCRUnavailable, "The OCR service is down, try again later", open.RetryAfter}
+	}
+
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return ocrFailure{http.StatusUnprocessableEntity, problem.OCRRejected, "The OCR service could not process this image", 0}
+	case codes.ResourceExhausted:
+		return ocrFailure{http.StatusTooManyRequests, problem.OCRBusy, "The OCR service is busy, try again later", 0}
+	case codes.Unavailable:
+		return ocrFailure{http.StatusServiceUnavailable, problem.OCRUnavailable, "The OCR service is unavailable", 0}
+	case codes.DeadlineExceeded:
+		return ocrFailure{http.StatusGatewayTimeout, problem.OCRTimeout, "The OCR service did not answer in time", 0}
+	default:
+		return ocrFailure{http.StatusBadGateway, problem.OCRFailed, "Could not perform OCR operation", 0}
+	}
+}
+
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
 }
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/example/golang-postgres-crud/audit"
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	oidcCookiePath = "/auth/oidc"
+	oidcFlowMaxAge = 10 * 60
+)
+
+var errOIDCProvisioningDisabled = errors.New("automatic provisioning is disabled")
+
+// OIDCLoginHandler godoc
+// @Summary      Start OIDC login
+// @Description  Redirects to the configured identity provider using the authorization-code flow with PKCE.
+// @Tags         auth
+// @Success      302
+// @Failure      404 {object} problem.Problem
+// @Failure      500 {object} problem.Problem
+// @Router       /auth/oidc/login [get]
+func (a *App) OIDCLoginHandler(c *gin.Context) {
+	if !auth.OIDCEnabled() {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "OIDC login is not configured")
+		return
+	}
+
+	flow, err := auth.NewOIDCFlow()
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to start login")
+		return
+	}
+
+	setOIDCFlowCookie(c, flow.State, flow.Nonce, flow.Verifier)
+	c.Redirect(http.StatusFound, auth.OIDCAuthURL(flow))
+}
+
+// StartOIDCLink godoc
+// @Summary      Link an OIDC identity
+// @Description  Starts linking an identity of the configured identity provider to the caller's account. The caller's browser must then open the returned URL; /auth/oidc/callback finishes the link.
+// @Tags         me
+// @Produce      json
+// @Success      200 {object} map[string]string
+// @Failure      401 {object} problem.Problem
+// @Failure      403 {object} problem.Problem
+// @Failure      404 {object} problem.Problem
+// @Failure      500 {object} problem.Problem
+// @Router       /api/me/oidc [post]
+func (a *App) StartOIDCLink(c *gin.Context) {
+	if !auth.OIDCEnabled() {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "OIDC login is not configured")
+		return
+	}
+
+	user, ok := a.currentUser(c)
+	if !ok {
+		return
+	}
+
+	flow, err := auth.NewOIDCFlow()
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to start linking")
+		return
+	}
+	// The callback links the identity to the account this token names. The
+	// cookie is only set here, for a caller holding a user token. A link
+	// token expires with the flow and is no access token, so the cookie
+	// can't be used to call the API.
+	linkToken, err := a.Tokens.CreateLinkToken(user)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to create token")
+		return
+	}
+
+	setOIDCFlowCookie(c, flow.State, flow.Nonce, flow.Verifier, linkToken)
+	c.JSON(http.StatusOK, gin.H{"url": auth.OIDCAuthURL(flow)})
+}
+
+// setOIDCFlowCookie keeps the values of a flow for the callback. The state,
+// nonce and verifier are base64url, so a link token, a JWT with dots of its
+// own, can only come last.
+func setOIDCFlowCookie(c *gin.Context, values ...string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcFlowCookie, strings.Join(values, "."),
+		oidcFlowMaxAge, oidcCookiePath, "", c.Request.TLS != nil, true)
+}
+
+// OIDCCallbackHandler godoc
+// @Summary      Finish OIDC login
+// @Description  Handles the identity provider's redirect and returns the API's own JWT. A login finds the user linked to the identity or provisions one; a flow started by /api/me/oidc links the identity to the account that started it.
+// @Tags         auth
+// @Produce      json
+// @Param        code   query     string  true  "Authorization code"
+// @Param        state  query     string  true  "State issued by /auth/oidc/login"
+// @Success      200    {object}  map[string]string
+// @Failure      400    {object}  problem.Problem
+// @Failure      401    {object}  problem.Problem
+// @Failure      403    {object}  problem.Problem
+// @Failure      409    {object}  problem.Problem
+// @Failure      500    {object}  problem.Problem
+// @Router       /auth/oidc/callback [get]
+func (a *App) OIDCCallbackHandler(c *gin.Context) {
+	if !auth.OIDCEnabled() {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "OIDC login is not configured")
+		return
+	}
+
+	if providerError := c.Query("error"); providerError != "" {
+		problem.Write(c, http.StatusUnauthorized, problem.LoginFailed, "Login was rejected by the identity provider: "+providerError)
+		return
+	}
+
+	cookie, err := c.Cookie(oidcFlowCookie)
+	c.SetCookie(oidcFlowCookie, "", -1, oidcCookiePath, "", c.Request.TLS != nil, true)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.LoginFailed, "Login session not found or expired")
+		return
+	}
+
+	parts := strings.SplitN(cookie, ".", 4)
+	if len(parts) < 3 || subtle.ConstantTimeCompare([]byte(parts[0]), []byte(c.Query("state"))) != 1 {
+		problem.Write(c, http.StatusBadRequest, problem.LoginFailed, "Invalid login state")
+		return
+	}
+	flow := auth.OIDCFlow{State: parts[0], Nonce: parts[1], Verifier: parts[2]}
+
+	identity, err := auth.OIDCExchange(c.Request.Context(), flow, c.Query("code"))
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "OIDC login failed", "error", err)
+		problem.Write(c, http.StatusUnauthorized, problem.LoginFailed, "Login with the identity provider failed")
+		return
+	}
+
+	if len(parts) == 4 {
+		a.finishOIDCLink(c, identity, parts[3])
+		return
+	}
+
+	user, err := a.findOrProvisionOIDCUser(c.Request.Context(), identity)
+	if errors.Is(err, errOIDCProvisioningDisabled) {
+		problem.Write(c, http.StatusForbidden, problem.AccountNotLinked, "No account is linked to this identity")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to provision OIDC user", "error", err)
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to provision user")
+		return
+	}
+
+	tokenString, err := a.Tokens.CreateToken(user)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to create token")
+		return
+	}
+
+	a.Audit.Record(c, audit.Entry{
+		ActorID:    &user.ID,
+		ActorName:  user.Username,
+		Action:     audit.ActionOIDCLogin,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		Success:    true,
+		Details:    map[string]interface{}{"issuer": identity.Issuer, "subject": identity.Subject},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+}
+
+// finishOIDCLink links the identity to the user linkToken names, the one
+// that started the flow with StartOIDCLink, and returns a new token for them.
+func (a *App) finishOIDCLink(c *gin.Context, identity *auth.OIDCIdentity, linkToken string) {
+	ctx := c.Request.Context()
+	claims, err := a.Tokens.VerifyLinkToken(linkToken)
+	if err != nil {
+		problem.Write(c, http.StatusUnauthorized, problem.InvalidToken, "Linking session expired, sign in again")
+		return
+	}
+	user, err := a.Users.Get(ctx, claims.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusUnauthorized, problem.InvalidToken, "User not found")
+		return
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load user")
+		return
+	}
+
+	linked, err := a.Users.GetByOIDCIdentity(ctx, identity.Issuer, identity.Subject)
+	if err == nil && linked.ID != user.ID {
+		problem.Write(c, http.StatusConflict, problem.AlreadyExists, "This identity is linked to another account")
+		return
+	}
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load user")
+		return
+	}
+
+	user.OIDCIssuer = &identity.Issuer
+	user.OIDCSubject = &identity.Subject
+	if err := a.Users.Update(ctx, &user); err != nil {
+		slog.ErrorContext(ctx, "Failed to link OIDC identity", "error", err)
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to link identity")
+		return
+	}
+
+	tokenString, err := a.Tokens.CreateToken(user)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to create token")
+		return
+	}
+
+	a.Audit.Record(c, audit.Entry{
+		ActorID:    &user.ID,
+		ActorName:  user.Username,
+		Action:     audit.ActionOIDCLink,
+		TargetType: audit.TargetUser,
+		TargetID:   strconv.FormatUint(uint64(user.ID), 10),
+		Success:    true,
+		Details:    map[string]interface{}{"issuer": identity.Issuer, "subject": identity.Subject},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+}
+
+// findOrProvisionOIDCUser returns the user linked to the identity, or creates
+// a new passwordless account for it. Existing accounts are never linked here,
+// even when the username matches the identity's email: whoever registered
+// that username first could otherwise take over the identity's logins. They
+// are linked with StartOIDCLink instead.
+func (a *App) findOrProvisionOIDCUser(ctx context.Context, identity *auth.OIDCIdentity) (models.User, error) {
+	user, err := a.Users.GetByOIDCIdentity(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return user, err
+	}
+
+	if !config.Current.OIDC.AutoProvision {
+		return user, errOIDCProvisioningDisabled
+	}
+
+	user = models.User{
+		Username:    oidcUsername(identity),
+		Role:        models.RoleUser,
+		OIDCIssuer:  &identity.Issuer,
+		OIDCSubject: &identity.Subject,
+	}
+
+	_, err = a.Users.GetByUsername(ctx, user.Username)
+	if err == nil {
+		sum := sha256.Sum256([]byte(identity.Issuer + "|" + identity.Subject))
+		user.Username = user.Username + "-" + hex.EncodeToString(sum[:])[:6]
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return user, err
+	}
+
+	return user, a.Users.Create(ctx, &user)
+}
+
+func oidcUsername(identity *auth.OIDCIdentity) string {
+	switch {
+	case identity.EmailVerified && identity.Email != "":
+		return identity.Email
+	case identity.PreferredUsername != "":
+		return identity.PreferredUsername
+	default:
+		return "oidc-" + identity.Subject
+	}
+}
@@ -0,0 +1,458 @@
+package handlers_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
+)
+
+const oidcClientID = "test-client"
+
+// oidcProvider is an identity provider that authorizes whatever identity
+// the test hands it. Its token endpoint checks the PKCE verifier against
+// the challenge of the authorization request, as a real provider does.
+type oidcProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]oidcGrant
+}
+
+type oidcGrant struct {
+	challenge string
+	nonce     string
+	subject   string
+	email     string
+}
+
+func newOIDCProvider(t *testing.T) *oidcProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &oidcProvider{key: key, codes: make(map[string]oidcGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{
+			"issuer":                                p.server.URL,
+			"authorization_endpoint":                p.server.URL + "/authorize",
+			"token_endpoint":                        p.server.URL + "/token",
+			"jwks_uri":                              p.server.URL + "/keys",
+			"response_types_supported":              []string{"code"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize plays the user signing in at the provider: it grants a code for
+// the authorization request at authURL.
+func (p *oidcProvider) authorize(t *testing.T, authURL, subject, email string) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization request without an S256 PKCE challenge: %s", authURL)
+	}
+	if query.Get("nonce") == "" {
+		t.Fatalf("authorization request without a nonce: %s", authURL)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	code := fmt.Sprintf("code-%d", len(p.codes)+1)
+	p.codes[code] = oidcGrant{
+		challenge: query.Get("code_challenge"),
+		nonce:     query.Get("nonce"),
+		subject:   subject,
+		email:     email,
+	}
+	return code
+}
+
+// setNonce changes the nonce the ID token for code will carry.
+func (p *oidcProvider) setNonce(code, nonce string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	grant := p.codes[code]
+	grant.nonce = nonce
+	p.codes[code] = grant
+}
+
+func (p *oidcProvider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p.mu.Lock()
+	grant, ok := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	now := time.Now()
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.server.URL,
+		"sub":            grant.subject,
+		"aud":            oidcClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          grant.nonce,
+		"email":          grant.email,
+		"email_verified": true,
+	})
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"access_token": "access-" + grant.subject,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// setupOIDC points the server at a new provider.
+func setupOIDC(t *testing.T) *oidcProvider {
+	t.Helper()
+	provider := newOIDCProvider(t)
+
+	saved := config.Current
+	t.Cleanup(func() { config.Current = saved })
+	config.Current.OIDC.IssuerURL = provider.server.URL
+	config.Current.OIDC.ClientID = oidcClientID
+	config.Current.OIDC.ClientSecret = "secret"
+	config.Current.OIDC.RedirectURL = "http://localhost/auth/oidc/callback"
+	config.Current.OIDC.AutoProvision = true
+	if err := auth.SetupOIDC(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+// startOIDCLogin starts a login and returns the provider URL it redirects
+// to and the flow cookie.
+func startOIDCLogin(t *testing.T, router *gin.Engine) (string, *http.Cookie) {
+	t.Helper()
+	w := serve(router, httptest.NewRequest(http.MethodGet, "/auth/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: got %d, want 302: %s", w.Code, w.Body)
+	}
+	return w.Header().Get("Location"), flowCookie(t, w)
+}
+
+func flowCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "oidc_flow" {
+			return cookie
+		}
+	}
+	t.Fatal("no oidc_flow cookie set")
+	return nil
+}
+
+func callback(router *gin.Engine, code, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/auth/oidc/callback?"+url.Values{"code": {code}, "state": {state}}.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	return serve(router, req)
+}
+
+func stateOf(t *testing.T, authURL string) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query().Get("state")
+}
+
+func tokenOf(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Token string `json:"token"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Token == "" {
+		t.Fatalf("no token in %s", w.Body)
+	}
+	return body.Token
+}
+
+func TestOIDCCallbackProvisionsUser(t *testing.T) {
+	provider := setupOIDC(t)
+	f := fakes.New()
+	router := newRouter(f)
+
+	authURL, cookie := startOIDCLogin(t, router)
+	code := provider.authorize(t, authURL, "subject-1", "ann@example.com")
+	w := callback(router, code, stateOf(t, authURL), cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("callback: got %d, want 200: %s", w.Code, w.Body)
+	}
+
+	claims, err := f.Tokens.VerifyToken(tokenOf(t, w))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := f.Users.Get(context.Background(), claims.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "ann@example.com" || user.OIDCSubject == nil || *user.OIDCSubject != "subject-1" {
+		t.Errorf("provisioned %+v, want ann@example.com linked to subject-1", user)
+	}
+
+	// A second login finds the same user.
+	authURL, cookie = startOIDCLogin(t, router)
+	code = provider.authorize(t, authURL, "subject-1", "ann@example.com")
+	w = callback(router, code, stateOf(t, authURL), cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("second callback: got %d, want 200: %s", w.Code, w.Body)
+	}
+	if claims, _ := f.Tokens.VerifyToken(tokenOf(t, w)); claims == nil || claims.UserID != user.ID {
+		t.Errorf("second login got claims %+v, want user %d", claims, user.ID)
+	}
+}
+
+func TestOIDCCallbackRejectsInvalidFlows(t *testing.T) {
+	provider := setupOIDC(t)
+	router := newRouter(fakes.New())
+
+	tests := []struct {
+		name   string
+		tamper func(code, state *string, cookie *http.Cookie) *http.Cookie
+		want   int
+	}{
+		{
+			name: "state mismatch",
+			tamper: func(code, state *string, cookie *http.Cookie) *http.Cookie {
+				*state = "forged"
+				return cookie
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "missing flow cookie",
+			tamper: func(code, state *string, cookie *http.Cookie) *http.Cookie {
+				return nil
+			},
+			want: http.StatusBadRequest,
+		},
+		{
+			name: "nonce mismatch",
+			tamper: func(code, state *string, cookie *http.Cookie) *http.Cookie {
+				provider.setNonce(*code, "replayed")
+				return cookie
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name: "PKCE verifier mismatch",
+			tamper: func(code, state *string, cookie *http.Cookie) *http.Cookie {
+				parts := strings.Split(cookie.Value, ".")
+				parts[2] = "wrong-verifier-wrong-verifier-wrong-verifier"
+				tampered := *cookie
+				tampered.Value = strings.Join(parts, ".")
+				return &tampered
+			},
+			want: http.StatusUnauthorized,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authURL, cookie := startOIDCLogin(t, router)
+			code := provider.authorize(t, authURL, "subject-1", "ann@example.com")
+			state := stateOf(t, authURL)
+			cookie = tt.tamper(&code, &state, cookie)
+
+			if w := callback(router, code, state, cookie); w.Code != tt.want {
+				t.Errorf("got %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestOIDCCallbackDoesNotLinkByUsername(t *testing.T) {
+	provider := setupOIDC(t)
+	f := fakes.New()
+	router := newRouter(f)
+
+	// Someone registered the victim's email as a username before the victim
+	// first signed in with the provider.
+	squatter := models.User{Username: "victim@example.com", Password: "hash"}
+	if err := f.Users.Create(context.Background(), &squatter); err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, cookie := startOIDCLogin(t, router)
+	code := provider.authorize(t, authURL, "victim-subject", "victim@example.com")
+	w := callback(router, code, stateOf(t, authURL), cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("callback: got %d, want 200: %s", w.Code, w.Body)
+	}
+
+	claims, err := f.Tokens.VerifyToken(tokenOf(t, w))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID == squatter.ID {
+		t.Fatal("the OIDC login was linked to the account with the matching username")
+	}
+	squatter, _ = f.Users.Get(context.Background(), squatter.ID)
+	if squatter.OIDCSubject != nil {
+		t.Errorf("the account with the matching username got linked to %q", *squatter.OIDCSubject)
+	}
+}
+
+func TestOIDCLinkFromSession(t *testing.T) {
+	provider := setupOIDC(t)
+	f := fakes.New()
+	router := newRouter(f)
+
+	user := models.User{Username: "ann", Password: "hash"}
+	if err := f.Users.Create(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	token, _ := f.Tokens.CreateToken(user)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/me/oidc", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := serve(router, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("start link: got %d, want 200: %s", w.Code, w.Body)
+	}
+	var started struct {
+		URL string `json:"url"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &started)
+	cookie := flowCookie(t, w)
+
+	// The token in the cookie only links, it doesn't call the API.
+	values := strings.SplitN(cookie.Value, ".", 4)
+	linkToken := values[3]
+	if w := call(router, linkToken, http.MethodGet, "/api/me", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("link token used as access token: got %d, want 401", w.Code)
+	}
+	// Nor does the callback take an access token for linking.
+	forged := *cookie
+	forged.Value = strings.Join(append(values[:3:3], token), ".")
+	code := provider.authorize(t, started.URL, "ann-subject", "ann@example.com")
+	if w := callback(router, code, stateOf(t, started.URL), &forged); w.Code != http.StatusUnauthorized {
+		t.Errorf("callback with an access token: got %d, want 401: %s", w.Code, w.Body)
+	}
+
+	code = provider.authorize(t, started.URL, "ann-subject", "ann@example.com")
+	w = callback(router, code, stateOf(t, started.URL), cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("callback: got %d, want 200: %s", w.Code, w.Body)
+	}
+	user, _ = f.Users.Get(context.Background(), user.ID)
+	if user.OIDCSubject == nil || *user.OIDCSubject != "ann-subject" {
+		t.Fatalf("user not linked: %+v", user)
+	}
+
+	// Logging in with the identity now finds ann.
+	authURL, cookie := startOIDCLogin(t, router)
+	code = provider.authorize(t, authURL, "ann-subject", "ann@example.com")
+	w = callback(router, code, stateOf(t, authURL), cookie)
+	if claims, _ := f.Tokens.VerifyToken(tokenOf(t, w)); claims == nil || claims.UserID != user.ID {
+		t.Errorf("login got claims %+v, want user %d", claims, user.ID)
+	}
+	if actions := f.Audit.Actions(); len(actions) != 2 || actions[0] != "auth.oidc_link" || actions[1] != "auth.oidc_login" {
+		t.Errorf("audited %v, want a link and a login", actions)
+	}
+}
+
+func TestOIDCLinkRejectsIdentityOfAnotherAccount(t *testing.T) {
+	provider := setupOIDC(t)
+	f := fakes.New()
+	router := newRouter(f)
+
+	issuer, subject := provider.server.URL, "taken-subject"
+	owner := models.User{Username: "owner", OIDCIssuer: &issuer, OIDCSubject: &subject}
+	other := models.User{Username: "other", Password: "hash"}
+	for _, user := range []*models.User{&owner, &other} {
+		if err := f.Users.Create(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	token, _ := f.Tokens.CreateToken(other)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/me/oidc", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := serve(router, req)
+	var started struct {
+		URL string `json:"url"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &started)
+
+	code := provider.authorize(t, started.URL, subject, "owner@example.com")
+	if w := callback(router, code, stateOf(t, started.URL), flowCookie(t, w)); w.Code != http.StatusConflict {
+		t.Errorf("got %d, want 409: %s", w.Code, w.Body)
+	}
+}
+
+func TestOIDCLinkRequiresUserToken(t *testing.T) {
+	setupOIDC(t)
+	router := newRouter(fakes.New())
+
+	if w := serve(router, httptest.NewRequest(http.MethodPost, "/api/me/oidc", nil)); w.Code != http.StatusUnauthorized {
+		t.Errorf("got %d, want 401: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	ocr "github.com/example/golang-postgres-crud/ocr_service"
+	"github.com/example/golang-postgres-crud/preprocess"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+// preprocessSteps returns the steps listed by the preprocess query parameter
+// or form field, or the default steps if there is none. It writes an error
+// response and reports false if a step is unknown.
+func (a *App) preprocessSteps(c *gin.Context) ([]preprocess.Step, bool) {
+	list := c.Query("preprocess")
+	if list == "" {
+		list = c.PostForm("preprocess")
+	}
+	if list == "" {
+		return a.PreprocessSteps, true
+	}
+	steps, err := preprocess.ParseSteps(list)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, fmt.Sprintf("Invalid preprocess parameter: %v", err))
+		return nil, false
+	}
+	return steps, true
+}
+
+// preprocessImage applies steps to the image and returns the PNG to send to
+// OCR instead. It writes an error response and reports false if the image
+// can't be preprocessed.
+func (a *App) preprocessImage(c *gin.Context, image ocr.Image, steps []preprocess.Step) ([]byte, bool) {
+	_, span := tracing.Tracer().Start(c.Request.Context(), "preprocess image")
+	processed, err := preprocess.Process(image.Data, image.Size, steps, a.PreprocessOptions)
+	span.End()
+
+	switch {
+	case errors.Is(err, preprocess.ErrUnreadable):
+		problem.Write(c, http.StatusUnprocessableEntity, problem.InvalidImage, "The image could not be decoded for preprocessing, only JPEG, PNG and GIF are supported")
+	case errors.Is(err, preprocess.ErrTooLarge):
+		problem.Write(c, http.StatusUnprocessableEntity, problem.InvalidImage,
+			fmt.Sprintf("The image has more than %d pixels, too many to preprocess", a.PreprocessOptions.MaxPixels))
+	case err != nil:
+		slog.ErrorContext(c.Request.Context(), "Failed to preprocess image", "error", err)
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to preprocess image")
+	}
+	return processed, err == nil
+}
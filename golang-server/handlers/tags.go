@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/gin-gonic/gin"
+)
+
+type TagInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+func normalizeTagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// normalizeTagNames normalizes the names the way the repositories expect,
+// dropping empty names and repeats.
+func normalizeTagNames(names []string) []string {
+	normalized := make([]string, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = normalizeTagName(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	return normalized
+}
+
+// GetTags godoc
+// @Summary      List tags
+// @Description  Lists all tags, ordered by name.
+// @Tags         tags
+// @Produce      json
+// @Success      200 {array} models.Tag
+// @Router       /api/tags [get]
+func (a *App) GetTags(c *gin.Context) {
+	tags, err := a.Tags.List(c.Request.Context())
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to list tags")
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// CreateTag godoc
+// @Summary      Create a tag
+// @Description  Creates a tag. Names are trimmed and lower-cased.
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Param        input body     TagInput true "Tag name"
+// @Success      201   {object} models.Tag
+// @Failure      400   {object} problem.Problem
+// @Failure      409   {object} problem.Problem
+// @Router       /api/tags [post]
+func (a *App) CreateTag(c *gin.Context) {
+	var input TagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
+		return
+	}
+
+	tag := models.Tag{Name: normalizeTagName(input.Name)}
+	if tag.Name == "" {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Tag name must not be empty")
+		return
+	}
+
+	taken, err := a.Tags.NameTaken(c.Request.Context(), tag.Name, 0)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to check tag names")
+		return
+	}
+	if taken {
+		problem.Write(c, http.StatusConflict, problem.AlreadyExists, "Tag already exists")
+		return
+	}
+
+	if err := a.Tags.Create(c.Request.Context(), &tag); err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to create tag")
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag godoc
+// @Summary      Rename a tag
+// @Description  Renames a tag. Readings keep the tag under its new name.
+// @Tags         tags
+// @Accept       json
+// @Produce      json
+// @Param        id    path     int      true "Tag ID"
+// @Param        input body     TagInput true "New tag name"
+// @Success      200   {object} models.Tag
+// @Failure      400   {object} problem.Problem
+// @Failure      404   {object} problem.Problem
+// @Failure      409   {object} problem.Problem
+// @Router       /api/tags/{id} [put]
+func (a *App) UpdateTag(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	var input TagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
+		return
+	}
+	name := normalizeTagName(input.Name)
+	if name == "" {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Tag name must not be empty")
+		return
+	}
+
+	tag, ok := a.loadTag(c, id)
+	if !ok {
+		return
+	}
+
+	taken, err := a.Tags.NameTaken(c.Request.Context(), name, tag.ID)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to check tag names")
+		return
+	}
+	if taken {
+		problem.Write(c, http.StatusConflict, problem.AlreadyExists, "Tag already exists")
+		return
+	}
+
+	tag.Name = name
+	if err := a.Tags.Update(c.Request.Context(), &tag); err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to update tag")
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag godoc
+// @Summary      Delete a tag
+// @Description  Deletes a tag and removes it from all readings.
+// @Tags         tags
+// @Produce      json
+// @Param        id  path     int true "Tag ID"
+// @Success      200 {object} map[string]string
+// @Failure      404 {object} problem.Problem
+// @Router       /api/tags/{id} [delete]
+func (a *App) DeleteTag(c *gin.Context) {
+	id, ok := idParam(c)
+	if !ok {
+		return
+	}
+
+	err := a.Tags.Delete(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Tag not found")
+		return
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to delete tag")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted"})
+}
+
+// loadTag fetches a tag. It writes an error response and reports false if
+// the tag is unknown.
+func (a *App) loadTag(c *gin.Context, id uint) (models.Tag, bool) {
+	tag, err := a.Tags.Get(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Tag not found")
+		return tag, false
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load tag")
+		return tag, false
+	}
+	return tag, true
+}
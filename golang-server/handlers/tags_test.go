@@ -0,0 +1,59 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+)
+
+func TestTags(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	var tag models.Tag
+	expect(t, call(router, token, http.MethodPost, "/api/tags", `{"name": " Invoices "}`), http.StatusCreated, &tag)
+	if tag.Name != "invoices" {
+		t.Errorf("created tag %q, want it normalized to invoices", tag.Name)
+	}
+	expect(t, call(router, token, http.MethodPost, "/api/tags", `{"name": "INVOICES"}`), http.StatusConflict, nil)
+	expect(t, call(router, token, http.MethodPost, "/api/tags", `{"name": "  "}`), http.StatusBadRequest, nil)
+	expect(t, call(router, token, http.MethodPost, "/api/tags", `{"name": "receipts"}`), http.StatusCreated, nil)
+
+	expect(t, call(router, token, http.MethodPut, "/api/tags/1", `{"name": "receipts"}`), http.StatusConflict, nil)
+	expect(t, call(router, token, http.MethodPut, "/api/tags/1", `{"name": "bills"}`), http.StatusOK, &tag)
+	if tag.Name != "bills" {
+		t.Errorf("renamed tag to %q, want bills", tag.Name)
+	}
+	expect(t, call(router, token, http.MethodPut, "/api/tags/9", `{"name": "other"}`), http.StatusNotFound, nil)
+
+	var tags []models.Tag
+	expect(t, call(router, token, http.MethodGet, "/api/tags", ""), http.StatusOK, &tags)
+	if len(tags) != 2 || tags[0].Name != "bills" || tags[1].Name != "receipts" {
+		t.Errorf("listed %+v, want bills and receipts", tags)
+	}
+
+	expect(t, call(router, token, http.MethodDelete, "/api/tags/1", ""), http.StatusOK, nil)
+	expect(t, call(router, token, http.MethodDelete, "/api/tags/1", ""), http.StatusNotFound, nil)
+}
+
+func TestDeletedTagLeavesReadings(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	reading := models.TextReadings{Tags: []models.Tag{{Name: "draft"}, {Name: "final"}}}
+	if err := f.Readings.Create(context.Background(), &reading); err != nil {
+		t.Fatal(err)
+	}
+	expect(t, call(router, token, http.MethodDelete, "/api/tags/1", ""), http.StatusOK, nil)
+
+	var got models.TextReadings
+	expect(t, call(router, token, http.MethodGet, "/api/text-readings/1", ""), http.StatusOK, &got)
+	if len(got.Tags) != 1 || got.Tags[0].Name != "final" {
+		t.Errorf("reading has tags %+v, want only final", got.Tags)
+	}
+}
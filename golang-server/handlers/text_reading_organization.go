@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/example/golang-postgres-crud/audit"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/gin-gonic/gin"
+)
+
+const maxBulkReadings = 1000
+
+type TextReadingTagsInput struct {
+	Tags []string `json:"tags"`
+}
+
+type BulkTagInput struct {
+	IDs  []uint   `json:"ids" binding:"required"`
+	Tags []string `json:"tags" binding:"required"`
+}
+
+type TextReadingFolderInput struct {
+	FolderID *uint `json:"folderId"`
+}
+
+// loadTextReading fetches the reading named by the :id parameter with its tags
+// and metadata. It writes an error response and reports false if the ID is
+// invalid or unknown.
+func (a *App) loadTextReading(c *gin.Context) (models.TextReadings, bool) {
+	id, ok := idParam(c)
+	if !ok {
+		return models.TextReadings{}, false
+	}
+
+	textReading, err := a.Readings.Get(c.Request.Context(), id)
+	if errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "TextReading not found")
+		return textReading, false
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load text reading")
+		return textReading, false
+	}
+	return textReading, true
+}
+
+// SetTextReadingTags godoc
+// @Summary      Replace a reading's tags
+// @Description  Sets the tags of a text reading. Unknown tag names are created.
+// @Tags         text-readings
+// @Accept       json
+// @Produce      json
+// @Param        id    path     int                  true "Text Reading ID"
+// @Param        input body     TextReadingTagsInput true "Tag names"
+// @Success      200   {object} models.TextReadings
+// @Failure      400   {object} problem.Problem
+// @Failure      404   {object} problem.Problem
+// @Router       /api/text-readings/{id}/tags [put]
+func (a *App) SetTextReadingTags(c *gin.Context) {
+	var input TextReadingTagsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
+		return
+	}
+
+	textReading, ok := a.loadTextReading(c)
+	if !ok {
+		return
+	}
+
+	tags, err := a.Readings.SetTags(c.Request.Context(), textReading.ID, normalizeTagNames(input.Tags))
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to update tags")
+		return
+	}
+	textReading.Tags = tags
+
+	a.Audit.Record(c, audit.Entry{
+		Action:     audit.ActionTextReadingUpdate,
+		TargetType: audit.TargetTextReading,
+		TargetID:   strconv.FormatUint(uint64(textReading.ID), 10),
+		Success:    true,
+		Details:    map[string]interface{}{"tags": input.Tags},
+	})
+
+	c.JSON(http.StatusOK, textReading)
+}
+
+// BulkTagTextReadings godoc
+// @Summary      Tag many readings
+// @Description  Adds the tags to every listed text reading. Unknown tag names are created.
+// @Tags         text-readings
+// @Accept       json
+// @Produce      json
+// @Param        input body     BulkTagInput true "Reading IDs and tag names"
+// @Success      200   {object} map[string]int
+// @Failure      400   {object} problem.Problem
+// @Router       /api/text-readings/bulk/tag [post]
+func (a *App) BulkTagTextReadings(c *gin.Context) {
+	a.bulkUpdateTags(c, true)
+}
+
+// BulkUntagTextReadings godoc
+// @Summary      Untag many readings
+// @Description  Removes the tags from every listed text reading.
+// @Tags         text-readings
+// @Accept       json
+// @Produce      json
+// @Param        input body     BulkTagInput true "Reading IDs and tag names"
+// @Success      200   {object} map[string]int
+// @Failure      400   {object} problem.Problem
+// @Router       /api/text-readings/bulk/untag [post]
+func (a *App) BulkUntagTextReadings(c *gin.Context) {
+	a.bulkUpdateTags(c, false)
+}
+
+func (a *App) bulkUpdateTags(c *gin.Context, add bool) {
+	var input BulkTagInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
+		return
+	}
+	if len(input.IDs) == 0 || len(input.IDs) > maxBulkReadings {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Between 1 and 1000 reading IDs are required")
+		return
+	}
+
+	ctx := c.Request.Context()
+	ids, err := a.Readings.ExistingIDs(ctx, input.IDs)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load text readings")
+		return
+	}
+	if len(ids) == 0 {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "No matching text readings")
+		return
+	}
+
+	names := normalizeTagNames(input.Tags)
+	if add {
+		err = a.Readings.AddTags(ctx, ids, names)
+	} else {
+		err = a.Readings.RemoveTags(ctx, ids, names)
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to update tags")
+		return
+	}
+
+	operation := "tag"
+	if !add {
+		operation = "untag"
+	}
+	for _, id := range ids {
+		a.Audit.Record(c, audit.Entry{
+			Action:     audit.ActionTextReadingUpdate,
+			TargetType: audit.TargetTextReading,
+			TargetID:   strconv.FormatUint(uint64(id), 10),
+			Success:    true,
+			Details:    map[string]interface{}{operation: input.Tags},
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": len(ids)})
+}
+
+// SetTextReadingFolder godoc
+// @Summary      Move a reading to a folder
+// @Description  Puts a text reading into a folder, or back to the top level when folderId is null.
+// @Tags         text-readings
+// @Accept       json
+// @Produce      json
+// @Param        id    path     int                    true "Text Reading ID"
+// @Param        input body     TextReadingFolderInput true "Target folder"
+// @Success      200   {object} models.TextReadings
+// @Failure      400   {object} problem.Problem
+// @Failure      404   {object} problem.Problem
+// @Router       /api/text-readings/{id}/folder [put]
+func (a *App) SetTextReadingFolder(c *gin.Context) {
+	var input TextReadingFolderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
+		return
+	}
+
+	textReading, ok := a.loadTextReading(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if input.FolderID != nil {
+		_, err := a.Folders.Get(ctx, *input.FolderID)
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Folder not found")
+			return
+		}
+		if err != nil {
+			problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load folder")
+			return
+		}
+	}
+
+	if err := a.Readings.SetFolder(ctx, textReading.ID, input.FolderID); err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to move text reading")
+		return
+	}
+	textReading.FolderID = input.FolderID
+
+	a.Audit.Record(c, audit.Entry{
+		Action:     audit.ActionTextReadingUpdate,
+		TargetType: audit.TargetTextReading,
+		TargetID:   strconv.FormatUint(uint64(textReading.ID), 10),
+		Success:    true,
+		Details:    map[string]interface{}{"folderId": input.FolderID},
+	})
+
+	c.JSON(http.StatusOK, textReading)
+}
+
+// GetTextReadingMetadata godoc
+// @Summary      Get a reading's metadata
+// @Description  Returns the free-form key/value metadata of a text reading.
+// @Tags         text-readings
+// @Produce      json
+// @Param        id  path     int true "Text Reading ID"
+// @Success      200 {object} map[string]string
+// @Failure      404 {object} problem.Problem
+// @Router       /api/text-readings/{id}/metadata [get]
+func (a *App) GetTextReadingMetadata(c *gin.Context) {
+	textReading, ok := a.loadTextReading(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, textReading.Metadata)
+}
+
+// UpdateTextReadingMetadata godoc
+// @Summary      Set metadata on a reading
+// @Description  Adds or overwrites the given metadata keys. Keys not in the request are left unchanged.
+// @Tags         text-readings
+// @Accept       json
+// @Produce      json
+// @Param        id    path     int               true "Text Reading ID"
+// @Param        input body     map[string]string true "Metadata to set"
+// @Success      200   {object} map[string]string
+// @Failure      400   {object} problem.Problem
+// @Failure      404   {object} problem.Problem
+// @Router       /api/text-readings/{id}/metadata [put]
+func (a *App) UpdateTextReadingMetadata(c *gin.Context) {
+	var input map[string]string
+	if err := c.ShouldBindJSON(&input); err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Invalid request")
+		return
+	}
+
+	textReading, ok := a.loadTextReading(c)
+	if !ok {
+		return
+	}
+
+	metadata := make(map[string]string, len(input))
+	for key, value := range input {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "Metadata keys must not be empty")
+			return
+		}
+		metadata[key] = value
+	}
+
+	if err := a.Readings.SetMetadata(c.Request.Context(), textReading.ID, metadata); err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to update metadata")
+		return
+	}
+	for key, value := range metadata {
+		textReading.Metadata[key] = value
+	}
+
+	a.Audit.Record(c, audit.Entry{
+		Action:     audit.ActionTextReadingUpdate,
+		TargetType: audit.TargetTextReading,
+		TargetID:   strconv.FormatUint(uint64(textReading.ID), 10),
+		Success:    true,
+		Details:    map[string]interface{}{"metadata": input},
+	})
+
+	c.JSON(http.StatusOK, textReading.Metadata)
+}
+
+// DeleteTextReadingMetadata godoc
+// @Summary      Remove a metadata key
+// @Description  Removes one metadata key from a text reading.
+// @Tags         text-readings
+// @Produce      json
+// @Param        id  path     int    true "Text Reading ID"
+// @Param        key path     string true "Metadata key"
+// @Success      200 {object} map[string]string
+// @Failure      404 {object} problem.Problem
+// @Router       /api/text-readings/{id}/metadata/{key} [delete]
+func (a *App) DeleteTextReadingMetadata(c *gin.Context) {
+	textReading, ok := a.loadTextReading(c)
+	if !ok {
+		return
+	}
+
+	key := c.Param("key")
+	if _, exists := textReading.Metadata[key]; !exists {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Metadata key not found")
+		return
+	}
+
+	if err := a.Readings.DeleteMetadata(c.Request.Context(), textReading.ID, key); err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to delete metadata")
+		return
+	}
+	delete(textReading.Metadata, key)
+
+	a.Audit.Record(c, audit.Entry{
+		Action:     audit.ActionTextReadingUpdate,
+		TargetType: audit.TargetTextReading,
+		TargetID:   strconv.FormatUint(uint64(textReading.ID), 10),
+		Success:    true,
+		Details:    map[string]interface{}{"deletedMetadataKey": key},
+	})
+
+	c.JSON(http.StatusOK, textReading.Metadata)
+}
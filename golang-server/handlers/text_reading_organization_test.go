@@ -0,0 +1,148 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/gin-gonic/gin"
+)
+
+func createReadings(t *testing.T, f *fakes.Fakes, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := f.Readings.Create(context.Background(), &models.TextReadings{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func listReadings(t *testing.T, router *gin.Engine, token, query string) []uint {
+	t.Helper()
+	var readings []models.TextReadings
+	expect(t, call(router, token, http.MethodGet, "/api/text-readings?"+query, ""), http.StatusOK, &readings)
+	ids := make([]uint, len(readings))
+	for i, reading := range readings {
+		ids[i] = reading.ID
+	}
+	return ids
+}
+
+func tagNames(tags []models.Tag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+func TestSetTextReadingTags(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+	createReadings(t, f, 1)
+
+	var reading models.TextReadings
+	expect(t, call(router, token, http.MethodPut, "/api/text-readings/1/tags", `{"tags": ["Draft", "draft", " ", "urgent"]}`), http.StatusOK, &reading)
+	if got := tagNames(reading.Tags); len(got) != 2 || got[0] != "draft" || got[1] != "urgent" {
+		t.Errorf("reading has tags %v, want draft and urgent", got)
+	}
+	expect(t, call(router, token, http.MethodPut, "/api/text-readings/1/tags", `{"tags": ["final"]}`), http.StatusOK, &reading)
+	if got := tagNames(reading.Tags); len(got) != 1 || got[0] != "final" {
+		t.Errorf("reading has tags %v, want final", got)
+	}
+
+	var tags []models.Tag
+	expect(t, call(router, token, http.MethodGet, "/api/tags", ""), http.StatusOK, &tags)
+	if got := tagNames(tags); len(got) != 3 {
+		t.Errorf("tags %v, want draft, final and urgent to stay", got)
+	}
+	expect(t, call(router, token, http.MethodPut, "/api/text-readings/9/tags", `{"tags": ["final"]}`), http.StatusNotFound, nil)
+}
+
+func TestBulkTagTextReadings(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+	createReadings(t, f, 3)
+
+	var result struct{ Updated int }
+	expect(t, call(router, token, http.MethodPost, "/api/text-readings/bulk/tag", `{"ids": [1, 2, 9], "tags": ["Draft", "urgent"]}`), http.StatusOK, &result)
+	if result.Updated != 2 {
+		t.Errorf("tagged %d readings, want 2", result.Updated)
+	}
+	if got := listReadings(t, router, token, "tag=draft&tag=urgent"); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("readings tagged draft and urgent: %v, want 1 and 2", got)
+	}
+
+	expect(t, call(router, token, http.MethodPost, "/api/text-readings/bulk/untag", `{"ids": [2, 3], "tags": ["draft", "unknown"]}`), http.StatusOK, nil)
+	if got := listReadings(t, router, token, "tag=draft"); len(got) != 1 || got[0] != 1 {
+		t.Errorf("readings tagged draft: %v, want 1", got)
+	}
+	if got := listReadings(t, router, token, "tag=urgent"); len(got) != 2 {
+		t.Errorf("readings tagged urgent: %v, want 1 and 2", got)
+	}
+
+	expect(t, call(router, token, http.MethodPost, "/api/text-readings/bulk/tag", `{"ids": [8, 9], "tags": ["draft"]}`), http.StatusNotFound, nil)
+	expect(t, call(router, token, http.MethodPost, "/api/text-readings/bulk/tag", `{"ids": [], "tags": ["draft"]}`), http.StatusBadRequest, nil)
+}
+
+func TestSetTextReadingFolder(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+	createReadings(t, f, 2)
+
+	expect(t, call(router, token, http.MethodPost, "/api/folders", `{"name": "Archive"}`), http.StatusCreated, nil)
+	expect(t, call(router, token, http.MethodPost, "/api/folders", `{"name": "2024", "parentId": 1}`), http.StatusCreated, nil)
+
+	var reading models.TextReadings
+	expect(t, call(router, token, http.MethodPut, "/api/text-readings/1/folder", `{"folderId": 2}`), http.StatusOK, &reading)
+	if reading.FolderID == nil || *reading.FolderID != 2 {
+		t.Errorf("reading is in folder %v, want 2", reading.FolderID)
+	}
+	expect(t, call(router, token, http.MethodPut, "/api/text-readings/2/folder", `{"folderId": 9}`), http.StatusBadRequest, nil)
+
+	if got := listReadings(t, router, token, "folderId=1"); len(got) != 0 {
+		t.Errorf("readings in folder 1: %v, want none", got)
+	}
+	if got := listReadings(t, router, token, "folderId=1&recursive=true"); len(got) != 1 || got[0] != 1 {
+		t.Errorf("readings below folder 1: %v, want 1", got)
+	}
+	if got := listReadings(t, router, token, "folderId=root"); len(got) != 1 || got[0] != 2 {
+		t.Errorf("readings at the top level: %v, want 2", got)
+	}
+}
+
+func TestTextReadingMetadata(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+	createReadings(t, f, 2)
+
+	var metadata map[string]string
+	expect(t, call(router, token, http.MethodPut, "/api/text-readings/1/metadata", `{"author": "Ann", "year": "1999"}`), http.StatusOK, &metadata)
+	expect(t, call(router, token, http.MethodPut, "/api/text-readings/1/metadata", `{"year": "2001"}`), http.StatusOK, &metadata)
+	if len(metadata) != 2 || metadata["author"] != "Ann" || metadata["year"] != "2001" {
+		t.Errorf("metadata %v, want author Ann and year 2001", metadata)
+	}
+	expect(t, call(router, token, http.MethodPut, "/api/text-readings/1/metadata", `{"": "x"}`), http.StatusBadRequest, nil)
+
+	if got := listReadings(t, router, token, "meta=year:2001"); len(got) != 1 || got[0] != 1 {
+		t.Errorf("readings with year 2001: %v, want 1", got)
+	}
+
+	metadata = nil
+	expect(t, call(router, token, http.MethodDelete, "/api/text-readings/1/metadata/author", ""), http.StatusOK, &metadata)
+	if len(metadata) != 1 || metadata["year"] != "2001" {
+		t.Errorf("metadata %v, want only year 2001", metadata)
+	}
+	expect(t, call(router, token, http.MethodDelete, "/api/text-readings/1/metadata/author", ""), http.StatusNotFound, nil)
+	metadata = nil
+	expect(t, call(router, token, http.MethodGet, "/api/text-readings/2/metadata", ""), http.StatusOK, &metadata)
+	if len(metadata) != 0 {
+		t.Errorf("untouched reading has metadata %v", metadata)
+	}
+}
@@ -1,20 +1,26 @@
 package handlers
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
+	"io/fs"
+	"log/slog"
 	"net/http"
-	"os"
 	"path/filepath"
 	"strconv"
-	"time"
+	"strings"
 
-	"github.com/example/golang-postgres-crud/db"
+	"github.com/example/golang-postgres-crud/audit"
+	"github.com/example/golang-postgres-crud/metrics"
 	"github.com/example/golang-postgres-crud/models"
 	ocr "github.com/example/golang-postgres-crud/ocr_service"
+	"github.com/example/golang-postgres-crud/preprocess"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/ratelimit"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/example/golang-postgres-crud/tracing"
 	"github.com/gin-gonic/gin"
 )
 
@@ -25,78 +31,217 @@ import (
 // @Accept       multipart/form-data
 // @Produce      json
 // @Param        file formData file true "Image file to upload (JPEG/PNG)"
+// @Param        engine query string false "OCR engine to use, also accepted as a form field; defaults to the configured engine"
+// @Param        preprocess query string false "Comma-separated preprocessing steps before OCR: orient, downscale, grayscale, deskew, binarize, or none; also accepted as a form field; defaults to the configured steps. The processed image is stored next to the original."
 // @Success      201 {object} models.TextReadings
-// @Failure      400 {object} map[string]string
-// @Failure      500 {object} map[string]string
+// @Failure      400 {object} problem.Problem
+// @Failure      422 {object} problem.Problem
+// @Failure      429 {object} problem.Problem
+// @Failure      500 {object} problem.Problem
 // @Router       /api/text-readings [post]
-func CreateTextReading(c *gin.Context) {
+func (a *App) CreateTextReading(c *gin.Context) {
+	ctx := c.Request.Context()
 
+	// Parsing the form reads the request body, so the span covers the upload.
+	_, readSpan := tracing.Tracer().Start(ctx, "read upload")
 	file, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File not provided"})
+		readSpan.End()
+		if uploadTooLarge(err) {
+			problem.Write(c, http.StatusRequestEntityTooLarge, problem.PayloadTooLarge, "File too large")
+			return
+		}
+		problem.Write(c, http.StatusBadRequest, problem.FileMissing, "File not provided")
+		return
+	}
+	engine, ok := a.ocrEngine(c)
+	if !ok {
+		readSpan.End()
+		return
+	}
+	steps, ok := a.preprocessSteps(c)
+	if !ok {
+		readSpan.End()
 		return
 	}
 
 	src, err := file.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open file"})
+		readSpan.End()
+		problem.Write(c, http.StatusInternalServerError, problem.StorageError, "Failed to open file")
 		return
 	}
 	defer src.Close()
 
-	imageBytes, err := io.ReadAll(src)
+	// Uploads beyond the multipart memory limit are spooled to disk, and
+	// from there they are streamed, never read into memory whole.
+	image, err := ocr.ReadImage(src, file.Size)
+	readSpan.End()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		problem.Write(c, http.StatusInternalServerError, problem.StorageError, "Failed to read file")
 		return
 	}
+	metrics.ObserveUpload("text-readings", file.Size)
 
-	ocrService, err := ocr.NewOcrService()
-	if err != nil {
-		log.Printf("Error connecting to gRPC server: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not connect to OCR server"})
+	consumption, ok := a.consumeQuota(c,
+		ratelimit.Charge{Metric: ratelimit.OCRCalls, Amount: 1},
+		ratelimit.Charge{Metric: ratelimit.UploadBytes, Amount: file.Size},
+	)
+	if !ok {
 		return
 	}
-	defer ocrService.Close()
+	created := false
+	defer func() {
+		if !created {
+			consumption.Refund(ctx)
+		}
+	}()
+
+	// OCR reads the processed image, which is kept next to the original.
+	ocrImage := image
+	var processed []byte
+	if len(steps) > 0 {
+		if processed, ok = a.preprocessImage(c, image, steps); !ok {
+			return
+		}
+		ocrImage = ocr.NewImage(processed)
+	}
 
-	ocrText, err := ocrService.PerformOcr(imageBytes)
+	ocrText, err := engine.PerformOcr(ctx, ocrImage)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not perform OCR operation"})
+		ocrFailed(c, err)
 		return
 	}
 
-	hash := sha256.New()
-	hash.Write(imageBytes)
-	hash.Write([]byte(time.Now().String()))
-	hashString := hex.EncodeToString(hash.Sum(nil))[:5]
-	filename := fmt.Sprintf("%s_%s", hashString, file.Filename)
-	filePath := filepath.Join("static", "images", filename)
-
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+	_, saveSpan := tracing.Tracer().Start(ctx, "save image")
+	filePath, err := a.Storage.Save(file.Filename, image.Reader())
+	if err != nil {
+		saveSpan.End()
+		problem.Write(c, http.StatusInternalServerError, problem.StorageError, "Failed to save file")
 		return
 	}
+	processedPath := ""
+	if processed != nil {
+		processedPath, err = a.Storage.SaveDerivative(filePath, preprocess.Suffix, bytes.NewReader(processed))
+		if err != nil {
+			saveSpan.End()
+			a.removeImages(ctx, "Failed to remove image after a failed save, reconcile will remove it", filePath)
+			problem.Write(c, http.StatusInternalServerError, problem.StorageError, "Failed to save processed file")
+			return
+		}
+	}
+	saveSpan.End()
 
 	textReading := models.TextReadings{
-		FileSize: file.Size,
-		FilePath: filePath,
-		OcrText:  ocrText,
+		FileSize:        file.Size,
+		FilePath:        filePath,
+		OcrText:         ocrText,
+		OcrEngine:       engine.Name(),
+		ContentHash:     image.Hash,
+		OcrStatus:       models.OcrStatusDone,
+		ProcessedPath:   processedPath,
+		PreprocessSteps: preprocess.FormatSteps(steps),
+	}
+
+	if err := a.Readings.Create(ctx, &textReading); err != nil {
+		a.removeImages(ctx, "Failed to remove image after a failed insert, reconcile will remove it", filePath, processedPath)
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to save text reading")
+		return
 	}
 
-	db.DB.Create(&textReading)
+	created = true
+
+	a.Audit.Record(c, audit.Entry{
+		Action:     audit.ActionTextReadingCreate,
+		TargetType: audit.TargetTextReading,
+		TargetID:   strconv.FormatUint(uint64(textReading.ID), 10),
+		Success:    true,
+		Details:    map[string]interface{}{"filename": file.Filename, "fileSize": file.Size, "filePath": filePath},
+	})
 
 	c.JSON(http.StatusCreated, textReading)
 }
 
+// uploadTooLarge reports whether reading an upload failed because the request
+// body exceeded the size limit.
+func uploadTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
+
+// readingFilter parses the list filters: every tag must be present, folderId
+// selects a folder (with its subfolders when recursive=true) or "root", and
+// each meta=key:value pair must match; a bare meta=key only requires the key
+// to exist.
+func readingFilter(c *gin.Context) (repository.ReadingFilter, error) {
+	var filter repository.ReadingFilter
+
+	for _, tag := range c.QueryArray("tag") {
+		filter.Tags = append(filter.Tags, normalizeTagName(tag))
+	}
+
+	switch folderID := c.Query("folderId"); folderID {
+	case "":
+	case "root":
+		filter.RootOnly = true
+	default:
+		id, err := strconv.ParseUint(folderID, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("invalid folderId")
+		}
+		folder := uint(id)
+		filter.FolderID = &folder
+		filter.Recursive = c.Query("recursive") == "true"
+	}
+
+	for _, meta := range c.QueryArray("meta") {
+		key, value, hasValue := strings.Cut(meta, ":")
+		if key == "" {
+			return filter, fmt.Errorf("invalid meta filter %q, expected key or key:value", meta)
+		}
+		filter.Meta = append(filter.Meta, repository.MetaFilter{Key: key, Value: value, HasValue: hasValue})
+	}
+
+	return filter, nil
+}
+
+// removeImages deletes stored images that no reading refers to, logging
+// failures with msg. Empty paths are skipped.
+func (a *App) removeImages(ctx context.Context, msg string, filePaths ...string) {
+	for _, filePath := range filePaths {
+		if filePath == "" {
+			continue
+		}
+		if err := a.Storage.Delete(filePath); err != nil {
+			slog.ErrorContext(ctx, msg, "path", filePath, "error", err)
+		}
+	}
+}
+
 // GetTextReadings godoc
 // @Summary      Get all text readings
-// @Description  Retrieves a list of all text reading records from the database
+// @Description  Retrieves text reading records, optionally filtered by tags, folder and metadata
 // @Tags         text-readings
 // @Produce      json
+// @Param        tag       query  []string  false  "Tag the reading must have (repeatable)"  collectionFormat(multi)
+// @Param        folderId  query  string    false  "Folder ID, or 'root' for readings outside any folder"
+// @Param        recursive query  bool      false  "Include readings in subfolders of folderId"
+// @Param        meta      query  []string  false  "Metadata filter as key or key:value (repeatable)"  collectionFormat(multi)
 // @Success      200 {array} models.TextReadings
+// @Failure      400 {object} problem.Problem
 // @Router       /api/text-readings [get]
-func GetTextReadings(c *gin.Context) {
-	var textReadings []models.TextReadings
-	db.DB.Find(&textReadings)
+func (a *App) GetTextReadings(c *gin.Context) {
+	filter, err := readingFilter(c)
+	if err != nil {
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, err.Error())
+		return
+	}
+
+	textReadings, err := a.Readings.List(c.Request.Context(), filter)
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to list text readings")
+		return
+	}
 	c.JSON(http.StatusOK, textReadings)
 }
 
@@ -107,18 +252,11 @@ func GetTextReadings(c *gin.Context) {
 // @Produce      json
 // @Param        id   path      int  true  "Text Reading ID"
 // @Success      200 {object} models.TextReadings
-// @Failure      404 {object} map[string]string
+// @Failure      404 {object} problem.Problem
 // @Router       /api/text-readings/{id} [get]
-func GetTextReading(c *gin.Context) {
-	var textReading models.TextReadings
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
-		return
-	}
-
-	if err := db.DB.First(&textReading, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "TextReading not found"})
+func (a *App) GetTextReading(c *gin.Context) {
+	textReading, ok := a.loadTextReading(c)
+	if !ok {
 		return
 	}
 	c.JSON(http.StatusOK, textReading)
@@ -133,19 +271,12 @@ func GetTextReading(c *gin.Context) {
 // @Param        id   path      int  true  "Text Reading ID"
 // @Param        input body      object true "The new OcrText data"
 // @Success      200 {object} models.TextReadings
-// @Failure      400 {object} map[string]string
-// @Failure      404 {object} map[string]string
+// @Failure      400 {object} problem.Problem
+// @Failure      404 {object} problem.Problem
 // @Router       /api/text-readings/{id} [put]
-func UpdateTextReading(c *gin.Context) {
-	var textReading models.TextReadings
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
-		return
-	}
-
-	if err := db.DB.First(&textReading, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "TextReading not found"})
+func (a *App) UpdateTextReading(c *gin.Context) {
+	textReading, ok := a.loadTextReading(c)
+	if !ok {
 		return
 	}
 
@@ -153,12 +284,27 @@ func UpdateTextReading(c *gin.Context) {
 		OcrText string `json:"ocrText"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, err.Error())
 		return
 	}
 
 	textReading.OcrText = input.OcrText
-	db.DB.Save(&textReading)
+	err := a.Readings.Update(c.Request.Context(), &textReading)
+	if errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "TextReading not found")
+		return
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to update text reading")
+		return
+	}
+
+	a.Audit.Record(c, audit.Entry{
+		Action:     audit.ActionTextReadingUpdate,
+		TargetType: audit.TargetTextReading,
+		TargetID:   strconv.FormatUint(uint64(textReading.ID), 10),
+		Success:    true,
+	})
 
 	c.JSON(http.StatusOK, textReading)
 }
@@ -170,65 +316,103 @@ func UpdateTextReading(c *gin.Context) {
 // @Produce      json
 // @Param        id   path      int  true  "Text Reading ID"
 // @Success      200 {object} map[string]string
-// @Failure      404 {object} map[string]string
-// @Failure      500 {object} map[string]string
+// @Failure      404 {object} problem.Problem
+// @Failure      500 {object} problem.Problem
 // @Router       /api/text-readings/{id} [delete]
-func DeleteTextReading(c *gin.Context) {
-	var textReading models.TextReadings
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+func (a *App) DeleteTextReading(c *gin.Context) {
+	textReading, ok := a.loadTextReading(c)
+	if !ok {
 		return
 	}
 
-	if err := db.DB.First(&textReading, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "TextReading not found"})
+	ctx := c.Request.Context()
+
+	// The image goes to the trash before the row is deleted, so a failed
+	// delete can put it back; it is only purged once the row is gone.
+	trashed := true
+	if err := a.Storage.Trash(textReading.FilePath); errors.Is(err, fs.ErrNotExist) {
+		trashed = false
+	} else if err != nil {
+		slog.ErrorContext(ctx, "Failed to move image to the trash", "path", textReading.FilePath, "error", err)
+		problem.Write(c, http.StatusInternalServerError, problem.StorageError, "Failed to delete associated file")
 		return
 	}
 
-	if _, err := os.Stat(textReading.FilePath); err == nil {
-		if err := os.Remove(textReading.FilePath); err != nil {
-			log.Printf("Failed to delete file %s: %v", textReading.FilePath, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete associated file"})
-			return
+	err := a.Readings.Delete(ctx, textReading.ID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		if trashed {
+			if restoreErr := a.Storage.Restore(textReading.FilePath); restoreErr != nil {
+				slog.ErrorContext(ctx, "Failed to restore image after a failed delete", "path", textReading.FilePath, "error", restoreErr)
+			}
 		}
-	} else if !os.IsNotExist(err) {
-		log.Printf("Failed to check file existence %s: %v", textReading.FilePath, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check file existence"})
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to delete text reading")
 		return
 	}
 
-	db.DB.Delete(&textReading)
+	// The row is gone either way, possibly deleted concurrently, so the
+	// image must not stay behind.
+	if trashed {
+		if purgeErr := a.Storage.Purge(textReading.FilePath); purgeErr != nil {
+			slog.WarnContext(ctx, "Failed to purge deleted image, reconcile will remove it", "path", textReading.FilePath, "error", purgeErr)
+		}
+	}
+	// The processed image can be made again, so it needs no trash.
+	if textReading.ProcessedPath != "" {
+		if removeErr := a.Storage.Delete(textReading.ProcessedPath); removeErr != nil {
+			slog.WarnContext(ctx, "Failed to remove deleted processed image, reconcile will remove it", "path", textReading.ProcessedPath, "error", removeErr)
+		}
+	}
+	if err != nil {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "TextReading not found")
+		return
+	}
+
+	a.Audit.Record(c, audit.Entry{
+		Action:     audit.ActionTextReadingDelete,
+		TargetType: audit.TargetTextReading,
+		TargetID:   strconv.FormatUint(uint64(textReading.ID), 10),
+		Success:    true,
+		Details:    map[string]interface{}{"filePath": textReading.FilePath},
+	})
 
 	c.JSON(http.StatusOK, gin.H{"message": "TextReading and associated file deleted"})
 }
 
 // GetTextReadingImage godoc
 // @Summary      Get image by text reading ID
-// @Description  Retrieves the image file associated with a text reading record.
+// @Description  Retrieves the image file associated with a text reading record, or with processed=true the preprocessed image OCR read.
 // @Tags         text-readings
 // @Produce      image/jpeg
 // @Param        id   path      int  true  "Text Reading ID"
+// @Param        processed query bool false "Return the preprocessed image instead of the original"
 // @Success      200 {file} file
-// @Failure      404 {object} map[string]string
+// @Failure      404 {object} problem.Problem
 // @Router       /api/text-readings/{id}/image [get]
-func GetTextReadingImage(c *gin.Context) {
-	var textReading models.TextReadings
-	id, err := strconv.Atoi(c.Param("id"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+func (a *App) GetTextReadingImage(c *gin.Context) {
+	textReading, ok := a.loadTextReading(c)
+	if !ok {
 		return
 	}
 
-	if err := db.DB.First(&textReading, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "TextReading not found"})
-		return
+	filePath := textReading.FilePath
+	if processed, _ := strconv.ParseBool(c.Query("processed")); processed {
+		if textReading.ProcessedPath == "" {
+			problem.Write(c, http.StatusNotFound, problem.NotFound, "Text reading has no processed image")
+			return
+		}
+		filePath = textReading.ProcessedPath
 	}
 
-	if _, err := os.Stat(textReading.FilePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Image file not found"})
+	image, err := a.Storage.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		problem.Write(c, http.StatusNotFound, problem.NotFound, "Image file not found")
+		return
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.StorageError, "Failed to open image file")
 		return
 	}
+	defer image.Close()
 
-	c.File(textReading.FilePath)
+	http.ServeContent(c.Writer, c.Request, filepath.Base(filePath), textReading.UpdatedAt, image)
 }
@@ -0,0 +1,172 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/audit"
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/ratelimit"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/example/golang-postgres-crud/routes"
+	"github.com/gin-gonic/gin"
+)
+
+var errDatabase = errors.New("database is gone")
+
+// failingReadings fails to create, delete and list readings, like a
+// database that went away mid-request.
+type failingReadings struct {
+	*fakes.Readings
+}
+
+func (failingReadings) Create(ctx context.Context, reading *models.TextReadings) error {
+	return errDatabase
+}
+
+func (failingReadings) Delete(ctx context.Context, id uint) error {
+	return errDatabase
+}
+
+func (failingReadings) ListBatches(ctx context.Context, filter repository.ReadingFilter, size int, fn func([]models.TextReadings) error) error {
+	return errDatabase
+}
+
+// routerWithFailingReadings serves the fakes with a readings repository
+// that fails.
+func routerWithFailingReadings(f *fakes.Fakes) *gin.Engine {
+	app := f.App()
+	app.Readings = failingReadings{f.Readings}
+	return routes.SetupRouter(app)
+}
+
+func upload(router *gin.Engine, token, filename, content string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", filename)
+	part.Write([]byte(content))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/text-readings", &body)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return serve(router, req)
+}
+
+// ocrCallsUsed returns how many OCR calls the caller's daily quota counted.
+func ocrCallsUsed(t *testing.T, router *gin.Engine, token string) int64 {
+	t.Helper()
+	var usage struct{ Quotas []ratelimit.Usage }
+	expect(t, call(router, token, http.MethodGet, "/api/usage", ""), http.StatusOK, &usage)
+	for _, quota := range usage.Quotas {
+		if quota.Metric == ratelimit.OCRCalls && quota.Period == ratelimit.Daily {
+			return quota.Used
+		}
+	}
+	t.Fatalf("no daily OCR quota in %+v", usage.Quotas)
+	return 0
+}
+
+func TestCreateTextReading(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	var reading models.TextReadings
+	expect(t, upload(router, token, "scan.png", "image"), http.StatusCreated, &reading)
+	if reading.OcrText != "recognized text" || reading.OcrEngine != "fake" || reading.FileSize != 5 || reading.ContentHash == "" {
+		t.Errorf("created %+v", reading)
+	}
+	if files := f.Storage.Files(); len(files) != 1 || files[0] != reading.FilePath {
+		t.Errorf("stored %v, want %s", files, reading.FilePath)
+	}
+	if len(f.OCR.Images) != 1 || string(f.OCR.Images[0]) != "image" {
+		t.Errorf("OCR was given %q, want the upload", f.OCR.Images)
+	}
+	if got := f.Audit.Actions(); len(got) != 1 || got[0] != audit.ActionTextReadingCreate {
+		t.Errorf("audited %v, want %s", got, audit.ActionTextReadingCreate)
+	}
+	if used := ocrCallsUsed(t, router, token); used != 1 {
+		t.Errorf("quota counted %d OCR calls, want 1", used)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/text-readings", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	expect(t, serve(router, req), http.StatusBadRequest, nil)
+}
+
+func TestCreateTextReadingRefundsFailedOCR(t *testing.T) {
+	f := fakes.New()
+	f.OCR.Err = errors.New("OCR server crashed")
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	expect(t, upload(router, token, "scan.png", "image"), http.StatusBadGateway, nil)
+	if files := f.Storage.Files(); len(files) != 0 {
+		t.Errorf("stored %v after OCR failed", files)
+	}
+	if count, _ := f.Readings.Count(context.Background(), repository.ReadingFilter{}); count != 0 {
+		t.Errorf("created %d readings after OCR failed", count)
+	}
+	if used := ocrCallsUsed(t, router, token); used != 0 {
+		t.Errorf("quota counted %d OCR calls after OCR failed, want the call refunded", used)
+	}
+}
+
+func TestCreateTextReadingRemovesImageWhenInsertFails(t *testing.T) {
+	f := fakes.New()
+	router := routerWithFailingReadings(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	expect(t, upload(router, token, "scan.png", "image"), http.StatusInternalServerError, nil)
+	if files := f.Storage.Files(); len(files) != 0 {
+		t.Errorf("stored %v after the insert failed", files)
+	}
+}
+
+func TestDeleteTextReading(t *testing.T) {
+	f := fakes.New()
+	router := newRouter(f)
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	var reading models.TextReadings
+	expect(t, upload(router, token, "scan.png", "image"), http.StatusCreated, &reading)
+	processed, _ := f.Storage.SaveDerivative(reading.FilePath, ".processed.png", bytes.NewReader([]byte("processed")))
+	reading.ProcessedPath = processed
+	if err := f.Readings.Update(context.Background(), &reading); err != nil {
+		t.Fatal(err)
+	}
+
+	expect(t, call(router, token, http.MethodDelete, "/api/text-readings/1", ""), http.StatusOK, nil)
+	if files := f.Storage.Files(); len(files) != 0 {
+		t.Errorf("left %v behind", files)
+	}
+	if trash, _ := f.Storage.ListTrash(); len(trash) != 0 {
+		t.Errorf("left %v in the trash", trash)
+	}
+	expect(t, call(router, token, http.MethodGet, "/api/text-readings/1", ""), http.StatusNotFound, nil)
+	expect(t, call(router, token, http.MethodDelete, "/api/text-readings/1", ""), http.StatusNotFound, nil)
+}
+
+func TestDeleteTextReadingRestoresImageWhenDeleteFails(t *testing.T) {
+	f := fakes.New()
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	var reading models.TextReadings
+	expect(t, upload(newRouter(f), token, "scan.png", "image"), http.StatusCreated, &reading)
+
+	expect(t, call(routerWithFailingReadings(f), token, http.MethodDelete, "/api/text-readings/1", ""), http.StatusInternalServerError, nil)
+	if files := f.Storage.Files(); len(files) != 1 || files[0] != reading.FilePath {
+		t.Errorf("stored %v, want the image back at %s", files, reading.FilePath)
+	}
+	if trash, _ := f.Storage.ListTrash(); len(trash) != 0 {
+		t.Errorf("left %v in the trash", trash)
+	}
+	expect(t, call(newRouter(f), token, http.MethodGet, "/api/text-readings/1/image", ""), http.StatusOK, nil)
+}
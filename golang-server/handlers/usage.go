@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/example/golang-postgres-crud/metrics"
+	"github.com/example/golang-postgres-crud/middleware"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+type UsageResponse struct {
+	Quotas []ratelimit.Usage `json:"quotas"`
+}
+
+// GetUsage godoc
+// @Summary      Get quota usage
+// @Description  Returns how much of each daily and monthly quota the caller's account has used. A limit of 0 means the metric is not capped.
+// @Tags         me
+// @Produce      json
+// @Success      200 {object} UsageResponse
+// @Failure      401 {object} problem.Problem
+// @Failure      500 {object} problem.Problem
+// @Router       /api/usage [get]
+func (a *App) GetUsage(c *gin.Context) {
+	usage, err := a.Quotas.Usage(c.Request.Context(), middleware.CurrentClaims(c).UserID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to load quota usage", "error", err)
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to load usage")
+		return
+	}
+	c.JSON(http.StatusOK, UsageResponse{Quotas: usage})
+}
+
+// consumeQuota charges the caller's quotas. It writes an error response and
+// reports false if a quota is exceeded or can't be checked. The caller
+// refunds the consumption if the work it paid for fails.
+func (a *App) consumeQuota(c *gin.Context, charges ...ratelimit.Charge) (*ratelimit.Consumption, bool) {
+	consumption, err := a.Quotas.Consume(c.Request.Context(), middleware.CurrentClaims(c).UserID, charges...)
+	var exceeded *ratelimit.QuotaError
+	if errors.As(err, &exceeded) {
+		metrics.QuotaExceeded(string(exceeded.Metric), string(exceeded.Period))
+		retryAfter := int(math.Ceil(time.Until(exceeded.ResetsAt).Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		p := problem.New(http.StatusTooManyRequests, problem.QuotaExceeded, quotaMessage(exceeded))
+		p.Quota = &problem.Quota{
+			Metric:   string(exceeded.Metric),
+			Period:   string(exceeded.Period),
+			Limit:    exceeded.Limit,
+			ResetsAt: exceeded.ResetsAt,
+		}
+		problem.Respond(c, p)
+		return nil, false
+	}
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "Failed to check quota", "error", err)
+		problem.Write(c, http.StatusInternalServerError, problem.InternalError, "Failed to check quota")
+		return nil, false
+	}
+	return consumption, true
+}
+
+func quotaMessage(e *ratelimit.QuotaError) string {
+	unit := "OCR calls"
+	if e.Metric == ratelimit.UploadBytes {
+		unit = "upload bytes"
+	}
+	return fmt.Sprintf("This request would exceed the %s quota of %d %s, which resets at %s",
+		e.Period, e.Limit, unit, e.ResetsAt.Format(time.RFC3339))
+}
@@ -1,11 +1,23 @@
 package handlers
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
-	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/example/golang-postgres-crud/metrics"
+	"github.com/example/golang-postgres-crud/middleware"
 	ocr "github.com/example/golang-postgres-crud/ocr_service"
+	"github.com/example/golang-postgres-crud/preprocess"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/ratelimit"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
@@ -16,30 +28,57 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func TextReadingWebSocketHandler(c *gin.Context) {
+func (a *App) TextReadingWebSocketHandler(c *gin.Context) {
 	tokenString := c.Query("token")
 	if tokenString == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "JWT token not provided"})
+		problem.Write(c, http.StatusUnauthorized, problem.Unauthenticated, "JWT token not provided")
 		return
 	}
 
-	if err := auth.VerifyToken(tokenString); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid JWT token", "details": err.Error()})
+	claims, err := a.Tokens.VerifyToken(tokenString)
+	if err != nil {
+		slog.DebugContext(c.Request.Context(), "Rejected WebSocket JWT", "error", err)
+		problem.Write(c, http.StatusUnauthorized, problem.InvalidToken, "Invalid JWT token")
+		return
+	}
+
+	engine, ok := a.ocrEngine(c)
+	if !ok {
+		return
+	}
+	steps, ok := a.preprocessSteps(c)
+	if !ok {
+		return
+	}
+
+	if a.webSockets.isClosing() {
+		problem.Write(c, http.StatusServiceUnavailable, problem.ShuttingDown, "Server is shutting down")
 		return
 	}
 
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Println("upgrade failed:", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": "WebSocket upgrade failed"})
+		slog.WarnContext(c.Request.Context(), "WebSocket upgrade failed", "error", err)
+		problem.Write(c, http.StatusBadRequest, problem.InvalidRequest, "WebSocket upgrade failed")
 		return
 	}
 	defer conn.Close()
+	if !a.webSockets.add(conn) {
+		conn.WriteControl(websocket.CloseMessage, shutdownCloseMessage, time.Now().Add(time.Second))
+		return
+	}
+	defer a.webSockets.remove(conn)
+	metrics.WebSocketOpened()
+	defer metrics.WebSocketClosed()
+
+	if config.Current.Limits.MaxBodyBytes > 0 {
+		conn.SetReadLimit(config.Current.Limits.MaxBodyBytes)
+	}
 
 	for {
 		messageType, p, err := conn.ReadMessage()
 		if err != nil {
-			log.Println("read failed:", err)
+			slog.DebugContext(c.Request.Context(), "WebSocket read failed", "error", err)
 			break
 		}
 
@@ -47,25 +86,161 @@ func TextReadingWebSocketHandler(c *gin.Context) {
 			conn.WriteMessage(websocket.TextMessage, []byte("Only binary data (images) is supported."))
 			continue
 		}
+		metrics.ObserveUpload("websocket", int64(len(p)))
 
-		ocrService, err := ocr.NewOcrService()
+		if refusal := a.admitWebSocketOCR(c.Request.Context(), claims.UserID); refusal != "" {
+			conn.WriteMessage(websocket.TextMessage, []byte(refusal))
+			continue
+		}
+		consumption, err := a.Quotas.Consume(c.Request.Context(), claims.UserID, ratelimit.Charge{Metric: ratelimit.OCRCalls, Amount: 1})
+		var exceeded *ratelimit.QuotaError
+		if errors.As(err, &exceeded) {
+			metrics.QuotaExceeded(string(exceeded.Metric), string(exceeded.Period))
+			conn.WriteMessage(websocket.TextMessage, []byte(quotaMessage(exceeded)))
+			continue
+		}
 		if err != nil {
-			log.Printf("Error connecting to gRPC server: %v", err)
-			conn.WriteMessage(websocket.TextMessage, []byte("Could not connect to OCR server"))
+			slog.ErrorContext(c.Request.Context(), "Failed to check quota", "error", err)
+			conn.WriteMessage(websocket.TextMessage, []byte("Could not perform OCR operation"))
 			return
 		}
-		defer ocrService.Close()
 
-		ocrText, err := ocrService.PerformOcr(p)
+		image := ocr.NewImage(p)
+		if len(steps) > 0 {
+			processed, err := preprocess.Process(image.Data, image.Size, steps, a.PreprocessOptions)
+			if err != nil {
+				consumption.Refund(c.Request.Context())
+				slog.DebugContext(c.Request.Context(), "Failed to preprocess WebSocket image", "error", err)
+				conn.WriteMessage(websocket.TextMessage, []byte("Could not preprocess the image."))
+				continue
+			}
+			image = ocr.NewImage(processed)
+		}
+
+		ocrText, err := engine.PerformOcr(c.Request.Context(), image)
 		if err != nil {
-			conn.WriteMessage(websocket.TextMessage, []byte("Could not perform OCR operation"))
-			return
+			consumption.Refund(c.Request.Context())
+			// The request context ends when the client goes away or the
+			// server shuts down; any other failure is the OCR service's,
+			// and the client may send the next image.
+			if c.Request.Context().Err() != nil {
+				return
+			}
+			conn.WriteMessage(websocket.TextMessage, []byte(ocrFailureMessage(err)))
+			continue
 		}
 
 		err = conn.WriteMessage(websocket.TextMessage, []byte(ocrText))
 		if err != nil {
-			log.Println("write failed:", err)
+			slog.WarnContext(c.Request.Context(), "WebSocket write failed", "error", err)
 			break
 		}
 	}
 }
+
+// ocrFailureMessage describes a failed OCR call the way ocrFailed does, with
+// the Retry-After hint in the text.
+func ocrFailureMessage(err error) string {
+	failure := classifyOCRError(err)
+	if failure.retryAfter > 0 {
+		return fmt.Sprintf("%s (retry after %ds)", failure.detail, retryAfterSeconds(failure.retryAfter))
+	}
+	return failure.detail
+}
+
+// admitWebSocketOCR takes a token from the OCR rate limit of the user for an
+// image sent over a WebSocket, which the HTTP middleware never sees. It
+// returns the message to send back instead of the text when the limit is
+// reached.
+func (a *App) admitWebSocketOCR(ctx context.Context, userID uint) string {
+	rule := middleware.OCRRule(a.OCRLimit)
+	if !rule.Limit.Enabled() {
+		return ""
+	}
+	result, err := a.RateLimits.Take(ctx, rule.Bucket(strconv.FormatUint(uint64(userID), 10)), rule.Limit)
+	if err != nil {
+		slog.ErrorContext(ctx, "Rate limiter failed, letting the request through", "limit", rule.Name, "error", err)
+		return ""
+	}
+	if result.Allowed {
+		return ""
+	}
+	metrics.RateLimited(rule.Name)
+	return fmt.Sprintf("Rate limit exceeded, retry in %ds", int(math.Ceil(result.RetryAfter.Seconds())))
+}
+
+var shutdownCloseMessage = websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+
+// webSockets tracks the open WebSocket connections, which http.Server's
+// Shutdown does not wait for once they are hijacked. The zero value is ready
+// to use.
+type webSockets struct {
+	mu      sync.Mutex
+	conns   map[*websocket.Conn]struct{}
+	closing bool
+	active  sync.WaitGroup
+}
+
+func (w *webSockets) add(conn *websocket.Conn) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closing {
+		return false
+	}
+	if w.conns == nil {
+		w.conns = make(map[*websocket.Conn]struct{})
+	}
+	w.conns[conn] = struct{}{}
+	w.active.Add(1)
+	return true
+}
+
+func (w *webSockets) remove(conn *websocket.Conn) {
+	w.mu.Lock()
+	delete(w.conns, conn)
+	w.mu.Unlock()
+	w.active.Done()
+}
+
+func (w *webSockets) isClosing() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.closing
+}
+
+// CloseWebSockets refuses new WebSocket connections and sends every open one
+// a close frame, then waits for their handlers to finish. Connections still
+// open when ctx is done are dropped.
+func (a *App) CloseWebSockets(ctx context.Context) error {
+	w := &a.webSockets
+	w.mu.Lock()
+	w.closing = true
+	conns := make([]*websocket.Conn, 0, len(w.conns))
+	for conn := range w.conns {
+		conns = append(conns, conn)
+	}
+	w.mu.Unlock()
+
+	deadline := time.Now().Add(time.Second)
+	for _, conn := range conns {
+		conn.WriteControl(websocket.CloseMessage, shutdownCloseMessage, deadline)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.active.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		w.mu.Lock()
+		for conn := range w.conns {
+			conn.Close()
+		}
+		w.mu.Unlock()
+		return ctx.Err()
+	}
+}
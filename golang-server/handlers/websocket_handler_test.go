@@ -0,0 +1,124 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/routes"
+	"github.com/gorilla/websocket"
+)
+
+func dialOCR(t *testing.T, server *httptest.Server, token string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/text-readings?token=" + token
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	}
+	return conn, resp, err
+}
+
+// exchange sends a message and returns the text of the answer.
+func exchange(t *testing.T, conn *websocket.Conn, messageType int, data string) string {
+	t.Helper()
+	if err := conn.WriteMessage(messageType, []byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	_, answer, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(answer)
+}
+
+func TestWebSocketOCR(t *testing.T) {
+	f := fakes.New()
+	server := httptest.NewServer(newRouter(f))
+	defer server.Close()
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	for _, token := range []string{"", "unknown"} {
+		_, resp, err := dialOCR(t, server, token)
+		if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: got %v, want 401", token, resp)
+		}
+	}
+
+	conn, _, err := dialOCR(t, server, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := exchange(t, conn, websocket.BinaryMessage, "image"); got != "recognized text" {
+		t.Errorf("got %q, want the OCR text", got)
+	}
+	if got := exchange(t, conn, websocket.TextMessage, "hello"); !strings.Contains(got, "Only binary data") {
+		t.Errorf("text message: got %q", got)
+	}
+	if got := exchange(t, conn, websocket.BinaryMessage, "second image"); got != "recognized text" {
+		t.Errorf("second image: got %q, want the OCR text", got)
+	}
+}
+
+func TestWebSocketReportsOCRFailures(t *testing.T) {
+	f := fakes.New()
+	f.OCR.Err = errors.New("OCR server crashed")
+	server := httptest.NewServer(newRouter(f))
+	defer server.Close()
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	conn, _, err := dialOCR(t, server, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The connection stays open for the next image.
+	for i := 0; i < 2; i++ {
+		if got := exchange(t, conn, websocket.BinaryMessage, "image"); got != "Could not perform OCR operation" {
+			t.Errorf("image %d: got %q, want the failure", i, got)
+		}
+	}
+}
+
+func TestCloseWebSockets(t *testing.T) {
+	f := fakes.New()
+	app := f.App()
+	server := httptest.NewServer(routes.SetupRouter(app))
+	defer server.Close()
+	token := signIn(t, f, "ann", models.RoleUser)
+
+	conn, _, err := dialOCR(t, server, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := exchange(t, conn, websocket.BinaryMessage, "image"); got != "recognized text" {
+		t.Fatalf("got %q, want the OCR text", got)
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		closed <- app.CloseWebSockets(ctx)
+	}()
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("got %v, want a going away close frame", err)
+	}
+	// The handler finishes once the client closes too.
+	conn.Close()
+	if err := <-closed; err != nil {
+		t.Errorf("CloseWebSockets: %v", err)
+	}
+
+	_, resp, err := dialOCR(t, server, token)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("connecting while shutting down: got %v, want 503", resp)
+	}
+}
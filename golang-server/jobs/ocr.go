@@ -0,0 +1,201 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/example/golang-postgres-crud/models"
+	ocr "github.com/example/golang-postgres-crud/ocr_service"
+	"github.com/example/golang-postgres-crud/preprocess"
+	"github.com/example/golang-postgres-crud/repository"
+	"github.com/example/golang-postgres-crud/storage"
+	"github.com/example/golang-postgres-crud/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// OCRWorker is what the OCR worker runs queued OCR with.
+type OCRWorker struct {
+	Engine ocr.OCREngine
+	Queue  repository.OCRQueue
+	// Storage holds the images of the readings, and receives the
+	// preprocessed ones.
+	Storage storage.Storage
+
+	// PreprocessSteps are applied to images before OCR, limited by
+	// PreprocessOptions.
+	PreprocessSteps   []preprocess.Step
+	PreprocessOptions preprocess.Options
+	// MaxAttempts is how often OCR of a reading is tried before it is
+	// marked failed.
+	MaxAttempts int
+}
+
+// StartOCRWorker runs the OCR queued in the worker's queue in the background
+// until ctx is cancelled. Readings left in the processing state by a
+// previous run are queued again first. The returned channel is closed once
+// the worker has stopped, after finishing the reading it was working on.
+func StartOCRWorker(ctx context.Context, interval time.Duration, worker OCRWorker) <-chan struct{} {
+	if err := worker.Queue.RequeueInterrupted(ctx); err != nil {
+		slog.ErrorContext(ctx, "Failed to requeue interrupted OCR jobs", "error", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := ProcessPendingOCR(ctx, worker); err != nil {
+				slog.ErrorContext(ctx, "OCR worker pass failed", "error", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return done
+}
+
+// ProcessPendingOCR performs OCR for queued readings until the queue is empty
+// and returns how many were handled. Readings are claimed from the queue, so
+// several workers can share it. A reading that fails and is queued again
+// waits for the next pass. Cancelling ctx stops the pass after the current
+// reading; that reading is still finished. An open circuit breaker ends the
+// pass too, putting the reading back as it was.
+func ProcessPendingOCR(ctx context.Context, worker OCRWorker) (int, error) {
+	jobCtx := context.WithoutCancel(ctx)
+	processed := 0
+	var lastID uint
+	for ctx.Err() == nil {
+		reading, err := worker.Queue.Claim(jobCtx, lastID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return processed, nil
+		}
+		if err != nil {
+			return processed, err
+		}
+
+		if err := worker.runOCR(jobCtx, reading); err != nil {
+			return processed, err
+		}
+		lastID = reading.ID
+		processed++
+	}
+	return processed, nil
+}
+
+// runOCR performs OCR for a claimed reading and stores the outcome. It only
+// returns an error if the engine's circuit breaker is open, having put the
+// reading back in the queue.
+func (w OCRWorker) runOCR(ctx context.Context, reading models.TextReadings) error {
+	ctx, span := tracing.Tracer().Start(ctx, "ocr job", trace.WithAttributes(attribute.Int64("reading.id", int64(reading.ID))))
+	defer span.End()
+
+	file, image, err := w.openImage(reading.FilePath)
+	if err != nil {
+		// A missing image will not come back by retrying.
+		w.finishReading(ctx, reading, repository.OCRResult{}, err, true)
+		return nil
+	}
+	defer file.Close()
+
+	var result repository.OCRResult
+	if len(w.PreprocessSteps) > 0 {
+		result.PreprocessSteps = preprocess.FormatSteps(w.PreprocessSteps)
+		result.ProcessedPath, image, err = w.preprocessImage(ctx, reading, image)
+		if err != nil {
+			// An image that can't be decoded won't be decoded on retry.
+			permanent := errors.Is(err, preprocess.ErrUnreadable) || errors.Is(err, preprocess.ErrTooLarge)
+			w.finishReading(ctx, reading, repository.OCRResult{}, err, permanent)
+			return nil
+		}
+	}
+
+	result.Text, err = w.Engine.PerformOcr(ctx, image)
+	var open *ocr.CircuitOpenError
+	if errors.As(err, &open) {
+		// The OCR servers were not even asked, so the attempt doesn't count.
+		if err := w.Queue.Release(ctx, reading.ID, err, result); err != nil {
+			slog.ErrorContext(ctx, "Failed to requeue text reading", "reading_id", reading.ID, "error", err)
+		}
+		return err
+	}
+	result.Engine = w.Engine.Name()
+	w.finishReading(ctx, reading, result, err, false)
+	return nil
+}
+
+// preprocessImage applies the preprocessing steps to the image of a reading
+// and stores the result next to it, returning its path and the image to OCR
+// instead.
+func (w OCRWorker) preprocessImage(ctx context.Context, reading models.TextReadings, image ocr.Image) (string, ocr.Image, error) {
+	_, span := tracing.Tracer().Start(ctx, "preprocess image")
+	defer span.End()
+
+	processed, err := preprocess.Process(image.Data, image.Size, w.PreprocessSteps, w.PreprocessOptions)
+	if err != nil {
+		return "", ocr.Image{}, err
+	}
+	processedPath, err := w.Storage.SaveDerivative(reading.FilePath, preprocess.Suffix, bytes.NewReader(processed))
+	if err != nil {
+		return "", ocr.Image{}, err
+	}
+	return processedPath, ocr.NewImage(processed), nil
+}
+
+// openImage opens the image of a reading for OCR. An image the storage can
+// read at any offset, like a file, is streamed from it; others are read into
+// memory. The caller closes the returned file.
+func (w OCRWorker) openImage(filePath string) (io.Closer, ocr.Image, error) {
+	file, err := w.Storage.Open(filePath)
+	if err != nil {
+		return nil, ocr.Image{}, err
+	}
+	image, err := readImage(file)
+	if err != nil {
+		file.Close()
+		return nil, ocr.Image{}, err
+	}
+	return file, image, nil
+}
+
+func readImage(file io.ReadSeeker) (ocr.Image, error) {
+	if data, ok := file.(io.ReaderAt); ok {
+		size, err := file.Seek(0, io.SeekEnd)
+		if err != nil {
+			return ocr.Image{}, err
+		}
+		return ocr.ReadImage(data, size)
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return ocr.Image{}, err
+	}
+	return ocr.NewImage(data), nil
+}
+
+// finishReading stores the result of OCR, or the failure, for a claimed
+// reading. A failed reading is queued again until it runs out of attempts.
+func (w OCRWorker) finishReading(ctx context.Context, reading models.TextReadings, result repository.OCRResult, ocrErr error, permanent bool) {
+	var err error
+	if ocrErr == nil {
+		err = w.Queue.Complete(ctx, reading.ID, result)
+	} else {
+		status := models.OcrStatusPending
+		if permanent || reading.OcrAttempts >= w.MaxAttempts {
+			status = models.OcrStatusFailed
+		}
+		slog.WarnContext(ctx, "OCR failed for text reading", "reading_id", reading.ID, "attempt", reading.OcrAttempts, "status", status, "error", ocrErr)
+		err = w.Queue.Fail(ctx, reading.ID, status, ocrErr, result)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to store OCR result for text reading", "reading_id", reading.ID, "error", err)
+	}
+}
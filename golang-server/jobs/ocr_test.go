@@ -0,0 +1,138 @@
+package jobs_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/jobs"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/preprocess"
+)
+
+func newWorker(f *fakes.Fakes) jobs.OCRWorker {
+	return jobs.OCRWorker{Engine: f.OCR, Queue: f.Readings, Storage: f.Storage, MaxAttempts: 3}
+}
+
+// saveImage stores an image for a reading and returns its path.
+func saveImage(t *testing.T, f *fakes.Fakes, content string) string {
+	t.Helper()
+	filePath, err := f.Storage.Save("scan.png", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func queueReading(t *testing.T, f *fakes.Fakes, filePath string) uint {
+	t.Helper()
+	reading := models.TextReadings{FilePath: filePath, OcrStatus: models.OcrStatusPending}
+	if err := f.Readings.Create(context.Background(), &reading); err != nil {
+		t.Fatal(err)
+	}
+	return reading.ID
+}
+
+func TestProcessPendingOCR(t *testing.T) {
+	ctx := context.Background()
+	f := fakes.New()
+	done := queueReading(t, f, saveImage(t, f, "image"))
+	missing := queueReading(t, f, "memory/gone.png")
+
+	processed, err := jobs.ProcessPendingOCR(ctx, newWorker(f))
+	if err != nil || processed != 2 {
+		t.Fatalf("processed %d readings, error %v; want 2 and no error", processed, err)
+	}
+
+	reading, _ := f.Readings.Get(ctx, done)
+	if reading.OcrStatus != models.OcrStatusDone || reading.OcrText != "recognized text" || reading.OcrEngine != "fake" {
+		t.Errorf("reading with image: status %q, text %q, engine %q", reading.OcrStatus, reading.OcrText, reading.OcrEngine)
+	}
+	if len(f.OCR.Images) != 1 || string(f.OCR.Images[0]) != "image" {
+		t.Errorf("OCR was given %q, want the stored image", f.OCR.Images)
+	}
+	// A missing image won't come back, so it isn't retried.
+	reading, _ = f.Readings.Get(ctx, missing)
+	if reading.OcrStatus != models.OcrStatusFailed || reading.OcrError == "" {
+		t.Errorf("reading without image: status %q, error %q; want failed with the cause", reading.OcrStatus, reading.OcrError)
+	}
+}
+
+func TestProcessPendingOCRRetriesFailures(t *testing.T) {
+	ctx := context.Background()
+	f := fakes.New()
+	f.OCR.Err = errors.New("OCR server unavailable")
+	id := queueReading(t, f, saveImage(t, f, "image"))
+
+	// Each pass makes one attempt; the reading waits for the next pass.
+	for attempt := 1; ; attempt++ {
+		if _, err := jobs.ProcessPendingOCR(ctx, newWorker(f)); err != nil {
+			t.Fatal(err)
+		}
+		reading, _ := f.Readings.Get(ctx, id)
+		if reading.OcrStatus == models.OcrStatusFailed {
+			if attempt != 3 {
+				t.Errorf("failed after %d attempts, want 3", attempt)
+			}
+			break
+		}
+		if reading.OcrStatus != models.OcrStatusPending || attempt >= 3 {
+			t.Fatalf("attempt %d left status %q", attempt, reading.OcrStatus)
+		}
+	}
+
+	depth, _ := f.Readings.Depth(ctx)
+	if depth[models.OcrStatusFailed] != 1 || depth[models.OcrStatusPending] != 0 {
+		t.Errorf("queue depth %v, want one failed reading", depth)
+	}
+}
+
+func TestProcessPendingOCRPreprocesses(t *testing.T) {
+	ctx := context.Background()
+	f := fakes.New()
+	var scan bytes.Buffer
+	if err := png.Encode(&scan, image.NewRGBA(image.Rect(0, 0, 30, 20))); err != nil {
+		t.Fatal(err)
+	}
+	filePath := saveImage(t, f, scan.String())
+	id := queueReading(t, f, filePath)
+	unreadable := queueReading(t, f, saveImage(t, f, "not an image"))
+
+	worker := newWorker(f)
+	worker.PreprocessSteps = []preprocess.Step{preprocess.Grayscale}
+	worker.PreprocessOptions = preprocess.Options{MaxDimension: 100, MaxPixels: 1000}
+	if _, err := jobs.ProcessPendingOCR(ctx, worker); err != nil {
+		t.Fatal(err)
+	}
+
+	reading, _ := f.Readings.Get(ctx, id)
+	if reading.OcrStatus != models.OcrStatusDone || reading.ProcessedPath != "memory/1_scan.processed.png" {
+		t.Fatalf("status %q, processed path %q; want done with the derivative", reading.OcrStatus, reading.ProcessedPath)
+	}
+	file, err := f.Storage.Open(reading.ProcessedPath)
+	if err != nil {
+		t.Fatalf("derivative not in storage: %v", err)
+	}
+	defer file.Close()
+	derivative, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := derivative.(*image.Gray); !ok {
+		t.Errorf("derivative is %T, want a gray image", derivative)
+	}
+	if len(f.OCR.Images) != 1 || bytes.Equal(f.OCR.Images[0], scan.Bytes()) {
+		t.Error("OCR was not given the derivative")
+	}
+
+	// Decoding won't succeed on a retry either.
+	reading, _ = f.Readings.Get(ctx, unreadable)
+	if reading.OcrStatus != models.OcrStatusFailed || reading.OcrAttempts != 1 {
+		t.Errorf("unreadable image: status %q after %d attempts, want failed after 1", reading.OcrStatus, reading.OcrAttempts)
+	}
+}
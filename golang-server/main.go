@@ -1,9 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/cli"
 	"github.com/example/golang-postgres-crud/config"
 	"github.com/example/golang-postgres-crud/db"
+	"github.com/example/golang-postgres-crud/handlers"
+	"github.com/example/golang-postgres-crud/jobs"
+	"github.com/example/golang-postgres-crud/logging"
+	"github.com/example/golang-postgres-crud/metrics"
+	ocr "github.com/example/golang-postgres-crud/ocr_service"
+	"github.com/example/golang-postgres-crud/repository"
 	"github.com/example/golang-postgres-crud/routes"
+	"github.com/example/golang-postgres-crud/storage"
+	"github.com/example/golang-postgres-crud/tracing"
 	"github.com/joho/godotenv"
 )
 
@@ -23,8 +45,175 @@ import (
 // @BasePath /
 func main() {
 	godotenv.Load()
-	config.LoadConfig()
+	args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := logging.Setup(os.Stderr, config.Current.Log.Level, config.Current.Log.Format); err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+
+	command := "serve"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "serve":
+		serve()
+	case "import":
+		db.ConnectDatabase()
+		db.EnsureSchema()
+		if err := cli.RunImport(repository.NewGorm(db.DB), storage.NewDisk(config.Current.Storage.ImageDir), args); err != nil {
+			log.Fatalf("Import failed: %v", err)
+		}
+	case "reconcile":
+		db.ConnectDatabase()
+		db.EnsureSchema()
+		if err := cli.RunReconcile(repository.NewGorm(db.DB), storage.NewDisk(config.Current.Storage.ImageDir), args); err != nil {
+			log.Fatalf("Reconcile failed: %v", err)
+		}
+	case "promote":
+		db.ConnectDatabase()
+		db.EnsureSchema()
+		if err := cli.RunPromote(repository.NewGorm(db.DB), args); err != nil {
+			log.Fatalf("Promote failed: %v", err)
+		}
+	case "migrate":
+		db.ConnectDatabase()
+		if err := cli.RunMigrate(args); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+	case "config":
+		if err := config.Print(os.Stdout); err != nil {
+			log.Fatalf("Failed to print configuration: %v", err)
+		}
+	default:
+		log.Fatalf("Unknown command %q, expected serve, import, reconcile, promote, migrate or config", command)
+	}
+}
+
+func serve() {
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
+
+	if err := auth.LoadKeys(); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+	if config.Current.Auth.KeysReloadInterval > 0 {
+		auth.ReloadKeysEvery(config.Current.Auth.KeysReloadInterval)
+	}
+	if err := auth.SetupOIDC(context.Background()); err != nil {
+		log.Fatalf("Failed to set up OIDC login: %v", err)
+	}
 	db.ConnectDatabase()
-	router := routes.SetupRouter()
-	router.Run()
+	db.EnsureSchema()
+	sqlDB, err := db.DB.DB()
+	if err != nil {
+		log.Fatalf("Failed to get database connection pool: %v", err)
+	}
+	metrics.RegisterDB(sqlDB)
+	repos := repository.NewGorm(db.DB)
+	metrics.RegisterQueueDepth(func() (map[string]int64, error) {
+		return repos.OCRQueue.Depth(context.Background())
+	})
+
+	ocrEngines, err := ocr.OpenEngines()
+	if err != nil {
+		log.Fatalf("Failed to set up OCR engines: %v", err)
+	}
+	app := handlers.NewApp(db.DB, ocrEngines)
+
+	// The worker shares the default engine with the handlers, and with it
+	// the connections and the circuit breaker.
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	defer stopWorker()
+	var workerDone <-chan struct{}
+	if config.Current.OCR.WorkerInterval > 0 {
+		workerDone = jobs.StartOCRWorker(workerCtx, config.Current.OCR.WorkerInterval, jobs.OCRWorker{
+			Engine:            ocrEngines.Default(),
+			Queue:             repos.OCRQueue,
+			Storage:           app.Storage,
+			PreprocessSteps:   app.PreprocessSteps,
+			PreprocessOptions: app.PreprocessOptions,
+			MaxAttempts:       config.Current.OCR.MaxAttempts,
+		})
+	}
+	server := &http.Server{
+		Addr:              config.Current.HTTP.Addr,
+		Handler:           routes.SetupRouter(app),
+		ReadHeaderTimeout: config.Current.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       config.Current.HTTP.ReadTimeout,
+		WriteTimeout:      config.Current.HTTP.WriteTimeout,
+		IdleTimeout:       config.Current.HTTP.IdleTimeout,
+		MaxHeaderBytes:    config.Current.Limits.MaxHeaderBytes,
+	}
+
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Listening", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-serverErr:
+		log.Fatalf("HTTP server failed: %v", err)
+	case <-signalCtx.Done():
+	}
+	// A second signal kills the process right away.
+	stopSignals()
+
+	slog.Info("Shutting down", "timeout", config.Current.HTTP.ShutdownTimeout.String())
+	ctx, cancel := context.WithTimeout(context.Background(), config.Current.HTTP.ShutdownTimeout)
+	defer cancel()
+	drain(ctx, server, app, stopWorker, workerDone)
+
+	ocrEngines.Close()
+	if err := sqlDB.Close(); err != nil {
+		slog.Error("Failed to close database", "error", err)
+	}
+	slog.Info("Shutdown complete")
+}
+
+// drain stops accepting connections and waits, until ctx is done, for
+// in-flight requests, WebSocket sessions and the current OCR job to finish.
+func drain(ctx context.Context, server *http.Server, app *handlers.App, stopWorker func(), workerDone <-chan struct{}) {
+	stopWorker()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Requests still in flight at shutdown timeout", "error", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := app.CloseWebSockets(ctx); err != nil {
+			slog.Error("WebSockets still open at shutdown timeout", "error", err)
+		}
+	}()
+	wg.Wait()
+
+	if workerDone != nil {
+		select {
+		case <-workerDone:
+		case <-ctx.Done():
+			slog.Error("OCR job still running at shutdown timeout")
+		}
+	}
 }
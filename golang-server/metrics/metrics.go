@@ -0,0 +1,191 @@
+// Package metrics defines the Prometheus metrics the server exposes on
+// /metrics.
+package metrics
+
+import (
+	"database/sql"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "Duration of HTTP requests by method, route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	ocrDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ocr_request_duration_seconds",
+		Help:    "Duration of OCR calls by engine and gRPC status code.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+	}, []string{"engine", "code"})
+
+	ocrErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocr_errors_total",
+		Help: "Failed OCR calls by engine and gRPC status code.",
+	}, []string{"engine", "code"})
+
+	ocrCircuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ocr_circuit_state",
+		Help: "State of the OCR circuit breaker by engine: 0 closed, 1 open, 2 half open.",
+	}, []string{"engine"})
+
+	ocrCircuitRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocr_circuit_rejected_total",
+		Help: "OCR calls failed at once by an open circuit breaker, by engine.",
+	}, []string{"engine"})
+
+	ocrCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ocr_cache_lookups_total",
+		Help: "OCR cache lookups by result: memory_hit, database_hit or miss.",
+	}, []string{"result"})
+
+	ocrCacheEntries = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ocr_cache_entries",
+		Help: "OCR results cached in memory.",
+	})
+
+	ocrCacheBytes = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ocr_cache_bytes",
+		Help: "Size of the OCR results cached in memory.",
+	})
+
+	uploadSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "upload_size_bytes",
+		Help:    "Size of uploaded images by endpoint.",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 10),
+	}, []string{"source"})
+
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limited_requests_total",
+		Help: "Requests rejected by a rate limit, by limit.",
+	}, []string{"limit"})
+
+	quotaExceeded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "quota_exceeded_total",
+		Help: "Requests rejected by a usage quota, by metric and period.",
+	}, []string{"metric", "period"})
+
+	websocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "websocket_connections",
+		Help: "Open WebSocket connections.",
+	})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+// Middleware records the duration of every request. Requests are labelled
+// with their route pattern rather than the path, so IDs don't create new
+// series; requests no route matched share the "unmatched" label.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveOCR records an OCR call to the named engine. code is the gRPC status
+// code of the call, "OK" when it succeeded.
+func ObserveOCR(engine, code string, duration time.Duration) {
+	ocrDuration.WithLabelValues(engine, code).Observe(duration.Seconds())
+	if code != "OK" {
+		ocrErrors.WithLabelValues(engine, code).Inc()
+	}
+}
+
+// SetOCRCircuitState reports the state of an engine's circuit breaker.
+func SetOCRCircuitState(engine string, state int) {
+	ocrCircuitState.WithLabelValues(engine).Set(float64(state))
+}
+
+// OCRCircuitRejected counts a call rejected by an open circuit breaker.
+func OCRCircuitRejected(engine string) {
+	ocrCircuitRejected.WithLabelValues(engine).Inc()
+}
+
+// ObserveOCRCache records an OCR cache lookup.
+func ObserveOCRCache(result string) {
+	ocrCacheLookups.WithLabelValues(result).Inc()
+}
+
+// SetOCRCacheSize reports what the OCR cache holds in memory.
+func SetOCRCacheSize(entries int, bytes int64) {
+	ocrCacheEntries.Set(float64(entries))
+	ocrCacheBytes.Set(float64(bytes))
+}
+
+// ObserveUpload records the size of an uploaded image. source names the
+// endpoint it was uploaded through.
+func ObserveUpload(source string, size int64) {
+	uploadSize.WithLabelValues(source).Observe(float64(size))
+}
+
+// RateLimited records a request rejected by the named rate limit.
+func RateLimited(limit string) {
+	rateLimited.WithLabelValues(limit).Inc()
+}
+
+// QuotaExceeded records a request rejected by a usage quota.
+func QuotaExceeded(metric, period string) {
+	quotaExceeded.WithLabelValues(metric, period).Inc()
+}
+
+// WebSocketOpened and WebSocketClosed track the open WebSocket connections.
+func WebSocketOpened() { websocketConnections.Inc() }
+func WebSocketClosed() { websocketConnections.Dec() }
+
+// RegisterDB exports the connection pool statistics of the database.
+func RegisterDB(database *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(database, "main"))
+}
+
+// RegisterQueueDepth exports the number of readings in each OCR status,
+// counted by count whenever metrics are scraped.
+func RegisterQueueDepth(count func() (map[string]int64, error)) {
+	prometheus.MustRegister(&queueCollector{count: count})
+}
+
+var queueDepthDesc = prometheus.NewDesc(
+	"ocr_queue_depth",
+	"Text readings by OCR status.",
+	[]string{"status"}, nil,
+)
+
+type queueCollector struct {
+	count func() (map[string]int64, error)
+}
+
+func (q *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+func (q *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := q.count()
+	if err != nil {
+		slog.Error("Failed to count the OCR queue", "error", err)
+		ch <- prometheus.NewInvalidMetric(queueDepthDesc, err)
+		return
+	}
+	for status, n := range counts {
+		ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(n), status)
+	}
+}
@@ -1,37 +1,198 @@
 package middleware
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/repository"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+const (
+	ContextClaims     = "claims"
+	ContextAuthMethod = "authMethod"
+	ContextScopes     = "scopes"
+	ContextAPIKeyID   = "apiKeyID"
+
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// lastUsedResolution limits how often the last-used timestamp of an API key is
+// written, so busy clients don't turn every request into a row update.
+const lastUsedResolution = time.Minute
+
+// Authenticator resolves the caller of a request from a JWT or an API key.
+type Authenticator struct {
+	Tokens  auth.TokenService
+	APIKeys repository.APIKeyRepository
+	Users   repository.UserRepository
+}
+
+func AuthMiddleware(authenticator Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			authenticator.authenticateAPIKey(c, apiKey)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
-			c.Abort()
+			problem.Write(c, http.StatusUnauthorized, problem.Unauthenticated, "Authorization header is missing")
 			return
 		}
 
 		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || strings.ToLower(tokenParts[0]) != "bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
+		if len(tokenParts) != 2 {
+			problem.Write(c, http.StatusUnauthorized, problem.InvalidToken, "Invalid authorization header format")
 			return
 		}
 
-		tokenString := tokenParts[1]
+		switch strings.ToLower(tokenParts[0]) {
+		case "bearer":
+			authenticator.authenticateJWT(c, tokenParts[1])
+		case "apikey":
+			authenticator.authenticateAPIKey(c, tokenParts[1])
+		default:
+			problem.Write(c, http.StatusUnauthorized, problem.InvalidToken, "Invalid authorization header format")
+		}
+	}
+}
+
+func (a Authenticator) authenticateJWT(c *gin.Context, tokenString string) {
+	claims, err := a.Tokens.VerifyToken(tokenString)
+	if err != nil {
+		slog.DebugContext(c.Request.Context(), "Rejected JWT", "error", err)
+		problem.Write(c, http.StatusUnauthorized, problem.InvalidToken, "Invalid or expired token")
+		return
+	}
+
+	c.Set(ContextClaims, claims)
+	c.Set(ContextAuthMethod, AuthMethodJWT)
+
+	c.Next()
+}
+
+func (a Authenticator) authenticateAPIKey(c *gin.Context, key string) {
+	prefix, ok := auth.ParseAPIKey(key)
+	if !ok {
+		problem.Write(c, http.StatusUnauthorized, problem.InvalidAPIKey, "Invalid API key")
+		return
+	}
+
+	ctx := c.Request.Context()
+	apiKey, err := a.APIKeys.GetByPrefix(ctx, prefix)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load API key")
+		return
+	}
+	if err != nil || !auth.CompareAPIKey(key, apiKey.KeyHash) {
+		problem.Write(c, http.StatusUnauthorized, problem.InvalidAPIKey, "Invalid API key")
+		return
+	}
+
+	now := time.Now()
+	if apiKey.RevokedAt != nil || (apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now)) {
+		problem.Write(c, http.StatusUnauthorized, problem.InvalidAPIKey, "API key is revoked or expired")
+		return
+	}
 
-		if err := auth.VerifyToken(tokenString); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token", "details": err.Error()})
-			c.Abort()
+	user, err := a.Users.Get(ctx, apiKey.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		problem.Write(c, http.StatusUnauthorized, problem.InvalidAPIKey, "Invalid API key")
+		return
+	}
+	if err != nil {
+		problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load user")
+		return
+	}
+
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= lastUsedResolution {
+		if err := a.APIKeys.TouchLastUsed(ctx, apiKey.ID, now); err != nil {
+			slog.ErrorContext(ctx, "Failed to update last use of API key", "api_key_id", apiKey.ID, "error", err)
+		}
+	}
+
+	c.Set(ContextClaims, &auth.Claims{
+		UserID:   user.ID,
+		Username: user.Username,
+		Roles:    []string{user.Role},
+		StandardClaims: jwt.StandardClaims{
+			Subject: strconv.FormatUint(uint64(user.ID), 10),
+		},
+	})
+	c.Set(ContextAuthMethod, AuthMethodAPIKey)
+	c.Set(ContextScopes, strings.Fields(apiKey.Scopes))
+	c.Set(ContextAPIKeyID, apiKey.ID)
+
+	c.Next()
+}
+
+// CurrentClaims returns the claims of the authenticated caller. It is only
+// valid behind AuthMiddleware.
+func CurrentClaims(c *gin.Context) *auth.Claims {
+	claims, _ := c.MustGet(ContextClaims).(*auth.Claims)
+	return claims
+}
+
+// RequireScope restricts a route to API keys carrying the given scope. Requests
+// authenticated with a user's JWT are not limited by scopes.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(ContextAuthMethod) != AuthMethodAPIKey {
+			c.Next()
 			return
 		}
 
+		for _, s := range c.GetStringSlice(ContextScopes) {
+			if s == scope {
+				c.Next()
+				return
+			}
+		}
+
+		p := problem.New(http.StatusForbidden, problem.InsufficientScope, "API key is missing the required scope")
+		p.Scope = scope
+		problem.Respond(c, p)
+	}
+}
+
+// RequireJWT rejects requests that were not authenticated with a user's JWT.
+func RequireJWT() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(ContextAuthMethod) != AuthMethodJWT {
+			problem.Write(c, http.StatusForbidden, problem.UserTokenRequired, "This endpoint requires a user token")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole rejects callers who do not have the given role. The role is
+// looked up in users rather than taken from the claims, so promoting or
+// demoting an account takes effect on tokens issued before the change.
+func RequireRole(users repository.UserRepository, role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := users.Get(c.Request.Context(), CurrentClaims(c).UserID)
+		if errors.Is(err, repository.ErrNotFound) {
+			problem.Write(c, http.StatusUnauthorized, problem.InvalidToken, "User no longer exists")
+			return
+		}
+		if err != nil {
+			problem.Write(c, http.StatusInternalServerError, problem.DatabaseError, "Failed to load user")
+			return
+		}
+		if user.Role != role {
+			problem.Write(c, http.StatusForbidden, problem.Forbidden, "Insufficient permissions")
+			return
+		}
 		c.Next()
 	}
 }
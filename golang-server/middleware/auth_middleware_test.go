@@ -0,0 +1,207 @@
+package middleware_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/fakes"
+	"github.com/example/golang-postgres-crud/middleware"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// newAuthRouter serves a route per middleware, each answering with the
+// caller's username.
+func newAuthRouter(f *fakes.Fakes) *gin.Engine {
+	router := gin.New()
+	api := router.Group("/", middleware.AuthMiddleware(middleware.Authenticator{
+		Tokens:  f.Tokens,
+		APIKeys: f.APIKeys,
+		Users:   f.Users,
+	}))
+	whoami := func(c *gin.Context) {
+		c.String(http.StatusOK, middleware.CurrentClaims(c).Username)
+	}
+	api.GET("/any", whoami)
+	api.GET("/read", middleware.RequireScope(auth.ScopeTextReadingsRead), whoami)
+	api.GET("/jwt", middleware.RequireJWT(), whoami)
+	api.GET("/admin", middleware.RequireRole(f.Users, models.RoleAdmin), whoami)
+	return router
+}
+
+func createUser(t *testing.T, f *fakes.Fakes, username, role string) models.User {
+	t.Helper()
+	user := models.User{Username: username, Password: "hash", Role: role}
+	if err := f.Users.Create(context.Background(), &user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func createAPIKey(t *testing.T, f *fakes.Fakes, apiKey models.APIKey) string {
+	t.Helper()
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKey.Prefix, apiKey.KeyHash = prefix, hash
+	if err := f.APIKeys.Create(context.Background(), &apiKey); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	f := fakes.New()
+	router := newAuthRouter(f)
+	ann := createUser(t, f, "ann", models.RoleUser)
+	token, _ := f.Tokens.CreateToken(ann)
+	linkToken, _ := f.Tokens.CreateLinkToken(ann)
+	key := createAPIKey(t, f, models.APIKey{UserID: ann.ID, Scopes: auth.ScopeOCR})
+	past := time.Now().Add(-time.Hour)
+	expired := createAPIKey(t, f, models.APIKey{UserID: ann.ID, Scopes: auth.ScopeOCR, ExpiresAt: &past})
+	revoked := createAPIKey(t, f, models.APIKey{UserID: ann.ID, Scopes: auth.ScopeOCR, RevokedAt: &past})
+	orphaned := createAPIKey(t, f, models.APIKey{UserID: 99, Scopes: auth.ScopeOCR})
+
+	tests := []struct {
+		name   string
+		header string
+		value  string
+		want   int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"bearer token", "Authorization", "Bearer " + token, http.StatusOK},
+		{"lowercase scheme", "Authorization", "bearer " + token, http.StatusOK},
+		{"unknown token", "Authorization", "Bearer nope", http.StatusUnauthorized},
+		{"link token", "Authorization", "Bearer " + linkToken, http.StatusUnauthorized},
+		{"malformed header", "Authorization", "Bearer", http.StatusUnauthorized},
+		{"unknown scheme", "Authorization", "Basic " + token, http.StatusUnauthorized},
+		{"X-API-Key", "X-API-Key", key, http.StatusOK},
+		{"ApiKey scheme", "Authorization", "ApiKey " + key, http.StatusOK},
+		{"mangled key", "X-API-Key", key + "x", http.StatusUnauthorized},
+		{"not a key", "X-API-Key", "hello", http.StatusUnauthorized},
+		{"expired key", "X-API-Key", expired, http.StatusUnauthorized},
+		{"revoked key", "X-API-Key", revoked, http.StatusUnauthorized},
+		{"key of a deleted user", "X-API-Key", orphaned, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/any", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("got %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusOK && w.Body.String() != "ann" {
+				t.Errorf("authenticated as %q, want ann", w.Body)
+			}
+		})
+	}
+
+	stored, _ := f.APIKeys.GetByPrefix(context.Background(), strings.Split(key, "_")[1])
+	if stored.LastUsedAt == nil {
+		t.Error("using the key did not record its last use")
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	f := fakes.New()
+	router := newAuthRouter(f)
+	ann := createUser(t, f, "ann", models.RoleUser)
+	root := createUser(t, f, "root", models.RoleAdmin)
+	annToken, _ := f.Tokens.CreateToken(ann)
+	rootToken, _ := f.Tokens.CreateToken(root)
+	readKey := createAPIKey(t, f, models.APIKey{UserID: ann.ID, Scopes: auth.ScopeTextReadingsRead + " " + auth.ScopeOCR})
+	ocrKey := createAPIKey(t, f, models.APIKey{UserID: ann.ID, Scopes: auth.ScopeOCR})
+	adminKey := createAPIKey(t, f, models.APIKey{UserID: root.ID, Scopes: auth.ScopeOCR})
+
+	tests := []struct {
+		path   string
+		header string
+		value  string
+		want   int
+	}{
+		// Scopes only limit API keys.
+		{"/read", "Authorization", "Bearer " + annToken, http.StatusOK},
+		{"/read", "X-API-Key", readKey, http.StatusOK},
+		{"/read", "X-API-Key", ocrKey, http.StatusForbidden},
+		{"/jwt", "Authorization", "Bearer " + annToken, http.StatusOK},
+		{"/jwt", "X-API-Key", readKey, http.StatusForbidden},
+		{"/admin", "Authorization", "Bearer " + rootToken, http.StatusOK},
+		{"/admin", "Authorization", "Bearer " + annToken, http.StatusForbidden},
+		{"/admin", "X-API-Key", adminKey, http.StatusOK},
+		{"/admin", "X-API-Key", readKey, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Header.Set(tt.header, tt.value)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s with %s %.20s: got %d, want %d: %s", tt.path, tt.header, tt.value, w.Code, tt.want, w.Body)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/read", nil)
+	req.Header.Set("X-API-Key", ocrKey)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !strings.Contains(w.Body.String(), `"scope":"text-readings:read"`) {
+		t.Errorf("missing scope not reported: %s", w.Body)
+	}
+}
+
+func TestRequireRoleUsesStoredRole(t *testing.T) {
+	f := fakes.New()
+	router := newAuthRouter(f)
+	ann := createUser(t, f, "ann", models.RoleUser)
+	root := createUser(t, f, "root", models.RoleAdmin)
+	annToken, _ := f.Tokens.CreateToken(ann)
+	rootToken, _ := f.Tokens.CreateToken(root)
+	ghost := models.User{Username: "ghost", Role: models.RoleAdmin}
+	ghost.ID = 99
+	ghostToken, _ := f.Tokens.CreateToken(ghost)
+
+	ann.Role, root.Role = models.RoleAdmin, models.RoleUser
+	for _, user := range []models.User{ann, root} {
+		if err := f.Users.Update(context.Background(), &user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"promoted", annToken, http.StatusOK},
+		{"demoted", rootToken, http.StatusForbidden},
+		{"deleted", ghostToken, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s user: got %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+	}
+}
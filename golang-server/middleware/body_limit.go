@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimit rejects request bodies larger than limit bytes. A body that
+// announces a larger Content-Length is refused right away; any other body
+// fails with *http.MaxBytesError once the limit is read past. A limit of 0
+// or less disables the check.
+func BodyLimit(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 {
+			return
+		}
+		if c.Request.ContentLength > limit {
+			problem.Write(c, http.StatusRequestEntityTooLarge, problem.PayloadTooLarge, "Request body too large")
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/example/golang-postgres-crud/metrics"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// contextRateLimit holds the most restrictive rate limit result of the
+// request so far, which the RateLimit-* headers describe.
+const contextRateLimit = "rateLimit"
+
+// RateLimitRule is a rate limit and whose bucket a request draws from.
+type RateLimitRule struct {
+	// Name labels the rule in bucket keys and metrics.
+	Name  string
+	Limit ratelimit.Limit
+	// Subject returns whom the request is counted for, such as the client
+	// IP or the user ID, or "" if the rule doesn't apply to it.
+	Subject func(c *gin.Context) string
+}
+
+// Bucket returns the key of the bucket of subject.
+func (r RateLimitRule) Bucket(subject string) string {
+	return r.Name + ":" + subject
+}
+
+// IPRule limits requests per client IP.
+func IPRule(limit ratelimit.Limit) RateLimitRule {
+	return RateLimitRule{Name: "ip", Limit: limit, Subject: func(c *gin.Context) string {
+		return c.ClientIP()
+	}}
+}
+
+// UserRule limits requests per user token. It is only valid behind
+// AuthMiddleware and leaves requests made with an API key alone.
+func UserRule(limit ratelimit.Limit) RateLimitRule {
+	return RateLimitRule{Name: "user", Limit: limit, Subject: func(c *gin.Context) string {
+		if c.GetString(ContextAuthMethod) != AuthMethodJWT {
+			return ""
+		}
+		return strconv.FormatUint(uint64(CurrentClaims(c).UserID), 10)
+	}}
+}
+
+// APIKeyRule limits requests per API key. It is only valid behind
+// AuthMiddleware and leaves requests made with a user token alone.
+func APIKeyRule(limit ratelimit.Limit) RateLimitRule {
+	return RateLimitRule{Name: "apiKey", Limit: limit, Subject: func(c *gin.Context) string {
+		if c.GetString(ContextAuthMethod) != AuthMethodAPIKey {
+			return ""
+		}
+		return strconv.FormatUint(uint64(c.GetUint(ContextAPIKeyID)), 10)
+	}}
+}
+
+// OCRRule limits OCR calls per user, however the user authenticated. It is
+// only valid behind AuthMiddleware.
+func OCRRule(limit ratelimit.Limit) RateLimitRule {
+	return RateLimitRule{Name: "ocr", Limit: limit, Subject: func(c *gin.Context) string {
+		return strconv.FormatUint(uint64(CurrentClaims(c).UserID), 10)
+	}}
+}
+
+// RateLimit takes a token for every rule that applies to the request and
+// rejects it with 429 once a bucket is empty. Responses carry RateLimit-*
+// headers for the bucket closest to running out. If the store fails, the
+// request is let through: a broken limiter shouldn't take the API down.
+func RateLimit(store ratelimit.Store, rules ...RateLimitRule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		for _, rule := range rules {
+			if !rule.Limit.Enabled() {
+				continue
+			}
+			subject := rule.Subject(c)
+			if subject == "" {
+				continue
+			}
+
+			result, err := store.Take(ctx, rule.Bucket(subject), rule.Limit)
+			if err != nil {
+				slog.ErrorContext(ctx, "Rate limiter failed, letting the request through", "limit", rule.Name, "error", err)
+				continue
+			}
+			setRateLimitHeaders(c, rule.Limit, result)
+			if !result.Allowed {
+				metrics.RateLimited(rule.Name)
+				RejectRateLimited(c, result)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// RejectRateLimited aborts the request with a rate_limited problem telling
+// the client when to retry.
+func RejectRateLimited(c *gin.Context, result ratelimit.Result) {
+	c.Header("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	problem.Write(c, http.StatusTooManyRequests, problem.RateLimited,
+		fmt.Sprintf("Rate limit exceeded, retry in %ds", ceilSeconds(result.RetryAfter)))
+}
+
+// setRateLimitHeaders describes result in the RateLimit-* headers of the IETF
+// draft, unless an earlier rule of the request has fewer requests left.
+func setRateLimitHeaders(c *gin.Context, limit ratelimit.Limit, result ratelimit.Result) {
+	if previous, ok := c.Get(contextRateLimit); ok && previous.(ratelimit.Result).Remaining <= result.Remaining {
+		return
+	}
+	c.Set(contextRateLimit, result)
+
+	h := c.Writer.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Burst, ceilSeconds(limit.Window())))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
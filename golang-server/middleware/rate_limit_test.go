@@ -0,0 +1,60 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/example/golang-postgres-crud/middleware"
+	"github.com/example/golang-postgres-crud/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+	store := ratelimit.NewMemoryStore()
+	store.Clock = func() time.Time { return now }
+
+	router := gin.New()
+	router.Use(middleware.RateLimit(store,
+		// A token every 2 seconds, 2 at most, per IP.
+		middleware.IPRule(ratelimit.Limit{PerMinute: 30, Burst: 2}),
+		// Applies to no one.
+		middleware.RateLimitRule{Name: "none", Limit: ratelimit.Limit{PerMinute: 1, Burst: 1}, Subject: func(c *gin.Context) string { return "" }},
+	))
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	steps := []struct {
+		advance    time.Duration
+		ip         string
+		status     int
+		remaining  string
+		reset      string
+		retryAfter string
+	}{
+		{0, "10.0.0.1", http.StatusNoContent, "1", "2", ""},
+		{0, "10.0.0.1", http.StatusNoContent, "0", "4", ""},
+		{0, "10.0.0.1", http.StatusTooManyRequests, "0", "4", "2"},
+		{0, "10.0.0.2", http.StatusNoContent, "1", "2", ""},
+		{time.Second, "10.0.0.1", http.StatusTooManyRequests, "0", "3", "1"},
+		{time.Second, "10.0.0.1", http.StatusNoContent, "0", "4", ""},
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = step.ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		h := w.Header()
+		if w.Code != step.status || h.Get("RateLimit-Remaining") != step.remaining || h.Get("RateLimit-Reset") != step.reset || h.Get("Retry-After") != step.retryAfter {
+			t.Errorf("step %d: got %d, remaining %q, reset %q, retry after %q; want %d, %q, %q, %q", i,
+				w.Code, h.Get("RateLimit-Remaining"), h.Get("RateLimit-Reset"), h.Get("Retry-After"),
+				step.status, step.remaining, step.reset, step.retryAfter)
+		}
+		if h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Policy") != "2;w=4" {
+			t.Errorf("step %d: limit %q, policy %q", i, h.Get("RateLimit-Limit"), h.Get("RateLimit-Policy"))
+		}
+	}
+}
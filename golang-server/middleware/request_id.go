@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/example/golang-postgres-crud/logging"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	HeaderRequestID  = "X-Request-ID"
+	ContextRequestID = "requestID"
+)
+
+// maxRequestIDLength bounds client-supplied IDs, which end up in every log
+// line of the request.
+const maxRequestIDLength = 128
+
+// RequestID takes the request ID from the X-Request-ID header, or generates
+// one when it is missing or unusable. The ID is echoed in the response, added
+// to the request context for logging, OCR calls and problem responses.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderRequestID)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Set(ContextRequestID, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Header(HeaderRequestID, id)
+		c.Next()
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' || r == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrAuditEventImmutable = errors.New("audit events are append-only")
+
+// AuditEvent records a security or data event. Rows are only ever inserted;
+// the hooks below reject updates and deletes made through GORM.
+type AuditEvent struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt  time.Time `json:"createdAt" gorm:"index"`
+	ActorID    *uint     `json:"actorId" gorm:"index"`
+	ActorName  string    `json:"actorName"`
+	Action     string    `json:"action" gorm:"index"`
+	TargetType string    `json:"targetType" gorm:"index:idx_audit_events_target"`
+	TargetID   string    `json:"targetId" gorm:"index:idx_audit_events_target"`
+	Success    bool      `json:"success"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	Details    string    `json:"details,omitempty"`
+}
+
+func (e *AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
+
+func (e *AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
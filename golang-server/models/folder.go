@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Folder groups text readings. Folders form a tree through ParentID; a nil
+// parent means the folder is at the top level.
+type Folder struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Name      string    `json:"name" gorm:"not null;index"`
+	ParentID  *uint     `json:"parentId" gorm:"index"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Name      string    `json:"name" gorm:"not null;uniqueIndex"`
+}
@@ -4,9 +4,50 @@ import (
 	"gorm.io/gorm"
 )
 
+// OCR states of a text reading. Readings created by an upload are done right
+// away; imported images without a transcript wait for the OCR worker.
+const (
+	OcrStatusDone       = "done"
+	OcrStatusPending    = "pending"
+	OcrStatusProcessing = "processing"
+	OcrStatusFailed     = "failed"
+)
+
 type TextReadings struct {
 	gorm.Model
-	FileSize int64  `json:"fileSize"`
-	FilePath string `json:"filePath"`
-	OcrText  string `json:"ocrText"`
+	FileSize    int64  `json:"fileSize"`
+	FilePath    string `json:"filePath"`
+	OcrText     string `json:"ocrText"`
+	OcrStatus   string `json:"ocrStatus" gorm:"not null;default:done;index"`
+	OcrError    string `json:"ocrError,omitempty"`
+	OcrEngine   string `json:"ocrEngine,omitempty"`
+	OcrAttempts int    `json:"-" gorm:"not null;default:0"`
+	ContentHash string `json:"contentHash" gorm:"index"`
+	FolderID    *uint  `json:"folderId" gorm:"index"`
+	Tags        []Tag  `json:"tags" gorm:"many2many:text_reading_tags"`
+
+	// ProcessedPath is the preprocessed image OCR read, stored next to the
+	// original, if PreprocessSteps were applied.
+	ProcessedPath   string `json:"processedPath,omitempty"`
+	PreprocessSteps string `json:"preprocessSteps,omitempty"`
+
+	MetadataEntries []ReadingMetadata `json:"-" gorm:"foreignKey:TextReadingID"`
+	Metadata        map[string]string `json:"metadata" gorm:"-"`
+}
+
+// ReadingMetadata is one free-form key/value pair attached to a text reading.
+type ReadingMetadata struct {
+	ID            uint   `json:"-" gorm:"primaryKey"`
+	TextReadingID uint   `json:"-" gorm:"not null;uniqueIndex:idx_reading_metadata_key"`
+	Key           string `json:"key" gorm:"not null;uniqueIndex:idx_reading_metadata_key"`
+	Value         string `json:"value"`
+}
+
+// AfterFind exposes preloaded metadata entries as a map.
+func (t *TextReadings) AfterFind(tx *gorm.DB) error {
+	t.Metadata = make(map[string]string, len(t.MetadataEntries))
+	for _, entry := range t.MetadataEntries {
+		t.Metadata[entry.Key] = entry.Value
+	}
+	return nil
 }
@@ -2,8 +2,19 @@ package models
 
 import "gorm.io/gorm"
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	gorm.Model
 	Username string `json:"username" gorm:"unique"`
 	Password string `json:"password"`
+	Role     string `json:"role" gorm:"not null;default:user"`
+
+	// OIDCIssuer and OIDCSubject link the account to an identity at an
+	// external provider. Both are nil for local accounts.
+	OIDCIssuer  *string `json:"-" gorm:"column:oidc_issuer;uniqueIndex:idx_users_oidc_identity"`
+	OIDCSubject *string `json:"-" gorm:"column:oidc_subject;uniqueIndex:idx_users_oidc_identity"`
 }
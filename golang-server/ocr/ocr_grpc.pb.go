@@ -23,6 +23,10 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type OcrServiceClient interface {
 	PerformOcr(ctx context.Context, in *OcrRequest, opts ...grpc.CallOption) (*OcrResponse, error)
+	// PerformOcrStream takes the image in chunks, so images of any size fit
+	// under the message size limit. It answers once the client closes the
+	// stream.
+	PerformOcrStream(ctx context.Context, opts ...grpc.CallOption) (OcrService_PerformOcrStreamClient, error)
 }
 
 type ocrServiceClient struct {
@@ -42,11 +46,49 @@ func (c *ocrServiceClient) PerformOcr(ctx context.Context, in *OcrRequest, opts
 	return out, nil
 }
 
+func (c *ocrServiceClient) PerformOcrStream(ctx context.Context, opts ...grpc.CallOption) (OcrService_PerformOcrStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &OcrService_ServiceDesc.Streams[0], "/ocr.OcrService/PerformOcrStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &ocrServicePerformOcrStreamClient{stream}
+	return x, nil
+}
+
+type OcrService_PerformOcrStreamClient interface {
+	Send(*OcrChunk) error
+	CloseAndRecv() (*OcrResponse, error)
+	grpc.ClientStream
+}
+
+type ocrServicePerformOcrStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *ocrServicePerformOcrStreamClient) Send(m *OcrChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *ocrServicePerformOcrStreamClient) CloseAndRecv() (*OcrResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(OcrResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // OcrServiceServer is the server API for OcrService service.
 // All implementations must embed UnimplementedOcrServiceServer
 // for forward compatibility
 type OcrServiceServer interface {
 	PerformOcr(context.Context, *OcrRequest) (*OcrResponse, error)
+	// PerformOcrStream takes the image in chunks, so images of any size fit
+	// under the message size limit. It answers once the client closes the
+	// stream.
+	PerformOcrStream(OcrService_PerformOcrStreamServer) error
 	mustEmbedUnimplementedOcrServiceServer()
 }
 
@@ -57,6 +99,9 @@ type UnimplementedOcrServiceServer struct {
 func (UnimplementedOcrServiceServer) PerformOcr(context.Context, *OcrRequest) (*OcrResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method PerformOcr not implemented")
 }
+func (UnimplementedOcrServiceServer) PerformOcrStream(OcrService_PerformOcrStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method PerformOcrStream not implemented")
+}
 func (UnimplementedOcrServiceServer) mustEmbedUnimplementedOcrServiceServer() {}
 
 // UnsafeOcrServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -88,6 +133,32 @@ func _OcrService_PerformOcr_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _OcrService_PerformOcrStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(OcrServiceServer).PerformOcrStream(&ocrServicePerformOcrStreamServer{stream})
+}
+
+type OcrService_PerformOcrStreamServer interface {
+	SendAndClose(*OcrResponse) error
+	Recv() (*OcrChunk, error)
+	grpc.ServerStream
+}
+
+type ocrServicePerformOcrStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *ocrServicePerformOcrStreamServer) SendAndClose(m *OcrResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *ocrServicePerformOcrStreamServer) Recv() (*OcrChunk, error) {
+	m := new(OcrChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // OcrService_ServiceDesc is the grpc.ServiceDesc for OcrService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -100,6 +171,12 @@ var OcrService_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _OcrService_PerformOcr_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "PerformOcrStream",
+			Handler:       _OcrService_PerformOcrStream_Handler,
+			ClientStreams: true,
+		},
+	},
 	Metadata: "ocr/ocr.proto",
 }
@@ -0,0 +1,140 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/example/golang-postgres-crud/metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Breaker states, as reported by the ocr_circuit_state metric.
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// Breaker is a circuit breaker for an engine. Once failures calls in a row
+// fail with the engine down or too slow, it opens and rejects calls for
+// cooldown. Then it lets one call through: if that one succeeds the breaker
+// closes, otherwise it opens again. A nil Breaker lets every call through.
+type Breaker struct {
+	// Clock tells the time, time.Now when nil.
+	Clock func() time.Time
+
+	engine   string
+	failures int
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	state     int
+	failed    int
+	openUntil time.Time
+}
+
+// NewBreaker returns a breaker for the named engine, or nil if failures is 0.
+func NewBreaker(engine string, failures int, cooldown time.Duration) *Breaker {
+	if failures == 0 {
+		return nil
+	}
+	metrics.SetOCRCircuitState(engine, breakerClosed)
+	return &Breaker{engine: engine, failures: failures, cooldown: cooldown}
+}
+
+// CircuitOpenError rejects a call while the breaker is open. Its gRPC status
+// is Unavailable, so callers handle it like an unreachable server.
+type CircuitOpenError struct {
+	Engine string
+	// RetryAfter is when the breaker lets a call through again.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s circuit breaker is open", e.Engine)
+}
+
+func (e *CircuitOpenError) GRPCStatus() *status.Status {
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// Allow reports whether a call may go ahead, returning a *CircuitOpenError if
+// not. Every allowed call must be followed by Record.
+func (b *Breaker) Allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	switch {
+	case b.state == breakerClosed:
+		return nil
+	case b.state == breakerOpen && !now.Before(b.openUntil):
+		// This call finds out whether the engine is back.
+		b.setState(breakerHalfOpen)
+		return nil
+	}
+	// Open, or half open with the trial call still running.
+	metrics.OCRCircuitRejected(b.engine)
+	return &CircuitOpenError{Engine: b.engine, RetryAfter: max(b.openUntil.Sub(now), time.Second)}
+}
+
+// Record counts the outcome of an allowed call. Only failures that say the
+// engine is down or too slow count; a rejected image is the image's fault.
+// A call the caller canceled says nothing about the engine either way.
+func (b *Breaker) Record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	code := status.Code(err)
+	if errors.Is(err, context.Canceled) {
+		code = codes.Canceled
+	}
+	switch code {
+	case codes.Canceled:
+		if b.state == breakerHalfOpen {
+			// The cooldown is over, so the next call is the trial.
+			b.state = breakerOpen
+			metrics.SetOCRCircuitState(b.engine, breakerOpen)
+		}
+	case codes.Unavailable, codes.DeadlineExceeded:
+		b.failed++
+		if b.state == breakerHalfOpen || b.failed >= b.failures {
+			b.openUntil = b.now().Add(b.cooldown)
+			b.setState(breakerOpen)
+		}
+	default:
+		b.failed = 0
+		if b.state != breakerClosed {
+			b.setState(breakerClosed)
+		}
+	}
+}
+
+func (b *Breaker) now() time.Time {
+	if b.Clock == nil {
+		return time.Now()
+	}
+	return b.Clock()
+}
+
+func (b *Breaker) setState(state int) {
+	switch state {
+	case breakerOpen:
+		slog.Warn("OCR circuit breaker opened", "engine", b.engine, "failures", b.failed, "cooldown", b.cooldown.String())
+	case breakerClosed:
+		slog.Info("OCR circuit breaker closed", "engine", b.engine)
+	}
+	b.state = state
+	metrics.SetOCRCircuitState(b.engine, state)
+}
@@ -0,0 +1,138 @@
+package ocr_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	ocr "github.com/example/golang-postgres-crud/ocr_service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+var (
+	errDown     = status.Error(codes.Unavailable, "connection refused")
+	errSlow     = status.Error(codes.DeadlineExceeded, "deadline exceeded")
+	errBadImage = status.Error(codes.InvalidArgument, "not an image")
+	errCanceled = status.Error(codes.Canceled, "context canceled")
+)
+
+// breakerStep either advances the clock, asks the breaker for a call, or
+// records the outcome of one.
+type breakerStep struct {
+	advance time.Duration
+	// allow, when set, is the RetryAfter Allow is expected to reject the
+	// call with, or 0 if it should let it through.
+	allow  *time.Duration
+	record error
+}
+
+func allowed() breakerStep { return breakerStep{allow: new(time.Duration)} }
+
+func rejected(retryAfter time.Duration) breakerStep {
+	return breakerStep{allow: &retryAfter}
+}
+
+func advance(d time.Duration) breakerStep { return breakerStep{advance: d} }
+func record(err error) breakerStep        { return breakerStep{record: err} }
+
+// calls has n calls go through and fail with err.
+func calls(n int, err error) []breakerStep {
+	var steps []breakerStep
+	for i := 0; i < n; i++ {
+		steps = append(steps, allowed(), record(err))
+	}
+	return steps
+}
+
+// seq joins steps into a new slice, so test cases sharing steps don't
+// write over each other.
+func seq(parts ...[]breakerStep) []breakerStep {
+	var steps []breakerStep
+	for _, part := range parts {
+		steps = append(steps, part...)
+	}
+	return steps
+}
+
+func step(steps ...breakerStep) []breakerStep { return steps }
+
+func TestBreaker(t *testing.T) {
+	opened := seq(calls(3, errDown), step(rejected(time.Minute)))
+	halfOpen := seq(opened, step(advance(time.Minute), allowed(), rejected(time.Second)))
+
+	tests := []struct {
+		name  string
+		steps []breakerStep
+	}{
+		{"opens after 3 failures", opened},
+		{"timeouts count", seq(calls(3, errSlow), step(rejected(time.Minute)))},
+		{"a success starts over", seq(calls(2, errDown), calls(1, nil), calls(2, errDown), step(allowed()))},
+		{"rejected images don't count", seq(calls(5, errBadImage), step(allowed()))},
+		{"stays open for the cooldown", seq(opened, step(advance(50*time.Second), rejected(10*time.Second), advance(9500*time.Millisecond), rejected(time.Second)))},
+		{"half open lets one call through", halfOpen},
+		{"a failed trial opens it again", seq(halfOpen, step(record(errDown), rejected(time.Minute), advance(time.Minute), allowed()))},
+		{"a successful trial closes it", seq(halfOpen, step(record(nil)), calls(2, errDown), step(allowed(), allowed()))},
+		{"canceled calls don't count", seq(calls(2, errDown), calls(5, errCanceled), step(allowed()))},
+		{"canceled calls don't reset", seq(calls(2, errDown), calls(1, context.Canceled), calls(1, errDown), step(rejected(time.Minute)))},
+		{"a canceled trial leaves the next call to try", seq(halfOpen, step(record(fmt.Errorf("read image: %w", context.Canceled)), allowed(), rejected(time.Second), record(nil), allowed()))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+			breaker := ocr.NewBreaker("test", 3, time.Minute)
+			breaker.Clock = func() time.Time { return now }
+
+			for i, step := range tt.steps {
+				now = now.Add(step.advance)
+				switch {
+				case step.allow != nil:
+					err := breaker.Allow()
+					var open *ocr.CircuitOpenError
+					switch {
+					case *step.allow == 0 && err != nil:
+						t.Fatalf("step %d: rejected with %v, want the call let through", i, err)
+					case *step.allow != 0 && !errors.As(err, &open):
+						t.Fatalf("step %d: got %v, want the circuit open", i, err)
+					case *step.allow != 0 && open.RetryAfter != *step.allow:
+						t.Fatalf("step %d: retry after %v, want %v", i, open.RetryAfter, *step.allow)
+					}
+				case step.advance == 0:
+					breaker.Record(step.record)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerOpenErrorIsUnavailable(t *testing.T) {
+	breaker := ocr.NewBreaker("test", 1, time.Minute)
+	breaker.Allow()
+	breaker.Record(errDown)
+	if err := breaker.Allow(); status.Code(err) != codes.Unavailable {
+		t.Errorf("got %v with code %v, want Unavailable", err, status.Code(err))
+	}
+}
+
+func TestNilBreaker(t *testing.T) {
+	breaker := ocr.NewBreaker("test", 0, time.Minute)
+	if breaker != nil {
+		t.Fatal("a breaker with no failure threshold should be off")
+	}
+	for i := 0; i < 5; i++ {
+		breaker.Record(errDown)
+		if err := breaker.Allow(); err != nil {
+			t.Fatalf("nil breaker rejected a call: %v", err)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package ocr
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/example/golang-postgres-crud/metrics"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// cacheSweepInterval is how often expired results are deleted from the
+// database.
+const cacheSweepInterval = time.Hour
+
+// Cache keeps OCR results in memory for a while, dropping the least recently
+// used ones once they take up more than a set number of bytes. With a
+// database it also keeps them in the ocr_cache table, where they survive
+// restarts and are shared by replicas. Failures of the database only cost
+// cache hits; they are logged, not returned.
+type Cache struct {
+	// Clock tells the time, time.Now when nil.
+	Clock func() time.Time
+
+	ttl      time.Duration
+	maxBytes int64
+	db       *gorm.DB
+
+	mu        sync.Mutex
+	lru       *list.List // of *cacheEntry, most recently used first
+	entries   map[string]*list.Element
+	size      int64
+	nextSweep time.Time
+}
+
+type cacheEntry struct {
+	key     string
+	text    string
+	expires time.Time
+}
+
+func (e *cacheEntry) size() int64 {
+	return int64(len(e.key) + len(e.text))
+}
+
+type cacheRow struct {
+	Key       string `gorm:"primaryKey"`
+	Text      string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+func (cacheRow) TableName() string { return "ocr_cache" }
+
+// NewCache returns an empty cache whose results expire after ttl. database
+// may be nil to keep results in memory only.
+func NewCache(maxBytes int64, ttl time.Duration, database *gorm.DB) *Cache {
+	return &Cache{
+		ttl:      ttl,
+		maxBytes: maxBytes,
+		db:       database,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get returns the cached result for key.
+func (c *Cache) Get(ctx context.Context, key string) (string, bool) {
+	if text, ok := c.getMemory(key); ok {
+		metrics.ObserveOCRCache("memory_hit")
+		return text, true
+	}
+	if c.db != nil {
+		var row cacheRow
+		err := c.db.WithContext(ctx).Where("key = ? AND expires_at > ?", key, c.now().UTC()).Take(&row).Error
+		if err == nil {
+			c.putMemory(key, row.Text, row.ExpiresAt)
+			metrics.ObserveOCRCache("database_hit")
+			return row.Text, true
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			slog.WarnContext(ctx, "Failed to read the OCR cache", "error", err)
+		}
+	}
+	metrics.ObserveOCRCache("miss")
+	return "", false
+}
+
+// Put caches text as the result for key.
+func (c *Cache) Put(ctx context.Context, key, text string) {
+	now := c.now().UTC()
+	expires := now.Add(c.ttl)
+	c.putMemory(key, text, expires)
+	if c.db == nil {
+		return
+	}
+
+	row := cacheRow{Key: key, Text: text, CreatedAt: now, ExpiresAt: expires}
+	err := c.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"text", "created_at", "expires_at"}),
+	}).Create(&row).Error
+	if err != nil {
+		slog.WarnContext(ctx, "Failed to write the OCR cache", "error", err)
+	}
+	c.sweep(ctx, now)
+}
+
+// Purge empties the cache and returns how many results it held, counting
+// those in memory and in the database separately.
+func (c *Cache) Purge(ctx context.Context) (memory int, database int64, err error) {
+	c.mu.Lock()
+	memory = len(c.entries)
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.size = 0
+	c.mu.Unlock()
+	metrics.SetOCRCacheSize(0, 0)
+
+	if c.db != nil {
+		result := c.db.WithContext(ctx).Where("1 = 1").Delete(&cacheRow{})
+		database, err = result.RowsAffected, result.Error
+	}
+	return memory, database, err
+}
+
+func (c *Cache) getMemory(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if !entry.expires.After(c.now()) {
+		c.remove(elem)
+		return "", false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.text, true
+}
+
+func (c *Cache) putMemory(key, text string, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	entry := &cacheEntry{key: key, text: text, expires: expires}
+	if entry.size() > c.maxBytes {
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += entry.size()
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+	metrics.SetOCRCacheSize(len(c.entries), c.size)
+}
+
+func (c *Cache) now() time.Time {
+	if c.Clock == nil {
+		return time.Now()
+	}
+	return c.Clock()
+}
+
+// remove drops an entry from memory. c.mu must be held.
+func (c *Cache) remove(elem *list.Element) {
+	entry := c.lru.Remove(elem).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size()
+	metrics.SetOCRCacheSize(len(c.entries), c.size)
+}
+
+// sweep deletes expired results from the database, at most once per
+// cacheSweepInterval. Expired results in memory make way for new ones as
+// they are used or evicted.
+func (c *Cache) sweep(ctx context.Context, now time.Time) {
+	c.mu.Lock()
+	if now.Before(c.nextSweep) {
+		c.mu.Unlock()
+		return
+	}
+	c.nextSweep = now.Add(cacheSweepInterval)
+	c.mu.Unlock()
+
+	if err := c.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&cacheRow{}).Error; err != nil {
+		slog.WarnContext(ctx, "Failed to delete expired OCR results", "error", err)
+	}
+}
+
+// CachedEngine answers from a Cache when it can and asks Engine otherwise.
+// Only successful results are cached. Concurrent calls for the same image
+// share one call to Engine, which callers going away don't cancel; only the
+// OCR timeout bounds it. Callers that join a call stop waiting when their
+// own ctx is done.
+type CachedEngine struct {
+	Engine OCREngine
+	Cache  *Cache
+
+	calls singleflight.Group
+	mu    sync.Mutex
+	// running holds the keys of the shared calls in flight, so that the
+	// caller that starts one knows it.
+	running map[string]bool
+}
+
+// CacheKey identifies the result of OCR on image by engine. Engines with
+// settings that change the result report them with a Params method, so
+// results of differently configured engines don't mix.
+func CacheKey(image Image, engine OCREngine) string {
+	key := image.Hash + ":" + engine.Name()
+	if p, ok := engine.(interface{ Params() string }); ok {
+		key += ":" + p.Params()
+	}
+	return key
+}
+
+func (c *CachedEngine) Name() string { return c.Engine.Name() }
+
+func (c *CachedEngine) PerformOcr(ctx context.Context, image Image) (string, error) {
+	key := CacheKey(image, c.Engine)
+	if text, ok := c.Cache.Get(ctx, key); ok {
+		return text, nil
+	}
+
+	c.mu.Lock()
+	if c.running == nil {
+		c.running = make(map[string]bool)
+	}
+	started := !c.running[key]
+	c.running[key] = true
+	results := c.calls.DoChan(key, func() (interface{}, error) {
+		defer func() {
+			c.mu.Lock()
+			delete(c.running, key)
+			c.mu.Unlock()
+		}()
+
+		// The call is shared, so no single caller's cancellation ends it.
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), config.Current.OCR.Timeout)
+		defer cancel()
+		text, err := c.Engine.PerformOcr(callCtx, image)
+		if err != nil {
+			return "", err
+		}
+		c.Cache.Put(callCtx, key, text)
+		return text, nil
+	})
+	c.mu.Unlock()
+
+	if started {
+		// The call reads this caller's image, which is only valid until
+		// the caller returns, so it waits even once its ctx is done.
+		result := <-results
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return result.Val.(string), result.Err
+	}
+	select {
+	case result := <-results:
+		return result.Val.(string), result.Err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
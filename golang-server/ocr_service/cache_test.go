@@ -0,0 +1,210 @@
+package ocr_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/golang-postgres-crud/db/dbtest"
+	ocr "github.com/example/golang-postgres-crud/ocr_service"
+)
+
+// blockingEngine answers every call with the text of the image once
+// released, failing calls whose ctx ended by then.
+type blockingEngine struct {
+	started chan struct{}
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls int
+}
+
+func newBlockingEngine() *blockingEngine {
+	return &blockingEngine{started: make(chan struct{}, 10), release: make(chan struct{})}
+}
+
+func (e *blockingEngine) Name() string { return "blocking" }
+
+func (e *blockingEngine) PerformOcr(ctx context.Context, image ocr.Image) (string, error) {
+	e.mu.Lock()
+	e.calls++
+	e.mu.Unlock()
+	e.started <- struct{}{}
+
+	<-e.release
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return "text of " + image.Hash[:8], nil
+}
+
+func (e *blockingEngine) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+func TestCacheExpires(t *testing.T) {
+	ctx := context.Background()
+	for _, withDB := range []bool{false, true} {
+		now := time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+		clock := func() time.Time { return now }
+		newCache := func() *ocr.Cache {
+			cache := ocr.NewCache(1<<20, time.Hour, nil)
+			if withDB {
+				cache = ocr.NewCache(1<<20, time.Hour, dbtest.Open(t))
+			}
+			cache.Clock = clock
+			return cache
+		}
+
+		cache := newCache()
+		cache.Put(ctx, "key", "text")
+		now = now.Add(59 * time.Minute)
+		if text, ok := cache.Get(ctx, "key"); !ok || text != "text" {
+			t.Errorf("database %t: got %q, %t before the result expired", withDB, text, ok)
+		}
+		now = now.Add(time.Minute)
+		if text, ok := cache.Get(ctx, "key"); ok {
+			t.Errorf("database %t: got %q after the result expired", withDB, text)
+		}
+	}
+}
+
+func TestCacheSharesResultsThroughTheDatabase(t *testing.T) {
+	ctx := context.Background()
+	database := dbtest.Open(t)
+	ocr.NewCache(1<<20, time.Hour, database).Put(ctx, "key", "text")
+
+	// Another replica, or this one after a restart.
+	if text, ok := ocr.NewCache(1<<20, time.Hour, database).Get(ctx, "key"); !ok || text != "text" {
+		t.Errorf("got %q, %t; want the result from the database", text, ok)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	// Room for two 10-byte entries.
+	cache := ocr.NewCache(25, time.Hour, nil)
+	cache.Put(ctx, "a", "123456789")
+	cache.Put(ctx, "b", "123456789")
+	cache.Get(ctx, "a")
+	cache.Put(ctx, "c", "123456789")
+	cache.Put(ctx, "d", "this one is too large to keep")
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true, "d": false} {
+		if _, ok := cache.Get(ctx, key); ok != want {
+			t.Errorf("%s cached: %t, want %t", key, ok, want)
+		}
+	}
+}
+
+func TestCachedEngineSharesCalls(t *testing.T) {
+	engine := newBlockingEngine()
+	cached := &ocr.CachedEngine{Engine: engine, Cache: ocr.NewCache(1<<20, time.Hour, nil)}
+	image := ocr.NewImage([]byte("image"))
+
+	var wg sync.WaitGroup
+	texts := make([]string, 5)
+	errs := make([]error, 5)
+	for i := range texts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			texts[i], errs[i] = cached.PerformOcr(context.Background(), image)
+		}()
+		if i == 0 {
+			<-engine.started
+		}
+	}
+	// Give the others time to join the call in flight. Any that come later
+	// find the result in the cache.
+	time.Sleep(20 * time.Millisecond)
+	close(engine.release)
+	wg.Wait()
+
+	for i := range texts {
+		if errs[i] != nil || texts[i] != texts[0] || texts[0] == "" {
+			t.Errorf("caller %d got %q, %v; want %q", i, texts[i], errs[i], texts[0])
+		}
+	}
+	if engine.Calls() != 1 {
+		t.Errorf("engine called %d times, want once", engine.Calls())
+	}
+
+	// Another image is another call.
+	other := ocr.NewImage([]byte("other image"))
+	if text, err := cached.PerformOcr(context.Background(), other); err != nil || text == texts[0] {
+		t.Errorf("other image: got %q, %v", text, err)
+	}
+}
+
+func TestCachedEngineOutlivesCanceledCaller(t *testing.T) {
+	engine := newBlockingEngine()
+	cached := &ocr.CachedEngine{Engine: engine, Cache: ocr.NewCache(1<<20, time.Hour, nil)}
+	image := ocr.NewImage([]byte("image"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := cached.PerformOcr(ctx, image)
+		first <- err
+	}()
+	<-engine.started
+
+	second := make(chan string, 1)
+	go func() {
+		text, err := cached.PerformOcr(context.Background(), image)
+		if err != nil {
+			t.Errorf("waiting caller failed with the first one: %v", err)
+		}
+		second <- text
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	close(engine.release)
+
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled caller got %v, want context.Canceled", err)
+	}
+	if text := <-second; text == "" {
+		t.Error("waiting caller got no text")
+	}
+	if engine.Calls() != 1 {
+		t.Errorf("engine called %d times, want once", engine.Calls())
+	}
+
+	// The result was cached although the caller that started the call was
+	// gone.
+	engine.release = make(chan struct{})
+	if text, err := cached.PerformOcr(context.Background(), image); err != nil || text == "" || engine.Calls() != 1 {
+		t.Errorf("got %q, %v after %d calls; want the cached text", text, err, engine.Calls())
+	}
+}
+
+func TestCachedEngineWaiterStopsWaiting(t *testing.T) {
+	engine := newBlockingEngine()
+	cached := &ocr.CachedEngine{Engine: engine, Cache: ocr.NewCache(1<<20, time.Hour, nil)}
+	image := ocr.NewImage([]byte("image"))
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := cached.PerformOcr(context.Background(), image)
+		first <- err
+	}()
+	<-engine.started
+
+	// A caller that joins the call leaves when its own ctx ends.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cached.PerformOcr(ctx, image); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the caller's deadline", err)
+	}
+
+	close(engine.release)
+	if err := <-first; err != nil {
+		t.Errorf("first caller failed: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package ocr
+
+import (
+	"fmt"
+
+	"github.com/example/golang-postgres-crud/config"
+)
+
+// Names of the engines the server can run.
+const (
+	EngineEasyOCR   = "easyocr"
+	EngineTesseract = "tesseract"
+	EngineFake      = "fake"
+)
+
+// Engines are the OCR engines a request may choose from, by name. The first
+// one is used when a request doesn't choose.
+type Engines struct {
+	byName  map[string]OCREngine
+	names   []string
+	closers []func()
+}
+
+// NewEngines collects engines, the first of them being the default.
+func NewEngines(engines ...OCREngine) *Engines {
+	e := &Engines{byName: make(map[string]OCREngine, len(engines))}
+	for _, engine := range engines {
+		e.byName[engine.Name()] = engine
+		e.names = append(e.names, engine.Name())
+	}
+	return e
+}
+
+// OpenEngines sets up the engines enabled by ocr.engines, with ocr.engine as
+// the default. Close releases them.
+func OpenEngines() (*Engines, error) {
+	cfg := config.Current.OCR
+	names := []string{cfg.Engine}
+	for _, name := range cfg.EngineList() {
+		if name != cfg.Engine {
+			names = append(names, name)
+		}
+	}
+
+	var engines []OCREngine
+	var closers []func()
+	closeAll := func() {
+		for _, closeEngine := range closers {
+			closeEngine()
+		}
+	}
+	for _, name := range names {
+		switch name {
+		case EngineEasyOCR:
+			service, err := NewOcrService()
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			engines = append(engines, service)
+			closers = append(closers, service.Close)
+		case EngineTesseract:
+			engines = append(engines, TesseractEngine{Path: cfg.TesseractPath, Languages: cfg.TesseractLanguages})
+		case EngineFake:
+			engines = append(engines, FakeEngine{})
+		default:
+			closeAll()
+			return nil, fmt.Errorf("unknown OCR engine %q", name)
+		}
+	}
+
+	e := NewEngines(engines...)
+	e.closers = closers
+	return e, nil
+}
+
+// Get returns the engine called name, or the default one for "".
+func (e *Engines) Get(name string) (OCREngine, bool) {
+	if name == "" {
+		return e.Default(), true
+	}
+	engine, ok := e.byName[name]
+	return engine, ok
+}
+
+func (e *Engines) Default() OCREngine {
+	return e.byName[e.names[0]]
+}
+
+// Names lists the engines, the default first.
+func (e *Engines) Names() []string {
+	return e.names
+}
+
+// All returns the engines, the default first.
+func (e *Engines) All() []OCREngine {
+	all := make([]OCREngine, len(e.names))
+	for i, name := range e.names {
+		all[i] = e.byName[name]
+	}
+	return all
+}
+
+// Wrap returns the engines with each one replaced by wrap(engine), such as
+// a CachedEngine in front of it. The result closes the same engines.
+func (e *Engines) Wrap(wrap func(OCREngine) OCREngine) *Engines {
+	wrapped := NewEngines()
+	for _, engine := range e.All() {
+		wrapper := wrap(engine)
+		wrapped.byName[engine.Name()] = wrapper
+		wrapped.names = append(wrapped.names, engine.Name())
+	}
+	wrapped.closers = e.closers
+	return wrapped
+}
+
+// Close releases the connections of the engines.
+func (e *Engines) Close() {
+	for _, closeEngine := range e.closers {
+		closeEngine()
+	}
+}
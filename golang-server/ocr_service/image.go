@@ -0,0 +1,39 @@
+package ocr
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/example/golang-postgres-crud/storage"
+)
+
+// Image is an image to recognize. Engines read it through Data, in chunks
+// and as often as they need, so an upload spooled to disk never has to be
+// loaded into memory whole.
+type Image struct {
+	Data io.ReaderAt
+	Size int64
+	// Hash is the hex SHA-256 of the image, as storage.ContentHash returns
+	// it.
+	Hash string
+}
+
+// NewImage wraps image data held in memory.
+func NewImage(data []byte) Image {
+	return Image{Data: bytes.NewReader(data), Size: int64(len(data)), Hash: storage.ContentHash(data)}
+}
+
+// ReadImage wraps the first size bytes of data, reading them once to hash
+// them.
+func ReadImage(data io.ReaderAt, size int64) (Image, error) {
+	hash, err := storage.ContentHashOf(io.NewSectionReader(data, 0, size))
+	if err != nil {
+		return Image{}, err
+	}
+	return Image{Data: data, Size: size, Hash: hash}, nil
+}
+
+// Reader returns a reader of the whole image.
+func (i Image) Reader() *io.SectionReader {
+	return io.NewSectionReader(i.Data, 0, i.Size)
+}
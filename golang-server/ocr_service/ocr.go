@@ -2,51 +2,232 @@ package ocr
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
 	"time"
 
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/example/golang-postgres-crud/logging"
+	"github.com/example/golang-postgres-crud/metrics"
 	pb "github.com/example/golang-postgres-crud/ocr"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/balancer/leastrequest"
+	"google.golang.org/grpc/balancer/roundrobin"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/health" // client-side health checks
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
 )
 
-const (
-	address = "python-server:50051"
-)
+// chunkSize is how much of an image goes in one message. Images up to this
+// size are sent whole with PerformOcr, larger ones in chunks with
+// PerformOcrStream.
+const chunkSize = 1 << 20
+
+// retryBufferSize bounds what gRPC keeps of a call to retry it: the whole of
+// a PerformOcr call and the first chunks of a stream. A stream that has sent
+// more is not retried, so memory stays bounded however large the image.
+const retryBufferSize = 4 * chunkSize
+
+// OCREngine performs OCR on an image. Failures are gRPC status errors, whatever
+// the engine, so callers can tell bad images from unavailable engines.
+type OCREngine interface {
+	// Name identifies the engine in requests, on readings and in metrics.
+	Name() string
+	PerformOcr(ctx context.Context, image Image) (string, error)
+}
 
+// OcrService is the EasyOCR engine, served by the Python gRPC server.
 type OcrService struct {
-	client pb.OcrServiceClient
-	conn   *grpc.ClientConn
+	client  pb.OcrServiceClient
+	conn    *grpc.ClientConn
+	breaker *Breaker
 }
 
+// NewOcrService prepares a client for the OCR servers. The connections are
+// made lazily, so the servers do not have to be up yet; calls fail until one
+// is. Calls are balanced over the servers passing gRPC health checks, retried
+// on another one if a server is unavailable or busy, and traced, carrying the
+// trace context to the server.
 func NewOcrService() (*OcrService, error) {
-	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	cfg := config.Current.OCR
+	serviceConfig, err := ocrServiceConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+		grpc.WithDefaultServiceConfig(serviceConfig),
+		grpc.WithDefaultCallOptions(grpc.MaxRetryRPCBufferSize(retryBufferSize)),
+	}
+
+	target := cfg.Address
+	if !strings.HasPrefix(target, "dns:") {
+		// A fixed list of servers, handed to the balancer as it is.
+		servers := manual.NewBuilderWithScheme("ocr")
+		state := resolver.State{}
+		for _, address := range cfg.AddressList() {
+			state.Endpoints = append(state.Endpoints, resolver.Endpoint{Addresses: []resolver.Address{{Addr: address}}})
+		}
+		servers.InitialState(state)
+		target = servers.Scheme() + ":///" + EngineEasyOCR
+		opts = append(opts, grpc.WithResolvers(servers))
+	}
+
+	conn, err := grpc.Dial(target, opts...)
 	if err != nil {
 		return nil, err
 	}
 	client := pb.NewOcrServiceClient(conn)
 	return &OcrService{
-		client: client,
-		conn:   conn,
+		client:  client,
+		conn:    conn,
+		breaker: NewBreaker(EngineEasyOCR, cfg.BreakerFailures, cfg.BreakerCooldown),
 	}, nil
 }
 
+// ocrServiceConfig returns the gRPC service config choosing the balancer,
+// health checks and retries.
+func ocrServiceConfig(cfg config.OCR) (string, error) {
+	type retryPolicy struct {
+		MaxAttempts          int      `json:"maxAttempts"`
+		InitialBackoff       string   `json:"initialBackoff"`
+		MaxBackoff           string   `json:"maxBackoff"`
+		BackoffMultiplier    float64  `json:"backoffMultiplier"`
+		RetryableStatusCodes []string `json:"retryableStatusCodes"`
+	}
+	type methodConfig struct {
+		Name        []map[string]string `json:"name"`
+		RetryPolicy *retryPolicy        `json:"retryPolicy,omitempty"`
+	}
+
+	balancer := roundrobin.Name
+	if cfg.Balancer == "least_request" {
+		balancer = leastrequest.Name
+	}
+	method := methodConfig{Name: []map[string]string{{"service": pb.OcrService_ServiceDesc.ServiceName}}}
+	if cfg.Retries > 0 {
+		method.RetryPolicy = &retryPolicy{
+			MaxAttempts:    cfg.Retries + 1,
+			InitialBackoff: fmt.Sprintf("%gs", cfg.RetryBackoff.Seconds()),
+			// gRPC wants a cap; doubling for at most 4 retries stays below it.
+			MaxBackoff:           fmt.Sprintf("%gs", 16*cfg.RetryBackoff.Seconds()),
+			BackoffMultiplier:    2,
+			RetryableStatusCodes: []string{"UNAVAILABLE", "RESOURCE_EXHAUSTED"},
+		}
+	}
+
+	serviceConfig, err := json.Marshal(map[string]interface{}{
+		"loadBalancingConfig": []map[string]interface{}{{balancer: map[string]interface{}{}}},
+		"healthCheckConfig":   map[string]string{"serviceName": pb.OcrService_ServiceDesc.ServiceName},
+		"methodConfig":        []methodConfig{method},
+	})
+	return string(serviceConfig), err
+}
+
 func (s *OcrService) Close() {
 	if s.conn != nil {
 		s.conn.Close()
 	}
 }
 
-func (s *OcrService) PerformOcr(imageBytes []byte) (string, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*30)
+func (s *OcrService) Name() string { return EngineEasyOCR }
+
+func (s *OcrService) PerformOcr(ctx context.Context, image Image) (string, error) {
+	if err := s.breaker.Allow(); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.Current.OCR.Timeout)
 	defer cancel()
+	if id := logging.RequestID(ctx); id != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", id)
+	}
 
-	req := &pb.OcrRequest{ImageData: imageBytes}
-	resp, err := s.client.PerformOcr(ctx, req)
+	start := time.Now()
+	var resp *pb.OcrResponse
+	var err error
+	if image.Size <= chunkSize {
+		resp, err = s.performOcr(ctx, image)
+	} else {
+		resp, err = s.performOcrStream(ctx, image)
+	}
+	s.breaker.Record(err)
+	metrics.ObserveOCR(EngineEasyOCR, status.Code(err).String(), time.Since(start))
 	if err != nil {
-		log.Printf("Error during OCR operation: %v", err)
+		slog.WarnContext(ctx, "OCR request failed", "engine", EngineEasyOCR, "code", status.Code(err).String(), "error", err)
 		return "", err
 	}
 
 	return resp.GetExtractedText(), nil
 }
+
+func (s *OcrService) performOcr(ctx context.Context, image Image) (*pb.OcrResponse, error) {
+	imageBytes := make([]byte, image.Size)
+	if _, err := io.ReadFull(image.Reader(), imageBytes); err != nil {
+		return nil, imageReadError(err)
+	}
+	return s.client.PerformOcr(ctx, &pb.OcrRequest{ImageData: imageBytes})
+}
+
+// performOcrStream sends the image in chunks, reading one at a time.
+// Returning early cancels the stream through ctx.
+func (s *OcrService) performOcrStream(ctx context.Context, image Image) (*pb.OcrResponse, error) {
+	stream, err := s.client.PerformOcrStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := image.Reader()
+	for {
+		// A fresh buffer for each chunk, as gRPC may hold on to sent ones.
+		chunk := make([]byte, chunkSize)
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			if err := stream.Send(&pb.OcrChunk{Data: chunk[:n]}); err == io.EOF {
+				// The server ended the call; its status comes from
+				// CloseAndRecv.
+				break
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, imageReadError(err)
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func imageReadError(err error) error {
+	return status.Errorf(codes.Internal, "reading the image: %v", err)
+}
+
+// Check asks the OCR server's gRPC health service whether the OCR service is
+// serving. A server without the health service counts as healthy as long as
+// it answers.
+func (s *OcrService) Check(ctx context.Context) error {
+	req := &healthpb.HealthCheckRequest{Service: pb.OcrService_ServiceDesc.ServiceName}
+	resp, err := healthpb.NewHealthClient(s.conn).Check(ctx, req)
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("OCR server is %s", resp.GetStatus())
+	}
+	return nil
+}
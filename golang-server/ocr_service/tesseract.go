@@ -0,0 +1,82 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/example/golang-postgres-crud/config"
+	"github.com/example/golang-postgres-crud/metrics"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// TesseractEngine runs the Tesseract command line tool on the image, so OCR
+// works without the Python server.
+type TesseractEngine struct {
+	// Path is the tesseract executable, looked up in PATH if it has no
+	// slash.
+	Path string
+	// Languages are the trained languages to use, joined by "+" like
+	// "eng+deu".
+	Languages string
+}
+
+func (t TesseractEngine) Name() string { return EngineTesseract }
+
+// Params reports the languages, which change the result as much as the image.
+func (t TesseractEngine) Params() string { return t.Languages }
+
+func (t TesseractEngine) PerformOcr(ctx context.Context, image Image) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.Current.OCR.Timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.Path, "stdin", "stdout", "-l", t.Languages)
+	cmd.Stdin = image.Reader()
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err := tesseractError(ctx, cmd.Run(), stderr.String())
+	metrics.ObserveOCR(EngineTesseract, status.Code(err).String(), time.Since(start))
+	if err != nil {
+		slog.WarnContext(ctx, "OCR request failed", "engine", EngineTesseract, "code", status.Code(err).String(), "error", err)
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Check reports whether the tesseract executable can be found.
+func (t TesseractEngine) Check(ctx context.Context) error {
+	_, err := exec.LookPath(t.Path)
+	return err
+}
+
+// tesseractError turns a failed run into the gRPC status the Python server
+// would have answered with.
+func tesseractError(ctx context.Context, err error, stderr string) error {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "tesseract did not finish in time")
+	case ctx.Err() != nil:
+		return status.FromContextError(ctx.Err()).Err()
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return status.Errorf(codes.Unavailable, "tesseract is not installed: %v", err)
+	case errors.As(err, &exitErr):
+		// Tesseract exits with 1 for images it can't read, explaining why
+		// on the last line of stderr.
+		lines := strings.Split(strings.TrimSpace(stderr), "\n")
+		return status.Errorf(codes.InvalidArgument, "tesseract failed: %s", lines[len(lines)-1])
+	default:
+		return status.Errorf(codes.Internal, "tesseract failed: %v", err)
+	}
+}
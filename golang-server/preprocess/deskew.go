@@ -0,0 +1,87 @@
+package preprocess
+
+import (
+	"math"
+)
+
+const (
+	// maxSkew is the largest skew, in degrees, deskewing looks for.
+	maxSkew = 10.0
+	// minSkew is the smallest skew, in degrees, worth rotating the image for.
+	minSkew = 0.2
+	// skewSampleSize is the longest side of the copy the skew is measured
+	// on, which is plenty to see the lines of text.
+	skewSampleSize = 1000
+	// minInkPixels is how much text the sample needs for a skew to be found.
+	minInkPixels = 100
+)
+
+// deskewed rotates r so the lines of text are level.
+func (r *raster) deskewed() *raster {
+	angle := r.skew()
+	if math.Abs(angle) < minSkew {
+		return r
+	}
+	return r.rotated(angle)
+}
+
+// skew estimates the angle, in degrees, of the lines of text in r, positive
+// if they go down to the right.
+//
+// It projects the ink onto the vertical axis at candidate angles. At the
+// angle of the lines, the ink piles up in a few rows of the projection with
+// the gaps between lines empty, which makes the sum of squared row counts
+// peak.
+func (r *raster) skew() float64 {
+	sample := r.grayscale().downscaled(skewSampleSize)
+	threshold := otsu(sample.pix)
+
+	// The ink is whichever side of the threshold has fewer pixels, dark
+	// text on light paper or the other way round.
+	var dark int
+	for _, v := range sample.pix {
+		if v <= threshold {
+			dark++
+		}
+	}
+	inkIsDark := 2*dark <= len(sample.pix)
+	var xs, ys []float64
+	for y := 0; y < sample.h; y++ {
+		for x := 0; x < sample.w; x++ {
+			if (sample.pix[y*sample.w+x] <= threshold) == inkIsDark {
+				xs = append(xs, float64(x))
+				ys = append(ys, float64(y))
+			}
+		}
+	}
+	if len(xs) < minInkPixels {
+		return 0
+	}
+
+	rows := make([]int, 2*(sample.w+sample.h)+1)
+	offset := sample.w + sample.h
+	score := func(angle float64) float64 {
+		sin, cos := math.Sincos(angle * math.Pi / 180)
+		clear(rows)
+		for i := range xs {
+			rows[int(math.Round(ys[i]*cos-xs[i]*sin))+offset]++
+		}
+		var sum float64
+		for _, n := range rows {
+			sum += float64(n) * float64(n)
+		}
+		return sum
+	}
+	search := func(from, to, step float64) float64 {
+		best, bestScore := 0.0, score(0)
+		for angle := from; angle <= to+step/2; angle += step {
+			if s := score(angle); s > bestScore {
+				best, bestScore = angle, s
+			}
+		}
+		return best
+	}
+
+	coarse := search(-maxSkew, maxSkew, 0.5)
+	return search(coarse-0.5, coarse+0.5, 0.1)
+}
@@ -0,0 +1,99 @@
+package preprocess
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// exifHeadSize is how much of a JPEG is searched for the EXIF segment, which
+// comes before the image data and can't be larger than 64 KiB.
+const exifHeadSize = 128 << 10
+
+// orientationTag is the EXIF tag holding the orientation, 1 to 8.
+const orientationTag = 0x0112
+
+// readOrientation returns the EXIF orientation of a JPEG in src, 1 (upright)
+// if there is none.
+func readOrientation(src io.ReaderAt, size int64) (int, error) {
+	head := make([]byte, min(size, exifHeadSize))
+	if _, err := src.ReadAt(head, 0); err != nil && !errors.Is(err, io.EOF) {
+		return 0, err
+	}
+	return exifOrientation(head), nil
+}
+
+// exifOrientation finds the orientation in the EXIF segment of JPEG data,
+// walking the segments up to the image data.
+func exifOrientation(jpeg []byte) int {
+	if len(jpeg) < 2 || jpeg[0] != 0xFF || jpeg[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(jpeg); {
+		if jpeg[i] != 0xFF {
+			return 1
+		}
+		marker := jpeg[i+1]
+		switch {
+		case marker == 0xFF:
+			// Fill byte before a marker.
+			i++
+			continue
+		case marker == 0x01 || marker >= 0xD0 && marker <= 0xD8:
+			// Markers without a segment.
+			i += 2
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			// The image data starts, or the image ends.
+			return 1
+		}
+
+		length := int(binary.BigEndian.Uint16(jpeg[i+2:]))
+		end := min(i+2+length, len(jpeg))
+		segment := jpeg[i+4 : max(end, i+4)]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of the TIFF
+// structure EXIF data is stored in.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int64(order.Uint32(tiff[4:]))
+	if ifd+2 > int64(len(tiff)) {
+		return 1
+	}
+	entries := int64(order.Uint16(tiff[ifd:]))
+	for k := int64(0); k < entries; k++ {
+		entry := ifd + 2 + 12*k
+		if entry+12 > int64(len(tiff)) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != orientationTag {
+			continue
+		}
+		// A SHORT value sits at the start of the 4-byte value field.
+		if orientation := int(order.Uint16(tiff[entry+8:])); orientation >= 1 && orientation <= 8 {
+			return orientation
+		}
+		return 1
+	}
+	return 1
+}
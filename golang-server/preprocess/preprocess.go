@@ -0,0 +1,148 @@
+// Package preprocess prepares photos of documents for OCR: it turns them
+// upright, shrinks them, and makes the text stand out straight and sharp.
+package preprocess
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // decoders for image.Decode
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// Step is one preprocessing operation.
+type Step string
+
+const (
+	// Orient applies the EXIF orientation of a JPEG, so the page is upright.
+	Orient Step = "orient"
+	// Downscale shrinks the image to Options.MaxDimension.
+	Downscale Step = "downscale"
+	// Grayscale drops the colors.
+	Grayscale Step = "grayscale"
+	// Deskew rotates the image so the lines of text are level.
+	Deskew Step = "deskew"
+	// Binarize turns every pixel black or white.
+	Binarize Step = "binarize"
+)
+
+// Steps lists the steps in the order they run: orientation first so the
+// rest sees the page upright, then downscaling to make the rest cheap.
+// Deskewing comes before binarizing, so the rotation blends gray levels
+// instead of leaving jagged black and white edges.
+var Steps = []Step{Orient, Downscale, Grayscale, Deskew, Binarize}
+
+// None is the list of steps that asks for no preprocessing.
+const None = "none"
+
+// Suffix replaces the extension of an image to name its processed
+// derivative, which is stored next to it.
+const Suffix = ".processed.png"
+
+var (
+	// ErrUnreadable is returned for data that isn't an image Process can
+	// decode.
+	ErrUnreadable = errors.New("not a JPEG, PNG or GIF image")
+	// ErrTooLarge is returned for images with more pixels than
+	// Options.MaxPixels.
+	ErrTooLarge = errors.New("image too large to preprocess")
+)
+
+// Options bounds the work Process does.
+type Options struct {
+	// MaxDimension is the longest side, in pixels, Downscale leaves.
+	MaxDimension int
+	// MaxPixels bounds the size of the images decoded, as a decoded image
+	// takes up 4 bytes per pixel.
+	MaxPixels int64
+}
+
+// ParseSteps parses a comma-separated list of steps, returning them in the
+// order they run whatever the order of the list. "" and "none" mean no
+// steps.
+func ParseSteps(list string) ([]Step, error) {
+	requested := make(map[Step]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == None {
+			continue
+		}
+		step := Step(name)
+		if !known(step) {
+			return nil, fmt.Errorf("unknown preprocessing step %q, expected %s or %s", name, FormatSteps(Steps), None)
+		}
+		requested[step] = true
+	}
+
+	var steps []Step
+	for _, step := range Steps {
+		if requested[step] {
+			steps = append(steps, step)
+		}
+	}
+	return steps, nil
+}
+
+// FormatSteps joins steps into the list ParseSteps reads.
+func FormatSteps(steps []Step) string {
+	names := make([]string, len(steps))
+	for i, step := range steps {
+		names[i] = string(step)
+	}
+	return strings.Join(names, ",")
+}
+
+func known(step Step) bool {
+	for _, s := range Steps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// Process decodes the first size bytes of src, applies steps and returns
+// the result as PNG.
+func Process(src io.ReaderAt, size int64, steps []Step, opts Options) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(io.NewSectionReader(src, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > opts.MaxPixels {
+		return nil, fmt.Errorf("%w: %dx%d is more than %d pixels", ErrTooLarge, cfg.Width, cfg.Height, opts.MaxPixels)
+	}
+	img, _, err := image.Decode(io.NewSectionReader(src, 0, size))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+
+	r := fromImage(img)
+	for _, step := range steps {
+		switch step {
+		case Orient:
+			orientation, err := readOrientation(src, size)
+			if err != nil {
+				return nil, err
+			}
+			r = r.oriented(orientation)
+		case Downscale:
+			r = r.downscaled(opts.MaxDimension)
+		case Grayscale:
+			r = r.grayscale()
+		case Deskew:
+			r = r.deskewed()
+		case Binarize:
+			r = r.binarized()
+		}
+	}
+
+	var out bytes.Buffer
+	if err := png.Encode(&out, r.image()); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
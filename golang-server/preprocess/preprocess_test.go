@@ -0,0 +1,212 @@
+package preprocess_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"reflect"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/preprocess"
+)
+
+var opts = preprocess.Options{MaxDimension: 1000, MaxPixels: 1 << 20}
+
+// halves draws a w×h image, black on the left half and white on the right.
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x >= w/2 {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeJPEG encodes img with an EXIF segment holding orientation right
+// after the start of image marker.
+func encodeJPEG(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A big-endian TIFF header and an IFD with the orientation alone.
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, 0x0112)
+	tiff = append(tiff, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01)
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	data := buf.Bytes()
+	return append(append(data[:2:2], app1...), data[2:]...)
+}
+
+func process(t *testing.T, data []byte, steps ...preprocess.Step) image.Image {
+	t.Helper()
+	out, err := preprocess.Process(bytes.NewReader(data), int64(len(data)), steps, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("output is not a PNG: %v", err)
+	}
+	return img
+}
+
+func gray(img image.Image, x, y int) uint8 {
+	return color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+}
+
+func TestProcessOrient(t *testing.T) {
+	tests := []struct {
+		orientation uint16
+		size        image.Point
+		// dark and light are points that should end up black and white.
+		dark, light image.Point
+	}{
+		{1, image.Pt(40, 20), image.Pt(5, 10), image.Pt(35, 10)},
+		{3, image.Pt(40, 20), image.Pt(35, 10), image.Pt(5, 10)},
+		// Stored turned a quarter counterclockwise: the left half is on top.
+		{6, image.Pt(20, 40), image.Pt(10, 5), image.Pt(10, 35)},
+		{8, image.Pt(20, 40), image.Pt(10, 35), image.Pt(10, 5)},
+	}
+	for _, tt := range tests {
+		img := process(t, encodeJPEG(t, halves(40, 20), tt.orientation), preprocess.Orient)
+		if got := img.Bounds().Size(); got != tt.size {
+			t.Errorf("orientation %d: size %v, want %v", tt.orientation, got, tt.size)
+			continue
+		}
+		if v := gray(img, tt.dark.X, tt.dark.Y); v > 64 {
+			t.Errorf("orientation %d: %v is %d, want dark", tt.orientation, tt.dark, v)
+		}
+		if v := gray(img, tt.light.X, tt.light.Y); v < 192 {
+			t.Errorf("orientation %d: %v is %d, want light", tt.orientation, tt.light, v)
+		}
+	}
+
+	// Without the step, the orientation is left alone.
+	img := process(t, encodeJPEG(t, halves(40, 20), 6))
+	if got := img.Bounds().Size(); got != image.Pt(40, 20) {
+		t.Errorf("unoriented size %v, want 40x20", got)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	big := encodePNG(t, halves(2000, 1000))
+	if _, err := preprocess.Process(bytes.NewReader(big), int64(len(big)), nil, opts); !errors.Is(err, preprocess.ErrTooLarge) {
+		t.Errorf("2000x1000 image: got %v, want ErrTooLarge", err)
+	}
+	text := []byte("not an image")
+	if _, err := preprocess.Process(bytes.NewReader(text), int64(len(text)), nil, opts); !errors.Is(err, preprocess.ErrUnreadable) {
+		t.Errorf("text: got %v, want ErrUnreadable", err)
+	}
+	// Only the first size bytes are read.
+	small := encodePNG(t, halves(40, 20))
+	if _, err := preprocess.Process(bytes.NewReader(small), int64(len(small))-10, nil, opts); !errors.Is(err, preprocess.ErrUnreadable) {
+		t.Errorf("truncated image: got %v, want ErrUnreadable", err)
+	}
+}
+
+func TestProcessGrayscale(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	src.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	src.Set(1, 0, color.NRGBA{G: 255, A: 255})
+	// Transparent areas are paper, not ink.
+	src.Set(2, 0, color.NRGBA{})
+
+	img := process(t, encodePNG(t, src), preprocess.Grayscale)
+	g, ok := img.(*image.Gray)
+	if !ok {
+		t.Fatalf("output is %T, want a gray image", img)
+	}
+	if want := []uint8{76, 150, 255}; !bytes.Equal(g.Pix, want) {
+		t.Errorf("gray levels %v, want %v", g.Pix, want)
+	}
+}
+
+func TestProcessBinarize(t *testing.T) {
+	// Dark gray text on light gray paper, with some noise.
+	src := image.NewGray(image.Rect(0, 0, 50, 50))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(190 + i%20)
+		if i/50 >= 20 && i/50 < 30 {
+			src.Pix[i] = uint8(40 + i%20)
+		}
+	}
+
+	img := process(t, encodePNG(t, src), preprocess.Binarize)
+	g, ok := img.(*image.Gray)
+	if !ok {
+		t.Fatalf("output is %T, want a gray image", img)
+	}
+	for i, v := range g.Pix {
+		want := uint8(255)
+		if i/50 >= 20 && i/50 < 30 {
+			want = 0
+		}
+		if v != want {
+			t.Fatalf("pixel %d is %d, want %d", i, v, want)
+		}
+	}
+}
+
+func TestProcessDownscale(t *testing.T) {
+	small := preprocess.Options{MaxDimension: 20, MaxPixels: opts.MaxPixels}
+	data := encodePNG(t, halves(100, 50))
+	out, err := preprocess.Process(bytes.NewReader(data), int64(len(data)), []preprocess.Step{preprocess.Downscale}, small)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 20 || cfg.Height != 10 {
+		t.Errorf("downscaled to %dx%d, want 20x10", cfg.Width, cfg.Height)
+	}
+}
+
+func TestParseSteps(t *testing.T) {
+	tests := []struct {
+		list    string
+		want    []preprocess.Step
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"none", nil, false},
+		{"binarize, orient", []preprocess.Step{preprocess.Orient, preprocess.Binarize}, false},
+		{"grayscale,grayscale", []preprocess.Step{preprocess.Grayscale}, false},
+		{"orient,sharpen", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := preprocess.ParseSteps(tt.list)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseSteps(%q) = %v, %v; want %v, error %t", tt.list, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
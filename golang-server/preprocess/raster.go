@@ -0,0 +1,218 @@
+package preprocess
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// raster is an 8-bit image with either 1 sample per pixel (gray) or 4 (RGBA,
+// always opaque). Every operation returns a new raster, or r itself if it
+// has nothing to do.
+type raster struct {
+	pix      []uint8
+	w, h     int
+	channels int
+}
+
+func newRaster(w, h, channels int) *raster {
+	return &raster{pix: make([]uint8, w*h*channels), w: w, h: h, channels: channels}
+}
+
+// fromImage copies img into a raster. Transparent areas become white, the
+// color of paper, rather than black.
+func fromImage(img image.Image) *raster {
+	b := img.Bounds()
+	if gray, ok := img.(*image.Gray); ok {
+		r := newRaster(b.Dx(), b.Dy(), 1)
+		for y := 0; y < r.h; y++ {
+			start := gray.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(r.pix[y*r.w:(y+1)*r.w], gray.Pix[start:start+r.w])
+		}
+		return r
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Over)
+	return &raster{pix: rgba.Pix, w: b.Dx(), h: b.Dy(), channels: 4}
+}
+
+func (r *raster) image() image.Image {
+	rect := image.Rect(0, 0, r.w, r.h)
+	if r.channels == 1 {
+		return &image.Gray{Pix: r.pix, Stride: r.w, Rect: rect}
+	}
+	return &image.RGBA{Pix: r.pix, Stride: 4 * r.w, Rect: rect}
+}
+
+// oriented undoes an EXIF orientation, 1 to 8.
+func (r *raster) oriented(orientation int) *raster {
+	if orientation <= 1 || orientation > 8 {
+		return r
+	}
+	w, h := r.w, r.h
+	if orientation >= 5 {
+		// Orientations 5 to 8 turn the image a quarter.
+		w, h = h, w
+	}
+	// source maps a pixel of the upright image to the stored one.
+	source := map[int]func(x, y int) (int, int){
+		2: func(x, y int) (int, int) { return r.w - 1 - x, y },
+		3: func(x, y int) (int, int) { return r.w - 1 - x, r.h - 1 - y },
+		4: func(x, y int) (int, int) { return x, r.h - 1 - y },
+		5: func(x, y int) (int, int) { return y, x },
+		6: func(x, y int) (int, int) { return y, r.h - 1 - x },
+		7: func(x, y int) (int, int) { return r.w - 1 - y, r.h - 1 - x },
+		8: func(x, y int) (int, int) { return r.w - 1 - y, x },
+	}[orientation]
+
+	out := newRaster(w, h, r.channels)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			sx, sy := source(x, y)
+			copy(out.pix[out.offset(x, y):out.offset(x+1, y)], r.pix[r.offset(sx, sy):r.offset(sx+1, sy)])
+		}
+	}
+	return out
+}
+
+// downscaled shrinks r so that neither side is longer than maxDimension,
+// averaging the pixels each output pixel covers.
+func (r *raster) downscaled(maxDimension int) *raster {
+	longest := max(r.w, r.h)
+	if longest <= maxDimension {
+		return r
+	}
+	scale := float64(maxDimension) / float64(longest)
+	w := max(1, int(math.Round(float64(r.w)*scale)))
+	h := max(1, int(math.Round(float64(r.h)*scale)))
+
+	out := newRaster(w, h, r.channels)
+	sum := make([]int, r.channels)
+	for y := 0; y < h; y++ {
+		y0, y1 := y*r.h/h, (y+1)*r.h/h
+		for x := 0; x < w; x++ {
+			x0, x1 := x*r.w/w, (x+1)*r.w/w
+			clear(sum)
+			for sy := y0; sy < y1; sy++ {
+				row := r.pix[r.offset(x0, sy):r.offset(x1, sy)]
+				for i, v := range row {
+					sum[i%r.channels] += int(v)
+				}
+			}
+			n := (x1 - x0) * (y1 - y0)
+			o := out.offset(x, y)
+			for c := range sum {
+				out.pix[o+c] = uint8((sum[c] + n/2) / n)
+			}
+		}
+	}
+	return out
+}
+
+// grayscale converts r to gray with the luma weights color.GrayModel uses.
+func (r *raster) grayscale() *raster {
+	if r.channels == 1 {
+		return r
+	}
+	out := newRaster(r.w, r.h, 1)
+	for i := range out.pix {
+		p := r.pix[4*i : 4*i+3]
+		out.pix[i] = uint8((19595*uint32(p[0]) + 38470*uint32(p[1]) + 7471*uint32(p[2]) + 1<<15) >> 16)
+	}
+	return out
+}
+
+// binarized turns r into black and white, splitting the gray levels at the
+// Otsu threshold.
+func (r *raster) binarized() *raster {
+	gray := r.grayscale()
+	threshold := otsu(gray.pix)
+	out := newRaster(gray.w, gray.h, 1)
+	for i, v := range gray.pix {
+		if v > threshold {
+			out.pix[i] = 255
+		}
+	}
+	return out
+}
+
+// otsu returns the threshold that best splits the gray levels in pix into
+// dark and light, the one with the largest variance between the two classes.
+// Levels up to the threshold are dark.
+func otsu(pix []uint8) uint8 {
+	var histogram [256]int
+	for _, v := range pix {
+		histogram[v]++
+	}
+	total := float64(len(pix))
+	var sumAll float64
+	for level, n := range histogram {
+		sumAll += float64(level * n)
+	}
+
+	var (
+		best      uint8
+		bestScore = -1.0
+		darkCount float64
+		darkSum   float64
+	)
+	for level, n := range histogram {
+		darkCount += float64(n)
+		darkSum += float64(level * n)
+		lightCount := total - darkCount
+		if darkCount == 0 || lightCount == 0 {
+			continue
+		}
+		darkMean := darkSum / darkCount
+		lightMean := (sumAll - darkSum) / lightCount
+		score := darkCount * lightCount * (darkMean - lightMean) * (darkMean - lightMean)
+		if score > bestScore {
+			best, bestScore = uint8(level), score
+		}
+	}
+	return best
+}
+
+// rotated turns r by angle degrees, counterclockwise as the image is viewed,
+// growing the canvas to keep the corners and filling it white.
+func (r *raster) rotated(angle float64) *raster {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	w := int(math.Ceil(math.Abs(float64(r.w)*cos) + math.Abs(float64(r.h)*sin)))
+	h := int(math.Ceil(math.Abs(float64(r.w)*sin) + math.Abs(float64(r.h)*cos)))
+	cx, cy := float64(r.w-1)/2, float64(r.h-1)/2
+	ocx, ocy := float64(w-1)/2, float64(h-1)/2
+
+	out := newRaster(w, h, r.channels)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := float64(x)-ocx, float64(y)-ocy
+			r.sample(out.pix[out.offset(x, y):out.offset(x+1, y)], cx+dx*cos-dy*sin, cy+dx*sin+dy*cos)
+		}
+	}
+	return out
+}
+
+// sample writes the bilinear interpolation of r at (x, y) to dst, taking
+// pixels outside r as white.
+func (r *raster) sample(dst []uint8, x, y float64) {
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	for c := range dst {
+		top := (1-fx)*r.at(x0, y0, c) + fx*r.at(x0+1, y0, c)
+		bottom := (1-fx)*r.at(x0, y0+1, c) + fx*r.at(x0+1, y0+1, c)
+		dst[c] = uint8(math.Round((1-fy)*top + fy*bottom))
+	}
+}
+
+func (r *raster) at(x, y, c int) float64 {
+	if x < 0 || y < 0 || x >= r.w || y >= r.h {
+		return 255
+	}
+	return float64(r.pix[r.offset(x, y)+c])
+}
+
+func (r *raster) offset(x, y int) int {
+	return (y*r.w + x) * r.channels
+}
@@ -0,0 +1,150 @@
+// Package problem writes API errors as RFC 7807 problem details
+// (application/problem+json). Every problem carries a stable code that
+// clients can match on; the title and detail are for humans and may change.
+package problem
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/example/golang-postgres-crud/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// TypeBase prefixes the code to form the problem type URI.
+const TypeBase = "/problems/"
+
+// Code identifies a kind of problem. Codes are part of the API: add new ones
+// rather than renaming existing ones.
+type Code string
+
+const (
+	InvalidRequest  Code = "invalid_request"
+	InvalidID       Code = "invalid_id"
+	FileMissing     Code = "file_missing"
+	PayloadTooLarge Code = "payload_too_large"
+	InvalidImage    Code = "invalid_image"
+
+	Unauthenticated    Code = "unauthenticated"
+	InvalidToken       Code = "invalid_token"
+	InvalidAPIKey      Code = "invalid_api_key"
+	InvalidCredentials Code = "invalid_credentials"
+	LoginFailed        Code = "login_failed"
+
+	Forbidden         Code = "forbidden"
+	InsufficientScope Code = "insufficient_scope"
+	UserTokenRequired Code = "user_token_required"
+	AccountNotLinked  Code = "account_not_linked"
+
+	RateLimited   Code = "rate_limited"
+	QuotaExceeded Code = "quota_exceeded"
+
+	NotFound      Code = "not_found"
+	RouteNotFound Code = "route_not_found"
+	AlreadyExists Code = "already_exists"
+	NotEmpty      Code = "not_empty"
+
+	OCRRejected    Code = "ocr_rejected"
+	OCRBusy        Code = "ocr_busy"
+	OCRFailed      Code = "ocr_failed"
+	OCRUnavailable Code = "ocr_unavailable"
+	OCRTimeout     Code = "ocr_timeout"
+
+	DatabaseError Code = "database_error"
+	StorageError  Code = "storage_error"
+	InternalError Code = "internal_error"
+	ShuttingDown  Code = "shutting_down"
+)
+
+var titles = map[Code]string{
+	InvalidRequest:     "Invalid request",
+	InvalidID:          "Invalid ID",
+	FileMissing:        "File missing",
+	PayloadTooLarge:    "Payload too large",
+	InvalidImage:       "Invalid image",
+	Unauthenticated:    "Authentication required",
+	InvalidToken:       "Invalid token",
+	InvalidAPIKey:      "Invalid API key",
+	InvalidCredentials: "Invalid credentials",
+	LoginFailed:        "Login failed",
+	Forbidden:          "Forbidden",
+	InsufficientScope:  "Insufficient scope",
+	UserTokenRequired:  "User token required",
+	AccountNotLinked:   "Account not linked",
+	RateLimited:        "Too many requests",
+	QuotaExceeded:      "Quota exceeded",
+	NotFound:           "Not found",
+	RouteNotFound:      "Route not found",
+	AlreadyExists:      "Already exists",
+	NotEmpty:           "Not empty",
+	OCRRejected:        "Image rejected by OCR",
+	OCRBusy:            "OCR service busy",
+	OCRFailed:          "OCR failed",
+	OCRUnavailable:     "OCR service unavailable",
+	OCRTimeout:         "OCR timed out",
+	DatabaseError:      "Database error",
+	StorageError:       "Storage error",
+	InternalError:      "Internal server error",
+	ShuttingDown:       "Server shutting down",
+}
+
+// Problem is an RFC 7807 problem details object. Code, RequestID and Scope
+// are extension members.
+type Problem struct {
+	Type      string `json:"type" example:"/problems/not_found"`
+	Title     string `json:"title" example:"Not found"`
+	Status    int    `json:"status" example:"404"`
+	Detail    string `json:"detail,omitempty" example:"Text reading not found"`
+	Instance  string `json:"instance,omitempty" example:"/api/text-readings/42"`
+	Code      Code   `json:"code" example:"not_found"`
+	RequestID string `json:"requestId,omitempty" example:"4f1c2a9e0b7d4e3f8a6b5c4d3e2f1a0b"`
+	// Scope names the API key scope involved in insufficient_scope and
+	// unknown-scope problems.
+	Scope string `json:"scope,omitempty"`
+	// Quota names the exceeded quota in quota_exceeded problems, as
+	// reported by /api/usage.
+	Quota *Quota `json:"quota,omitempty"`
+}
+
+// Quota identifies a usage quota and its limit.
+type Quota struct {
+	Metric   string    `json:"metric" example:"ocr"`
+	Period   string    `json:"period" example:"daily"`
+	Limit    int64     `json:"limit" example:"100"`
+	ResetsAt time.Time `json:"resetsAt"`
+}
+
+// New returns a problem with the type and title that belong to code.
+func New(status int, code Code, detail string) Problem {
+	title, ok := titles[code]
+	if !ok {
+		title = http.StatusText(status)
+	}
+	return Problem{
+		Type:   TypeBase + string(code),
+		Title:  title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Write aborts the request with a problem response.
+func Write(c *gin.Context, status int, code Code, detail string) {
+	Respond(c, New(status, code, detail))
+}
+
+// Respond aborts the request with p, filling in the request path and ID.
+func Respond(c *gin.Context, p Problem) {
+	if p.Instance == "" {
+		p.Instance = c.Request.URL.Path
+	}
+	if p.RequestID == "" {
+		p.RequestID = logging.RequestID(c.Request.Context())
+	}
+	c.Header("Content-Type", ContentType)
+	c.AbortWithStatusJSON(p.Status, p)
+}
@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBStore keeps buckets and counters in the rate_limit_buckets and
+// usage_counters tables, so every replica sharing the database shares the
+// limits. Each call is one short transaction that locks the row it changes.
+type DBStore struct {
+	Clock Clock
+
+	db *gorm.DB
+
+	mu        sync.Mutex
+	nextSweep time.Time
+}
+
+type bucketRow struct {
+	Key    string `gorm:"primaryKey"`
+	Tokens float64
+	// Named so GORM doesn't overwrite it with the time of the update.
+	Refilled time.Time `gorm:"column:updated_at"`
+	FullAt   time.Time
+}
+
+func (bucketRow) TableName() string { return "rate_limit_buckets" }
+
+type counterRow struct {
+	Key       string `gorm:"primaryKey"`
+	Value     int64
+	ExpiresAt time.Time
+}
+
+func (counterRow) TableName() string { return "usage_counters" }
+
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := s.Clock.now().UTC()
+	s.sweep(ctx, now)
+
+	var result Result
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		b := newBucket(now, limit)
+		row := bucketRow{Key: key, Tokens: b.tokens, Refilled: now, FullAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).Take(&row).Error; err != nil {
+			return err
+		}
+
+		b = bucket{tokens: row.Tokens, updated: row.Refilled}
+		result = b.take(now, limit)
+		return tx.Model(&bucketRow{}).Where("key = ?", key).Updates(map[string]interface{}{
+			"tokens":     b.tokens,
+			"updated_at": now,
+			"full_at":    now.Add(result.Reset),
+		}).Error
+	})
+	return result, err
+}
+
+func (s *DBStore) Add(ctx context.Context, key string, amount, max int64, expires time.Time) (int64, bool, error) {
+	now := s.Clock.now().UTC()
+	s.sweep(ctx, now)
+
+	var value int64
+	var ok bool
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		row := counterRow{Key: key, ExpiresAt: expires.UTC()}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("key = ?", key).Take(&row).Error; err != nil {
+			return err
+		}
+
+		// An expired counter that wasn't swept yet starts over.
+		if !row.ExpiresAt.After(now) {
+			row.Value = 0
+			row.ExpiresAt = expires.UTC()
+		}
+		value = row.Value
+		if max > 0 && row.Value+amount > max {
+			return nil
+		}
+		value, ok = row.Value+amount, true
+		return tx.Model(&counterRow{}).Where("key = ?", key).Updates(map[string]interface{}{
+			"value":      value,
+			"expires_at": row.ExpiresAt,
+		}).Error
+	})
+	return value, ok, err
+}
+
+func (s *DBStore) Counters(ctx context.Context, keys []string) (map[string]int64, error) {
+	var rows []counterRow
+	err := s.db.WithContext(ctx).
+		Where("key IN ? AND expires_at > ?", keys, s.Clock.now().UTC()).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		values[row.Key] = row.Value
+	}
+	return values, nil
+}
+
+// sweep deletes full buckets and expired counters, at most once per
+// sweepInterval across the calls of this replica. Failures are only logged:
+// leftover rows are harmless and the next sweep retries.
+func (s *DBStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Before(s.nextSweep) {
+		s.mu.Unlock()
+		return
+	}
+	s.nextSweep = now.Add(sweepInterval)
+	s.mu.Unlock()
+
+	db := s.db.WithContext(ctx)
+	if err := db.Where("full_at <= ?", now).Delete(&bucketRow{}).Error; err != nil {
+		slog.WarnContext(ctx, "Failed to delete full rate limit buckets", "error", err)
+	}
+	if err := db.Where("expires_at <= ?", now).Delete(&counterRow{}).Error; err != nil {
+		slog.WarnContext(ctx, "Failed to delete expired usage counters", "error", err)
+	}
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps buckets and counters in the process. Every replica has
+// its own, so limits apply per replica.
+type MemoryStore struct {
+	Clock Clock
+
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	counters  map[string]*memoryCounter
+	nextSweep time.Time
+}
+
+type memoryBucket struct {
+	bucket
+	fullAt time.Time
+}
+
+type memoryCounter struct {
+	value   int64
+	expires time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:  make(map[string]*memoryBucket),
+		counters: make(map[string]*memoryCounter),
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Clock.now()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{bucket: newBucket(now, limit)}
+		s.buckets[key] = b
+	}
+	result := b.take(now, limit)
+	b.fullAt = now.Add(result.Reset)
+	return result, nil
+}
+
+func (s *MemoryStore) Add(ctx context.Context, key string, amount, max int64, expires time.Time) (int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Clock.now()
+	s.sweep(now)
+	c, ok := s.counters[key]
+	if !ok || !c.expires.After(now) {
+		c = &memoryCounter{expires: expires}
+		s.counters[key] = c
+	}
+	if max > 0 && c.value+amount > max {
+		return c.value, false, nil
+	}
+	c.value += amount
+	return c.value, true, nil
+}
+
+func (s *MemoryStore) Counters(ctx context.Context, keys []string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.Clock.now()
+	values := make(map[string]int64, len(keys))
+	for _, key := range keys {
+		if c, ok := s.counters[key]; ok && c.expires.After(now) {
+			values[key] = c.value
+		}
+	}
+	return values, nil
+}
+
+// sweep drops full buckets and expired counters, at most once per
+// sweepInterval. s.mu must be held.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	s.nextSweep = now.Add(sweepInterval)
+	for key, b := range s.buckets {
+		if !b.fullAt.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, c := range s.counters {
+		if !c.expires.After(now) {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Metric is something a quota limits.
+type Metric string
+
+const (
+	// OCRCalls counts the images sent to the OCR server.
+	OCRCalls Metric = "ocr"
+	// UploadBytes counts the bytes of uploaded images that are kept.
+	UploadBytes Metric = "uploadBytes"
+)
+
+// Metrics lists the metrics in the order usage is reported.
+var Metrics = []Metric{OCRCalls, UploadBytes}
+
+// Period is the span a quota counts over. Periods are calendar days and
+// months in UTC.
+type Period string
+
+const (
+	Daily   Period = "daily"
+	Monthly Period = "monthly"
+)
+
+var periods = []Period{Daily, Monthly}
+
+// QuotaLimit caps a metric per user. Zero means no cap; usage is counted
+// either way.
+type QuotaLimit struct {
+	Daily   int64
+	Monthly int64
+}
+
+func (l QuotaLimit) of(period Period) int64 {
+	if period == Daily {
+		return l.Daily
+	}
+	return l.Monthly
+}
+
+// Charge is an amount of a metric to count against a user's quotas.
+type Charge struct {
+	Metric Metric
+	Amount int64
+}
+
+// QuotaError reports a charge that would exceed a quota.
+type QuotaError struct {
+	Metric   Metric
+	Period   Period
+	Limit    int64
+	ResetsAt time.Time
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("%s %s quota of %d exceeded", e.Period, e.Metric, e.Limit)
+}
+
+// Usage is how much of a quota a user has used.
+type Usage struct {
+	Metric Metric `json:"metric" example:"ocr"`
+	Period Period `json:"period" example:"daily"`
+	Used   int64  `json:"used" example:"12"`
+	// Limit is 0 when the metric is not capped.
+	Limit    int64     `json:"limit" example:"100"`
+	ResetsAt time.Time `json:"resetsAt"`
+}
+
+// Quotas counts per-user usage in a Store and enforces the limits.
+type Quotas struct {
+	Store  Store
+	Limits map[Metric]QuotaLimit
+	Clock  Clock
+}
+
+// Consume counts the charges against the quotas of a user. If any of them
+// would exceed a quota, nothing is counted and a *QuotaError is returned.
+func (q *Quotas) Consume(ctx context.Context, userID uint, charges ...Charge) (*Consumption, error) {
+	now := q.Clock.now().UTC()
+	consumption := &Consumption{quotas: q}
+	for _, charge := range charges {
+		for _, period := range periods {
+			key, resetsAt := counterKey(userID, charge.Metric, period, now)
+			limit := q.Limits[charge.Metric].of(period)
+			_, ok, err := q.Store.Add(ctx, key, charge.Amount, limit, resetsAt)
+			if err == nil && !ok {
+				err = &QuotaError{Metric: charge.Metric, Period: period, Limit: limit, ResetsAt: resetsAt}
+			}
+			if err != nil {
+				consumption.Refund(ctx)
+				return nil, err
+			}
+			consumption.counted = append(consumption.counted, counted{key, charge.Amount, resetsAt})
+		}
+	}
+	return consumption, nil
+}
+
+// Usage reports the usage of a user for every metric and period.
+func (q *Quotas) Usage(ctx context.Context, userID uint) ([]Usage, error) {
+	now := q.Clock.now().UTC()
+	var usage []Usage
+	var keys []string
+	for _, metric := range Metrics {
+		for _, period := range periods {
+			key, resetsAt := counterKey(userID, metric, period, now)
+			keys = append(keys, key)
+			usage = append(usage, Usage{
+				Metric:   metric,
+				Period:   period,
+				Limit:    q.Limits[metric].of(period),
+				ResetsAt: resetsAt,
+			})
+		}
+	}
+
+	values, err := q.Store.Counters(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	for i, key := range keys {
+		usage[i].Used = values[key]
+	}
+	return usage, nil
+}
+
+// Consumption is what Consume counted.
+type Consumption struct {
+	quotas  *Quotas
+	counted []counted
+}
+
+type counted struct {
+	key      string
+	amount   int64
+	resetsAt time.Time
+}
+
+// Refund takes back the consumption, for work that failed after it was
+// charged. It refunds the periods that were charged even if they ended in
+// the meantime. Failures are only logged: the user is merely charged too
+// much.
+func (c *Consumption) Refund(ctx context.Context) {
+	for _, counted := range c.counted {
+		if _, _, err := c.quotas.Store.Add(ctx, counted.key, -counted.amount, 0, counted.resetsAt); err != nil {
+			slog.ErrorContext(ctx, "Failed to refund quota", "key", counted.key, "amount", counted.amount, "error", err)
+		}
+	}
+	c.counted = nil
+}
+
+// counterKey returns the key of the counter of metric for the period that
+// includes now, and when that period ends.
+func counterKey(userID uint, metric Metric, period Period, now time.Time) (string, time.Time) {
+	year, month, day := now.Date()
+	var start, end time.Time
+	var layout string
+	if period == Daily {
+		start = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 0, 1)
+		layout = "2006-01-02"
+	} else {
+		start = time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+		end = start.AddDate(0, 1, 0)
+		layout = "2006-01"
+	}
+	return fmt.Sprintf("quota:user:%d:%s:%s", userID, metric, start.Format(layout)), end
+}
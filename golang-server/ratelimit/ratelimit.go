@@ -0,0 +1,114 @@
+// Package ratelimit implements token-bucket rate limits and usage quotas.
+// Their state lives in a Store: MemoryStore keeps it in the process, DBStore
+// in the database, so replicas behind a load balancer share their limits.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is a token bucket that refills PerMinute tokens a minute and holds at
+// most Burst. Every request takes one token. A Limit with no PerMinute
+// doesn't limit anything.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+// Enabled reports whether the limit applies.
+func (l Limit) Enabled() bool {
+	return l.PerMinute > 0 && l.Burst > 0
+}
+
+// Window is the time an empty bucket takes to fill up again.
+func (l Limit) Window() time.Duration {
+	return time.Duration(float64(l.Burst) / l.rate() * float64(time.Second))
+}
+
+// rate is the refill rate in tokens per second.
+func (l Limit) rate() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// Result is the state of a bucket after taking a token from it.
+type Result struct {
+	Allowed bool
+	// Limit is the size of the bucket and Remaining the whole tokens left.
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token, set when not Allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps rate limit buckets and usage counters by key. Keys are opaque
+// to the store; buckets and counters have separate key spaces.
+type Store interface {
+	// Take takes a token from the bucket at key, creating a full bucket
+	// if there is none.
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Add adds amount to the counter at key and returns the new value. If
+	// max is positive and the counter would exceed it, the counter is left
+	// as it is and ok is false. A counter that doesn't exist yet starts at
+	// zero and is dropped at expires.
+	Add(ctx context.Context, key string, amount, max int64, expires time.Time) (value int64, ok bool, err error)
+	// Counters returns the values of the counters at keys. Missing and
+	// expired counters are left out.
+	Counters(ctx context.Context, keys []string) (map[string]int64, error)
+}
+
+// Clock tells the time. The stores and Quotas use time.Now when theirs is
+// nil; tests set their own.
+type Clock func() time.Time
+
+func (c Clock) now() time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c()
+}
+
+// sweepInterval is how often the stores drop full buckets and expired
+// counters.
+const sweepInterval = time.Minute
+
+// bucket is the state of a token bucket as of updated. A bucket that has
+// filled up is the same as no bucket at all, so stores may drop it.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+func newBucket(now time.Time, limit Limit) bucket {
+	return bucket{tokens: float64(limit.Burst), updated: now}
+}
+
+// take refills b for the time since it was last updated and takes a token if
+// a whole one is left.
+func (b *bucket) take(now time.Time, limit Limit) Result {
+	burst := float64(limit.Burst)
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * limit.rate()
+	}
+	// Clamping also applies a lowered burst to existing buckets.
+	b.tokens = math.Min(b.tokens, burst)
+	b.updated = now
+
+	result := Result{Limit: limit.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.rate())
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((burst - b.tokens) / limit.rate())
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,312 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/golang-postgres-crud/db/dbtest"
+	"github.com/example/golang-postgres-crud/ratelimit"
+)
+
+func TestMain(m *testing.M) {
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	os.Exit(m.Run())
+}
+
+// clock is a ratelimit.Clock that only moves when told to.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newClock(now time.Time) *clock {
+	return &clock{now: now}
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// stores opens each kind of store with the clock.
+var stores = []struct {
+	name string
+	open func(t *testing.T, clock *clock) ratelimit.Store
+}{
+	{"memory", func(t *testing.T, clock *clock) ratelimit.Store {
+		store := ratelimit.NewMemoryStore()
+		store.Clock = clock.Now
+		return store
+	}},
+	{"db", func(t *testing.T, clock *clock) ratelimit.Store {
+		store := ratelimit.NewDBStore(dbtest.Open(t))
+		store.Clock = clock.Now
+		return store
+	}},
+}
+
+var start = time.Date(2026, 1, 30, 12, 0, 0, 0, time.UTC)
+
+func TestTake(t *testing.T) {
+	// A token a second, three at most.
+	limit := ratelimit.Limit{PerMinute: 60, Burst: 3}
+	steps := []struct {
+		name       string
+		advance    time.Duration
+		allowed    bool
+		remaining  int
+		reset      time.Duration
+		retryAfter time.Duration
+	}{
+		{"full bucket", 0, true, 2, time.Second, 0},
+		{"second token", 0, true, 1, 2 * time.Second, 0},
+		{"last token", 0, true, 0, 3 * time.Second, 0},
+		{"empty", 0, false, 0, 3 * time.Second, time.Second},
+		{"half refilled", 500 * time.Millisecond, false, 0, 2500 * time.Millisecond, 500 * time.Millisecond},
+		{"refilled", 500 * time.Millisecond, true, 0, 3 * time.Second, 0},
+		{"full again", time.Minute, true, 2, time.Second, 0},
+	}
+
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			clock := newClock(start)
+			store := s.open(t, clock)
+			for _, step := range steps {
+				clock.Advance(step.advance)
+				result, err := store.Take(context.Background(), "ip:1.2.3.4", limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := ratelimit.Result{Allowed: step.allowed, Limit: 3, Remaining: step.remaining, Reset: step.reset, RetryAfter: step.retryAfter}
+				if result != want {
+					t.Errorf("%s: got %+v, want %+v", step.name, result, want)
+				}
+			}
+
+			// Buckets don't share tokens, and a lowered burst applies to
+			// existing buckets.
+			if result, _ := store.Take(context.Background(), "ip:5.6.7.8", limit); result.Remaining != 2 {
+				t.Errorf("other bucket has %d tokens left, want 2", result.Remaining)
+			}
+			result, _ := store.Take(context.Background(), "ip:1.2.3.4", ratelimit.Limit{PerMinute: 60, Burst: 1})
+			if !result.Allowed || result.Remaining != 0 || result.Limit != 1 {
+				t.Errorf("lowered burst: got %+v, want the last token", result)
+			}
+		})
+	}
+}
+
+func TestAdd(t *testing.T) {
+	ctx := context.Background()
+	for _, s := range stores {
+		t.Run(s.name, func(t *testing.T) {
+			clock := newClock(start)
+			store := s.open(t, clock)
+			expires := start.Add(time.Hour)
+
+			steps := []struct {
+				amount, max int64
+				value       int64
+				ok          bool
+			}{
+				{2, 5, 2, true},
+				{3, 5, 5, true},
+				{1, 5, 5, false},
+				// Without a max, anything goes.
+				{1, 0, 6, true},
+				{-6, 0, 0, true},
+			}
+			for i, step := range steps {
+				value, ok, err := store.Add(ctx, "counter", step.amount, step.max, expires)
+				if err != nil || value != step.value || ok != step.ok {
+					t.Errorf("step %d: got %d, %t, %v; want %d, %t", i, value, ok, err, step.value, step.ok)
+				}
+			}
+
+			store.Add(ctx, "counter", 4, 0, expires)
+			if values, _ := store.Counters(ctx, []string{"counter", "missing"}); len(values) != 1 || values["counter"] != 4 {
+				t.Errorf("counters %v, want counter at 4", values)
+			}
+
+			// An expired counter is gone, and starts over when added to.
+			clock.Advance(time.Hour)
+			if values, _ := store.Counters(ctx, []string{"counter"}); len(values) != 0 {
+				t.Errorf("expired counters %v, want none", values)
+			}
+			if value, ok, _ := store.Add(ctx, "counter", 1, 5, expires.Add(time.Hour)); value != 1 || !ok {
+				t.Errorf("expired counter: got %d, %t; want 1", value, ok)
+			}
+		})
+	}
+}
+
+func TestDBStoreConcurrentTakes(t *testing.T) {
+	ctx := context.Background()
+	store := ratelimit.NewDBStore(dbtest.Open(t))
+	store.Clock = newClock(start).Now
+	// The clock stands still, so only the burst is there to take.
+	limit := ratelimit.Limit{PerMinute: 1, Burst: 10}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var allowed, added int
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := store.Take(ctx, "user:1", limit)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, ok, err := store.Add(ctx, "counter", 1, 10, start.Add(time.Hour))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if result.Allowed {
+				allowed++
+			}
+			if ok {
+				added++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 10 || added != 10 {
+		t.Errorf("%d takes allowed and %d adds counted, want 10 each", allowed, added)
+	}
+	if values, _ := store.Counters(ctx, []string{"counter"}); values["counter"] != 10 {
+		t.Errorf("counter at %d, want 10", values["counter"])
+	}
+}
+
+func TestQuotasRollOver(t *testing.T) {
+	ctx := context.Background()
+	ocr := ratelimit.Charge{Metric: ratelimit.OCRCalls, Amount: 1}
+	tests := []struct {
+		name string
+		// start is a minute before the day or month ends.
+		start time.Time
+		// allowed is how many calls the next day allows.
+		allowed int
+		// denied is the period that denies the call after those.
+		denied ratelimit.Period
+	}{
+		{"next day", time.Date(2026, 1, 30, 23, 59, 0, 0, time.UTC), 1, ratelimit.Monthly},
+		{"next month", time.Date(2026, 1, 31, 23, 59, 0, 0, time.UTC), 2, ratelimit.Daily},
+	}
+	for _, s := range stores {
+		for _, tt := range tests {
+			t.Run(s.name+"/"+tt.name, func(t *testing.T) {
+				clock := newClock(tt.start)
+				quotas := &ratelimit.Quotas{
+					Store:  s.open(t, clock),
+					Limits: map[ratelimit.Metric]ratelimit.QuotaLimit{ratelimit.OCRCalls: {Daily: 2, Monthly: 3}},
+					Clock:  clock.Now,
+				}
+				consume := func() error {
+					_, err := quotas.Consume(ctx, 1, ocr)
+					return err
+				}
+
+				for i := 0; i < 2; i++ {
+					if err := consume(); err != nil {
+						t.Fatal(err)
+					}
+				}
+				var quotaErr *ratelimit.QuotaError
+				if err := consume(); !errors.As(err, &quotaErr) || quotaErr.Period != ratelimit.Daily || !quotaErr.ResetsAt.Equal(tt.start.Add(time.Minute)) {
+					t.Fatalf("third call: got %v, want the daily quota ending in a minute", err)
+				}
+
+				clock.Advance(2 * time.Minute)
+				for i := 0; i < tt.allowed; i++ {
+					if err := consume(); err != nil {
+						t.Fatalf("call %d of the next day: %v", i+1, err)
+					}
+				}
+				if err := consume(); !errors.As(err, &quotaErr) || quotaErr.Period != tt.denied {
+					t.Errorf("got %v, want the %s quota exceeded", err, tt.denied)
+				}
+			})
+		}
+	}
+}
+
+func TestQuotasRefund(t *testing.T) {
+	ctx := context.Background()
+	clock := newClock(time.Date(2026, 1, 31, 23, 59, 0, 0, time.UTC))
+	store := ratelimit.NewMemoryStore()
+	store.Clock = clock.Now
+	quotas := &ratelimit.Quotas{
+		Store: store,
+		Limits: map[ratelimit.Metric]ratelimit.QuotaLimit{
+			ratelimit.OCRCalls:    {Daily: 5},
+			ratelimit.UploadBytes: {Daily: 100},
+		},
+		Clock: clock.Now,
+	}
+	used := func() map[ratelimit.Metric]int64 {
+		usage, err := quotas.Usage(ctx, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		daily := make(map[ratelimit.Metric]int64)
+		for _, u := range usage {
+			if u.Period == ratelimit.Daily {
+				daily[u.Metric] = u.Used
+			}
+		}
+		return daily
+	}
+	ocr := ratelimit.Charge{Metric: ratelimit.OCRCalls, Amount: 1}
+
+	// A charge over a quota counts none of the others.
+	_, err := quotas.Consume(ctx, 1, ocr, ratelimit.Charge{Metric: ratelimit.UploadBytes, Amount: 150})
+	var quotaErr *ratelimit.QuotaError
+	if !errors.As(err, &quotaErr) || quotaErr.Metric != ratelimit.UploadBytes {
+		t.Fatalf("got %v, want the upload quota exceeded", err)
+	}
+	if got := used(); got[ratelimit.OCRCalls] != 0 || got[ratelimit.UploadBytes] != 0 {
+		t.Errorf("rejected charges left usage %v", got)
+	}
+
+	consumption, err := quotas.Consume(ctx, 1, ocr, ratelimit.Charge{Metric: ratelimit.UploadBytes, Amount: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := used(); got[ratelimit.OCRCalls] != 1 || got[ratelimit.UploadBytes] != 60 {
+		t.Errorf("usage %v, want the charges counted", got)
+	}
+	consumption.Refund(ctx)
+	consumption.Refund(ctx)
+	if got := used(); got[ratelimit.OCRCalls] != 0 || got[ratelimit.UploadBytes] != 0 {
+		t.Errorf("usage %v after a refund, want none", got)
+	}
+
+	// A refund after the period ended doesn't take from the next one.
+	consumption, _ = quotas.Consume(ctx, 1, ocr)
+	clock.Advance(2 * time.Minute)
+	quotas.Consume(ctx, 1, ocr)
+	consumption.Refund(ctx)
+	if got := used(); got[ratelimit.OCRCalls] != 1 {
+		t.Errorf("next day's usage %d after refunding the day before, want 1", got[ratelimit.OCRCalls])
+	}
+}
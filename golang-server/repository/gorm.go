@@ -0,0 +1,323 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/example/golang-postgres-crud/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewGorm returns repositories backed by the given GORM connection. The
+// queries stick to SQL that Postgres and SQLite both accept, so the same
+// implementation serves either DB_DRIVER.
+func NewGorm(db *gorm.DB) Repositories {
+	return Repositories{
+		Readings: &gormReadings{db: db},
+		Users:    &gormUsers{db: db},
+		APIKeys:  &gormAPIKeys{db: db},
+		Tags:     &gormTags{db: db},
+		Folders:  &gormFolders{db: db},
+		Audit:    &gormAudit{db: db},
+		OCRQueue: &gormOCRQueue{db: db},
+	}
+}
+
+// update writes every column of value. Unlike a plain Save, it never falls
+// back to inserting: a row deleted in the meantime yields ErrNotFound instead
+// of coming back.
+func update(db *gorm.DB, value interface{}) error {
+	result := db.Select("*").Save(value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// affected turns a write that matched no row into ErrNotFound.
+func affected(result *gorm.DB) error {
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormReadings struct {
+	db *gorm.DB
+}
+
+func (r *gormReadings) query(ctx context.Context, filter ReadingFilter) (*gorm.DB, error) {
+	query, err := r.filtered(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return query.Preload("Tags").Preload("MetadataEntries"), nil
+}
+
+func (r *gormReadings) filtered(ctx context.Context, filter ReadingFilter) (*gorm.DB, error) {
+	query := r.db.WithContext(ctx).Model(&models.TextReadings{})
+
+	if len(filter.IDs) > 0 {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+
+	for _, tag := range filter.Tags {
+		query = query.Where(`EXISTS (SELECT 1 FROM text_reading_tags JOIN tags ON tags.id = text_reading_tags.tag_id
+			WHERE text_reading_tags.text_readings_id = text_readings.id AND tags.name = ?)`, tag)
+	}
+
+	switch {
+	case filter.RootOnly:
+		query = query.Where("folder_id IS NULL")
+	case filter.FolderID != nil && filter.Recursive:
+		ids, err := folderDescendants(r.db.WithContext(ctx), *filter.FolderID)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("folder_id IN ?", ids)
+	case filter.FolderID != nil:
+		query = query.Where("folder_id = ?", *filter.FolderID)
+	}
+
+	for _, meta := range filter.Meta {
+		if meta.HasValue {
+			query = query.Where(`EXISTS (SELECT 1 FROM reading_metadata
+				WHERE reading_metadata.text_reading_id = text_readings.id AND reading_metadata.key = ? AND reading_metadata.value = ?)`, meta.Key, meta.Value)
+		} else {
+			query = query.Where(`EXISTS (SELECT 1 FROM reading_metadata
+				WHERE reading_metadata.text_reading_id = text_readings.id AND reading_metadata.key = ?)`, meta.Key)
+		}
+	}
+
+	return query, nil
+}
+
+func (r *gormReadings) List(ctx context.Context, filter ReadingFilter) ([]models.TextReadings, error) {
+	query, err := r.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var readings []models.TextReadings
+	return readings, query.Find(&readings).Error
+}
+
+func (r *gormReadings) ListBatches(ctx context.Context, filter ReadingFilter, size int, fn func([]models.TextReadings) error) error {
+	query, err := r.query(ctx, filter)
+	if err != nil {
+		return err
+	}
+	var readings []models.TextReadings
+	return query.FindInBatches(&readings, size, func(tx *gorm.DB, batch int) error {
+		return fn(readings)
+	}).Error
+}
+
+func (r *gormReadings) Count(ctx context.Context, filter ReadingFilter) (int64, error) {
+	query, err := r.filtered(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = query.Count(&count).Error
+	return count, err
+}
+
+func (r *gormReadings) ListFiles(ctx context.Context) ([]ReadingFiles, error) {
+	var files []ReadingFiles
+	err := r.db.WithContext(ctx).Model(&models.TextReadings{}).Select("id", "file_path", "processed_path").Order("id").Find(&files).Error
+	return files, err
+}
+
+func (r *gormReadings) ExistingIDs(ctx context.Context, ids []uint) ([]uint, error) {
+	existing := []uint{}
+	err := r.db.WithContext(ctx).Model(&models.TextReadings{}).Where("id IN ?", ids).Order("id").Pluck("id", &existing).Error
+	return existing, err
+}
+
+func (r *gormReadings) Get(ctx context.Context, id uint) (models.TextReadings, error) {
+	var reading models.TextReadings
+	err := r.db.WithContext(ctx).Preload("Tags").Preload("MetadataEntries").First(&reading, id).Error
+	return reading, notFound(err)
+}
+
+func (r *gormReadings) FindByContentHash(ctx context.Context, hash string) (models.TextReadings, error) {
+	var reading models.TextReadings
+	result := r.db.WithContext(ctx).Where("content_hash = ?", hash).Limit(1).Find(&reading)
+	if result.Error != nil {
+		return reading, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return reading, ErrNotFound
+	}
+	return reading, nil
+}
+
+func (r *gormReadings) Create(ctx context.Context, reading *models.TextReadings) error {
+	return r.db.WithContext(ctx).Create(reading).Error
+}
+
+func (r *gormReadings) Update(ctx context.Context, reading *models.TextReadings) error {
+	return update(r.db.WithContext(ctx).Omit("Tags", "MetadataEntries"), reading)
+}
+
+func (r *gormReadings) Delete(ctx context.Context, id uint) error {
+	return affected(r.db.WithContext(ctx).Delete(&models.TextReadings{}, id))
+}
+
+func (r *gormReadings) SetTags(ctx context.Context, id uint, names []string) ([]models.Tag, error) {
+	var tags []models.Tag
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var reading models.TextReadings
+		if err := tx.Select("id").First(&reading, id).Error; err != nil {
+			return notFound(err)
+		}
+		var err error
+		if tags, err = findOrCreateTags(tx, names); err != nil {
+			return err
+		}
+		return tx.Model(&reading).Association("Tags").Replace(tags)
+	})
+	return tags, err
+}
+
+func (r *gormReadings) AddTags(ctx context.Context, ids []uint, names []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tags, err := findOrCreateTags(tx, names)
+		if err != nil || len(tags) == 0 {
+			return err
+		}
+		for _, id := range ids {
+			reading := models.TextReadings{Model: gorm.Model{ID: id}}
+			if err := tx.Model(&reading).Association("Tags").Append(tags); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *gormReadings) RemoveTags(ctx context.Context, ids []uint, names []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var tags []models.Tag
+		if err := tx.Where("name IN ?", names).Find(&tags).Error; err != nil || len(tags) == 0 {
+			return err
+		}
+		for _, id := range ids {
+			reading := models.TextReadings{Model: gorm.Model{ID: id}}
+			if err := tx.Model(&reading).Association("Tags").Delete(tags); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// findOrCreateTags returns the tags with the given names, creating the ones
+// that don't exist yet.
+func findOrCreateTags(tx *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tag := models.Tag{Name: name}
+		if err := tx.Where(models.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (r *gormReadings) SetFolder(ctx context.Context, id uint, folderID *uint) error {
+	return affected(r.db.WithContext(ctx).Model(&models.TextReadings{}).Where("id = ?", id).Update("folder_id", folderID))
+}
+
+func (r *gormReadings) SetMetadata(ctx context.Context, id uint, metadata map[string]string) error {
+	if len(metadata) == 0 {
+		return nil
+	}
+	entries := make([]models.ReadingMetadata, 0, len(metadata))
+	for key, value := range metadata {
+		entries = append(entries, models.ReadingMetadata{TextReadingID: id, Key: key, Value: value})
+	}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "text_reading_id"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value"}),
+	}).Create(&entries).Error
+}
+
+func (r *gormReadings) DeleteMetadata(ctx context.Context, id uint, key string) error {
+	return affected(r.db.WithContext(ctx).Where("text_reading_id = ? AND key = ?", id, key).Delete(&models.ReadingMetadata{}))
+}
+
+type gormUsers struct {
+	db *gorm.DB
+}
+
+func (r *gormUsers) Get(ctx context.Context, id uint) (models.User, error) {
+	var user models.User
+	return user, notFound(r.db.WithContext(ctx).First(&user, id).Error)
+}
+
+func (r *gormUsers) GetByUsername(ctx context.Context, username string) (models.User, error) {
+	var user models.User
+	return user, notFound(r.db.WithContext(ctx).Where("username = ?", username).First(&user).Error)
+}
+
+func (r *gormUsers) GetByOIDCIdentity(ctx context.Context, issuer, subject string) (models.User, error) {
+	var user models.User
+	err := r.db.WithContext(ctx).Where("oidc_issuer = ? AND oidc_subject = ?", issuer, subject).First(&user).Error
+	return user, notFound(err)
+}
+
+func (r *gormUsers) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Create(user).Error
+}
+
+func (r *gormUsers) Update(ctx context.Context, user *models.User) error {
+	return update(r.db.WithContext(ctx), user)
+}
+
+type gormAPIKeys struct {
+	db *gorm.DB
+}
+
+func (r *gormAPIKeys) Create(ctx context.Context, apiKey *models.APIKey) error {
+	return r.db.WithContext(ctx).Create(apiKey).Error
+}
+
+func (r *gormAPIKeys) ListByUser(ctx context.Context, userID uint) ([]models.APIKey, error) {
+	var apiKeys []models.APIKey
+	return apiKeys, r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&apiKeys).Error
+}
+
+func (r *gormAPIKeys) GetForUser(ctx context.Context, userID, id uint) (models.APIKey, error) {
+	var apiKey models.APIKey
+	return apiKey, notFound(r.db.WithContext(ctx).Where("user_id = ?", userID).First(&apiKey, id).Error)
+}
+
+func (r *gormAPIKeys) GetByPrefix(ctx context.Context, prefix string) (models.APIKey, error) {
+	var apiKey models.APIKey
+	return apiKey, notFound(r.db.WithContext(ctx).Where("prefix = ?", prefix).First(&apiKey).Error)
+}
+
+func (r *gormAPIKeys) Update(ctx context.Context, apiKey *models.APIKey) error {
+	return update(r.db.WithContext(ctx), apiKey)
+}
+
+func (r *gormAPIKeys) TouchLastUsed(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&models.APIKey{}).Where("id = ?", id).UpdateColumn("last_used_at", at).Error
+}
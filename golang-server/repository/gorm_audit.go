@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/example/golang-postgres-crud/models"
+	"gorm.io/gorm"
+)
+
+type gormAudit struct {
+	db *gorm.DB
+}
+
+func (r *gormAudit) query(ctx context.Context, filter AuditFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&models.AuditEvent{})
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.ActorName != "" {
+		query = query.Where("actor_name = ?", filter.ActorName)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.Success != nil {
+		query = query.Where("success = ?", *filter.Success)
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+	return query
+}
+
+func (r *gormAudit) Create(ctx context.Context, event *models.AuditEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+func (r *gormAudit) List(ctx context.Context, filter AuditFilter, limit, offset int) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	err := r.query(ctx, filter).Order("id DESC").Limit(limit).Offset(offset).Find(&events).Error
+	return events, err
+}
+
+// Each streams the rows, so exporting the whole log takes little memory.
+func (r *gormAudit) Each(ctx context.Context, filter AuditFilter, fn func(models.AuditEvent) error) error {
+	rows, err := r.query(ctx, filter).Order("id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.AuditEvent
+		if err := r.db.ScanRows(rows, &event); err != nil {
+			return err
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/example/golang-postgres-crud/models"
+	"gorm.io/gorm"
+)
+
+type gormOCRQueue struct {
+	db *gorm.DB
+}
+
+func (q *gormOCRQueue) RequeueInterrupted(ctx context.Context) error {
+	return q.db.WithContext(ctx).Model(&models.TextReadings{}).
+		Where("ocr_status = ?", models.OcrStatusProcessing).
+		Update("ocr_status", models.OcrStatusPending).Error
+}
+
+// Claim takes the reading with a conditional update, so a reading another
+// worker claimed first is skipped.
+func (q *gormOCRQueue) Claim(ctx context.Context, afterID uint) (models.TextReadings, error) {
+	db := q.db.WithContext(ctx)
+	for {
+		var reading models.TextReadings
+		err := db.Where("ocr_status = ? AND id > ?", models.OcrStatusPending, afterID).Order("id").First(&reading).Error
+		if err != nil {
+			return reading, notFound(err)
+		}
+
+		result := db.Model(&models.TextReadings{}).
+			Where("id = ? AND ocr_status = ?", reading.ID, models.OcrStatusPending).
+			Updates(map[string]interface{}{"ocr_status": models.OcrStatusProcessing, "ocr_attempts": gorm.Expr("ocr_attempts + 1")})
+		if result.Error != nil {
+			return reading, result.Error
+		}
+		if result.RowsAffected == 1 {
+			reading.OcrStatus = models.OcrStatusProcessing
+			reading.OcrAttempts++
+			return reading, nil
+		}
+		afterID = reading.ID
+	}
+}
+
+func (q *gormOCRQueue) Complete(ctx context.Context, id uint, result OCRResult) error {
+	return q.update(ctx, id, map[string]interface{}{
+		"ocr_status":       models.OcrStatusDone,
+		"ocr_text":         result.Text,
+		"ocr_engine":       result.Engine,
+		"ocr_error":        "",
+		"processed_path":   result.ProcessedPath,
+		"preprocess_steps": result.PreprocessSteps,
+	})
+}
+
+func (q *gormOCRQueue) Fail(ctx context.Context, id uint, status string, cause error, result OCRResult) error {
+	updates := map[string]interface{}{"ocr_status": status, "ocr_error": cause.Error()}
+	recordDerivative(updates, result)
+	return q.update(ctx, id, updates)
+}
+
+func (q *gormOCRQueue) Release(ctx context.Context, id uint, cause error, result OCRResult) error {
+	updates := map[string]interface{}{
+		"ocr_status":   models.OcrStatusPending,
+		"ocr_attempts": gorm.Expr("ocr_attempts - 1"),
+		"ocr_error":    cause.Error(),
+	}
+	recordDerivative(updates, result)
+	return q.update(ctx, id, updates)
+}
+
+func (q *gormOCRQueue) update(ctx context.Context, id uint, updates map[string]interface{}) error {
+	return affected(q.db.WithContext(ctx).Model(&models.TextReadings{}).Where("id = ?", id).Updates(updates))
+}
+
+// recordDerivative adds the processed image of result, if one was saved, to
+// the updates of a reading whose OCR did not succeed.
+func recordDerivative(updates map[string]interface{}, result OCRResult) {
+	if result.ProcessedPath != "" {
+		updates["processed_path"] = result.ProcessedPath
+		updates["preprocess_steps"] = result.PreprocessSteps
+	}
+}
+
+func (q *gormOCRQueue) Depth(ctx context.Context) (map[string]int64, error) {
+	counts := map[string]int64{
+		models.OcrStatusPending:    0,
+		models.OcrStatusProcessing: 0,
+		models.OcrStatusFailed:     0,
+	}
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+
+	var rows []struct {
+		OcrStatus string
+		Count     int64
+	}
+	err := q.db.WithContext(ctx).Model(&models.TextReadings{}).
+		Select("ocr_status, count(*) AS count").
+		Where("ocr_status IN ?", statuses).
+		Group("ocr_status").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.OcrStatus] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/example/golang-postgres-crud/models"
+	"gorm.io/gorm"
+)
+
+type gormTags struct {
+	db *gorm.DB
+}
+
+func (r *gormTags) List(ctx context.Context) ([]models.Tag, error) {
+	var tags []models.Tag
+	return tags, r.db.WithContext(ctx).Order("name").Find(&tags).Error
+}
+
+func (r *gormTags) Get(ctx context.Context, id uint) (models.Tag, error) {
+	var tag models.Tag
+	return tag, notFound(r.db.WithContext(ctx).First(&tag, id).Error)
+}
+
+func (r *gormTags) NameTaken(ctx context.Context, name string, exceptID uint) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Tag{}).Where("name = ? AND id <> ?", name, exceptID).Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormTags) Create(ctx context.Context, tag *models.Tag) error {
+	return r.db.WithContext(ctx).Create(tag).Error
+}
+
+func (r *gormTags) Update(ctx context.Context, tag *models.Tag) error {
+	return update(r.db.WithContext(ctx), tag)
+}
+
+func (r *gormTags) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM text_reading_tags WHERE tag_id = ?", id).Error; err != nil {
+			return err
+		}
+		return affected(tx.Delete(&models.Tag{}, id))
+	})
+}
+
+type gormFolders struct {
+	db *gorm.DB
+}
+
+func (r *gormFolders) List(ctx context.Context, filter FolderFilter) ([]models.Folder, error) {
+	query := r.db.WithContext(ctx).Order("name")
+	switch {
+	case filter.RootOnly:
+		query = query.Where("parent_id IS NULL")
+	case filter.ParentID != nil:
+		query = query.Where("parent_id = ?", *filter.ParentID)
+	}
+	var folders []models.Folder
+	return folders, query.Find(&folders).Error
+}
+
+func (r *gormFolders) Get(ctx context.Context, id uint) (models.Folder, error) {
+	var folder models.Folder
+	return folder, notFound(r.db.WithContext(ctx).First(&folder, id).Error)
+}
+
+func (r *gormFolders) Descendants(ctx context.Context, id uint) ([]uint, error) {
+	return folderDescendants(r.db.WithContext(ctx), id)
+}
+
+// folderDescendants returns the IDs of the folder and all folders below it.
+func folderDescendants(db *gorm.DB, rootID uint) ([]uint, error) {
+	var folders []models.Folder
+	if err := db.Select("id", "parent_id").Find(&folders).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[uint][]uint)
+	for _, f := range folders {
+		if f.ParentID != nil {
+			children[*f.ParentID] = append(children[*f.ParentID], f.ID)
+		}
+	}
+
+	ids := []uint{rootID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids, nil
+}
+
+func (r *gormFolders) NameTaken(ctx context.Context, name string, parentID *uint, exceptID uint) (bool, error) {
+	query := r.db.WithContext(ctx).Model(&models.Folder{}).Where("name = ? AND id <> ?", name, exceptID)
+	if parentID != nil {
+		query = query.Where("parent_id = ?", *parentID)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
+	var count int64
+	err := query.Count(&count).Error
+	return count > 0, err
+}
+
+func (r *gormFolders) CountChildren(ctx context.Context, id uint) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.Folder{}).Where("parent_id = ?", id).Count(&count).Error
+	return count, err
+}
+
+func (r *gormFolders) Create(ctx context.Context, folder *models.Folder) error {
+	return r.db.WithContext(ctx).Create(folder).Error
+}
+
+func (r *gormFolders) Update(ctx context.Context, folder *models.Folder) error {
+	return update(r.db.WithContext(ctx), folder)
+}
+
+func (r *gormFolders) Delete(ctx context.Context, id uint) error {
+	return affected(r.db.WithContext(ctx).Delete(&models.Folder{}, id))
+}
@@ -0,0 +1,38 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/example/golang-postgres-crud/db/dbtest"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/repository"
+)
+
+func TestExistingIDs(t *testing.T) {
+	ctx := context.Background()
+	repos := repository.NewGorm(dbtest.Open(t))
+	for i := 0; i < 3; i++ {
+		if err := repos.Readings.Create(ctx, &models.TextReadings{FilePath: "scan.png"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repos.Readings.Delete(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		ids  []uint
+		want []uint
+	}{
+		{[]uint{3, 1, 2, 9, 1}, []uint{1, 3}},
+		{[]uint{2, 9}, []uint{}},
+	}
+	for _, tt := range tests {
+		got, err := repos.Readings.ExistingIDs(ctx, tt.ids)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ExistingIDs(%v) = %v, %v; want %v", tt.ids, got, err, tt.want)
+		}
+	}
+}
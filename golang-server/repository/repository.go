@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/example/golang-postgres-crud/models"
+)
+
+// ErrNotFound is returned when the requested record does not exist.
+var ErrNotFound = errors.New("record not found")
+
+// ReadingFilter selects text readings. Empty fields don't restrict the result.
+type ReadingFilter struct {
+	IDs []uint
+	// Tags lists normalized tag names; a reading must have all of them.
+	Tags []string
+	// FolderID selects one folder, including its subfolders when Recursive
+	// is set. RootOnly selects readings outside any folder instead.
+	FolderID  *uint
+	Recursive bool
+	RootOnly  bool
+	Meta      []MetaFilter
+}
+
+// MetaFilter requires a metadata key, and its value too when HasValue is set.
+type MetaFilter struct {
+	Key      string
+	Value    string
+	HasValue bool
+}
+
+// ReadingFiles names the image files of a reading.
+type ReadingFiles struct {
+	ID            uint
+	FilePath      string
+	ProcessedPath string
+}
+
+// ReadingRepository stores text readings with their tags, folder and
+// metadata. Tag names passed to it must be normalized, as the handlers do,
+// and appear once.
+type ReadingRepository interface {
+	List(ctx context.Context, filter ReadingFilter) ([]models.TextReadings, error)
+	// ListBatches calls fn with consecutive batches of matching readings,
+	// ordered by ID, and stops at the first error fn returns.
+	ListBatches(ctx context.Context, filter ReadingFilter, size int, fn func([]models.TextReadings) error) error
+	Count(ctx context.Context, filter ReadingFilter) (int64, error)
+	// ListFiles returns the files of every reading, ordered by ID.
+	ListFiles(ctx context.Context) ([]ReadingFiles, error)
+	// ExistingIDs returns the IDs among ids that belong to a reading, in
+	// ascending order, without loading the readings.
+	ExistingIDs(ctx context.Context, ids []uint) ([]uint, error)
+	Get(ctx context.Context, id uint) (models.TextReadings, error)
+	FindByContentHash(ctx context.Context, hash string) (models.TextReadings, error)
+	Create(ctx context.Context, reading *models.TextReadings) error
+	Update(ctx context.Context, reading *models.TextReadings) error
+	Delete(ctx context.Context, id uint) error
+
+	// SetTags replaces the tags of a reading, creating the ones that don't
+	// exist yet, and returns them.
+	SetTags(ctx context.Context, id uint, names []string) ([]models.Tag, error)
+	// AddTags adds the tags to each of the readings, creating the ones that
+	// don't exist yet.
+	AddTags(ctx context.Context, ids []uint, names []string) error
+	// RemoveTags removes the tags from each of the readings. Unknown names
+	// are ignored.
+	RemoveTags(ctx context.Context, ids []uint, names []string) error
+	// SetFolder moves a reading into a folder, or to the top level when
+	// folderID is nil.
+	SetFolder(ctx context.Context, id uint, folderID *uint) error
+	// SetMetadata adds or overwrites metadata keys of a reading.
+	SetMetadata(ctx context.Context, id uint, metadata map[string]string) error
+	DeleteMetadata(ctx context.Context, id uint, key string) error
+}
+
+// OCRResult is what an OCR job stores on a reading.
+type OCRResult struct {
+	Engine string
+	Text   string
+	// ProcessedPath is the preprocessed image OCR read, if PreprocessSteps
+	// were applied.
+	ProcessedPath   string
+	PreprocessSteps string
+}
+
+// OCRQueue holds the readings waiting for OCR, in their OCR status.
+// Readings are claimed before OCR runs, so several workers can share it.
+//
+// Fail and Release record the processed image of the result too, if one was
+// saved, so the file stays referred to; a retry overwrites it.
+type OCRQueue interface {
+	// RequeueInterrupted queues the readings left processing again.
+	RequeueInterrupted(ctx context.Context) error
+	// Claim marks the first pending reading after afterID as processing,
+	// counting the attempt, and returns it. It returns ErrNotFound when no
+	// reading is pending.
+	Claim(ctx context.Context, afterID uint) (models.TextReadings, error)
+	// Complete stores the result of successful OCR.
+	Complete(ctx context.Context, id uint, result OCRResult) error
+	// Fail records a failed attempt, leaving the reading in status: pending
+	// to retry it or failed.
+	Fail(ctx context.Context, id uint, status string, cause error, result OCRResult) error
+	// Release puts a claimed reading back in the queue without counting the
+	// attempt.
+	Release(ctx context.Context, id uint, cause error, result OCRResult) error
+	// Depth counts the readings pending, processing and failed, by status.
+	Depth(ctx context.Context) (map[string]int64, error)
+}
+
+type UserRepository interface {
+	Get(ctx context.Context, id uint) (models.User, error)
+	GetByUsername(ctx context.Context, username string) (models.User, error)
+	GetByOIDCIdentity(ctx context.Context, issuer, subject string) (models.User, error)
+	Create(ctx context.Context, user *models.User) error
+	Update(ctx context.Context, user *models.User) error
+}
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, apiKey *models.APIKey) error
+	ListByUser(ctx context.Context, userID uint) ([]models.APIKey, error)
+	GetForUser(ctx context.Context, userID, id uint) (models.APIKey, error)
+	GetByPrefix(ctx context.Context, prefix string) (models.APIKey, error)
+	Update(ctx context.Context, apiKey *models.APIKey) error
+	TouchLastUsed(ctx context.Context, id uint, at time.Time) error
+}
+
+type TagRepository interface {
+	// List returns all tags, ordered by name.
+	List(ctx context.Context) ([]models.Tag, error)
+	Get(ctx context.Context, id uint) (models.Tag, error)
+	// NameTaken reports whether a tag other than exceptID has the name.
+	NameTaken(ctx context.Context, name string, exceptID uint) (bool, error)
+	Create(ctx context.Context, tag *models.Tag) error
+	Update(ctx context.Context, tag *models.Tag) error
+	// Delete deletes a tag and removes it from all readings.
+	Delete(ctx context.Context, id uint) error
+}
+
+// FolderFilter selects folders: the children of ParentID, or the top level
+// with RootOnly. The zero value selects all folders.
+type FolderFilter struct {
+	ParentID *uint
+	RootOnly bool
+}
+
+type FolderRepository interface {
+	// List returns the matching folders, ordered by name.
+	List(ctx context.Context, filter FolderFilter) ([]models.Folder, error)
+	Get(ctx context.Context, id uint) (models.Folder, error)
+	// Descendants returns the IDs of the folder and all folders below it.
+	Descendants(ctx context.Context, id uint) ([]uint, error)
+	// NameTaken reports whether a folder other than exceptID has the name
+	// in the parent, or at the top level when parentID is nil.
+	NameTaken(ctx context.Context, name string, parentID *uint, exceptID uint) (bool, error)
+	// CountChildren counts the folders directly inside a folder.
+	CountChildren(ctx context.Context, id uint) (int64, error)
+	Create(ctx context.Context, folder *models.Folder) error
+	Update(ctx context.Context, folder *models.Folder) error
+	Delete(ctx context.Context, id uint) error
+}
+
+// AuditFilter selects audit events. Empty fields don't restrict the result;
+// Since is inclusive and Until exclusive.
+type AuditFilter struct {
+	ActorID    *uint
+	ActorName  string
+	Action     string
+	TargetType string
+	TargetID   string
+	IP         string
+	Success    *bool
+	Since      time.Time
+	Until      time.Time
+}
+
+// AuditRepository stores the audit log, which is only ever appended to.
+type AuditRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+	// List returns a page of matching events, newest first.
+	List(ctx context.Context, filter AuditFilter, limit, offset int) ([]models.AuditEvent, error)
+	// Each calls fn with the matching events one at a time, oldest first,
+	// and stops at the first error fn returns.
+	Each(ctx context.Context, filter AuditFilter, fn func(models.AuditEvent) error) error
+}
+
+// Repositories bundles the repositories the application is built from.
+type Repositories struct {
+	Readings ReadingRepository
+	Users    UserRepository
+	APIKeys  APIKeyRepository
+	Tags     TagRepository
+	Folders  FolderRepository
+	Audit    AuditRepository
+	OCRQueue OCRQueue
+}
@@ -1,36 +1,129 @@
 package routes
 
 import (
+	"net/http"
+
+	"github.com/example/golang-postgres-crud/auth"
+	"github.com/example/golang-postgres-crud/config"
 	"github.com/example/golang-postgres-crud/handlers"
+	"github.com/example/golang-postgres-crud/metrics"
 	"github.com/example/golang-postgres-crud/middleware"
+	"github.com/example/golang-postgres-crud/models"
+	"github.com/example/golang-postgres-crud/problem"
+	"github.com/example/golang-postgres-crud/ratelimit"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
 	_ "github.com/example/golang-postgres-crud/docs"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func SetupRouter() *gin.Engine {
-	router := gin.Default()
+func SetupRouter(app *handlers.App) *gin.Engine {
+	router := gin.New()
+	router.MaxMultipartMemory = config.Current.Limits.MultipartMemory
+	if err := router.SetTrustedProxies(config.Current.HTTP.TrustedProxyList()); err != nil {
+		// config.Validate has checked every entry.
+		panic(err)
+	}
+	router.Use(middleware.RequestID())
+	router.Use(otelgin.Middleware(config.Current.Tracing.ServiceName, otelgin.WithGinFilter(tracedRoute)))
+	router.Use(metrics.Middleware())
+	router.Use(middleware.Logger(), middleware.Recovery())
+	router.Use(middleware.BodyLimit(config.Current.Limits.MaxBodyBytes))
+	router.NoRoute(func(c *gin.Context) {
+		problem.Write(c, http.StatusNotFound, problem.RouteNotFound, "No route matches "+c.Request.Method+" "+c.Request.URL.Path)
+	})
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	router.POST("/register", handlers.RegisterHandler)
-	router.POST("/login", handlers.LoginHandler)
+	router.GET("/healthz", app.HealthzHandler)
+	router.GET("/readyz", app.ReadyzHandler)
+	router.GET("/metrics", metrics.Handler())
+
+	// Probes, scrapes and the docs are left out of the rate limits.
+	limits := config.Current.RateLimit
+	limited := router.Group("", middleware.RateLimit(app.RateLimits,
+		middleware.IPRule(ratelimit.Limit{PerMinute: limits.IPPerMinute, Burst: limits.IPBurst})))
+
+	limited.POST("/register", app.RegisterHandler)
+	limited.POST("/login", app.LoginHandler)
+	limited.GET("/.well-known/jwks.json", app.JWKSHandler)
+	limited.GET("/auth/oidc/login", app.OIDCLoginHandler)
+	limited.GET("/auth/oidc/callback", app.OIDCCallbackHandler)
 
-	router.GET("/ws/text-readings", handlers.TextReadingWebSocketHandler)
+	limited.GET("/ws/text-readings", app.TextReadingWebSocketHandler)
 
-	api := router.Group("/api")
-	api.Use(middleware.AuthMiddleware())
+	ocrLimit := middleware.RateLimit(app.RateLimits, middleware.OCRRule(app.OCRLimit))
+
+	api := limited.Group("/api")
+	api.Use(middleware.AuthMiddleware(middleware.Authenticator{
+		Tokens:  app.Tokens,
+		APIKeys: app.APIKeys,
+		Users:   app.Users,
+	}))
+	api.Use(middleware.RateLimit(app.RateLimits,
+		middleware.UserRule(ratelimit.Limit{PerMinute: limits.UserPerMinute, Burst: limits.UserBurst}),
+		middleware.APIKeyRule(ratelimit.Limit{PerMinute: limits.APIKeyPerMinute, Burst: limits.APIKeyBurst})))
 	{
-		api.POST("/text-readings", handlers.CreateTextReading)
-		api.GET("/text-readings", handlers.GetTextReadings)
-		api.GET("/text-readings/:id", handlers.GetTextReading)
-		api.PUT("/text-readings/:id", handlers.UpdateTextReading)
-		api.DELETE("/text-readings/:id", handlers.DeleteTextReading)
-		api.GET("/text-readings/:id/image", handlers.GetTextReadingImage)
-		api.POST("/ocr", handlers.PerformOcr)
+		api.POST("/text-readings", middleware.RequireScope(auth.ScopeTextReadingsWrite), ocrLimit, app.CreateTextReading)
+		api.GET("/text-readings", middleware.RequireScope(auth.ScopeTextReadingsRead), app.GetTextReadings)
+		api.GET("/text-readings/:id", middleware.RequireScope(auth.ScopeTextReadingsRead), app.GetTextReading)
+		api.PUT("/text-readings/:id", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.UpdateTextReading)
+		api.DELETE("/text-readings/:id", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.DeleteTextReading)
+		api.GET("/text-readings/:id/image", middleware.RequireScope(auth.ScopeTextReadingsRead), app.GetTextReadingImage)
+		api.GET("/text-readings/export", middleware.RequireScope(auth.ScopeTextReadingsRead), app.ExportTextReadings)
+		api.GET("/text-readings/:id/export", middleware.RequireScope(auth.ScopeTextReadingsRead), app.ExportTextReading)
+		api.PUT("/text-readings/:id/tags", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.SetTextReadingTags)
+		api.PUT("/text-readings/:id/folder", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.SetTextReadingFolder)
+		api.GET("/text-readings/:id/metadata", middleware.RequireScope(auth.ScopeTextReadingsRead), app.GetTextReadingMetadata)
+		api.PUT("/text-readings/:id/metadata", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.UpdateTextReadingMetadata)
+		api.DELETE("/text-readings/:id/metadata/:key", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.DeleteTextReadingMetadata)
+		api.POST("/text-readings/bulk/tag", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.BulkTagTextReadings)
+		api.POST("/text-readings/bulk/untag", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.BulkUntagTextReadings)
+
+		api.GET("/tags", middleware.RequireScope(auth.ScopeTextReadingsRead), app.GetTags)
+		api.POST("/tags", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.CreateTag)
+		api.PUT("/tags/:id", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.UpdateTag)
+		api.DELETE("/tags/:id", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.DeleteTag)
+
+		api.GET("/folders", middleware.RequireScope(auth.ScopeTextReadingsRead), app.GetFolders)
+		api.GET("/folders/:id", middleware.RequireScope(auth.ScopeTextReadingsRead), app.GetFolder)
+		api.POST("/folders", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.CreateFolder)
+		api.PUT("/folders/:id", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.UpdateFolder)
+		api.DELETE("/folders/:id", middleware.RequireScope(auth.ScopeTextReadingsWrite), app.DeleteFolder)
+		api.POST("/ocr", middleware.RequireScope(auth.ScopeOCR), ocrLimit, app.PerformOcr)
+
+		api.GET("/me", app.GetMe)
+		api.GET("/usage", app.GetUsage)
+		api.PUT("/me", middleware.RequireJWT(), app.UpdateMe)
+		api.POST("/me/oidc", middleware.RequireJWT(), app.StartOIDCLink)
+
+		apiKeys := api.Group("/api-keys")
+		apiKeys.Use(middleware.RequireJWT())
+		{
+			apiKeys.POST("", app.CreateAPIKey)
+			apiKeys.GET("", app.ListAPIKeys)
+			apiKeys.DELETE("/:id", app.RevokeAPIKey)
+		}
+
+		admin := api.Group("/admin")
+		admin.Use(middleware.RequireJWT(), middleware.RequireRole(app.Users, models.RoleAdmin))
+		{
+			admin.GET("/audit-events", app.GetAuditEvents)
+			admin.GET("/audit-events/export", app.ExportAuditEvents)
+			admin.DELETE("/ocr-cache", app.PurgeOCRCache)
+		}
 	}
 
 	return router
 }
+
+// tracedRoute leaves probes and scrapes out of the traces.
+func tracedRoute(c *gin.Context) bool {
+	switch c.FullPath() {
+	case "/healthz", "/readyz", "/metrics":
+		return false
+	}
+	return true
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// trashDir holds images whose removal can still be undone, under the name
+// they had in the image directory.
+const trashDir = ".trash"
+
+// Storage keeps the image files of text readings. Paths returned by Save are
+// what the readings store and what the other methods accept.
+//
+// Deleting a reading moves its image to the trash first, so the file can be
+// restored if the database delete fails, and purges it once the row is gone.
+type Storage interface {
+	Save(filename string, data io.Reader) (string, error)
+	// SaveDerivative stores an image made from the one at filePath next to
+	// it, under its name with the extension replaced by suffix.
+	SaveDerivative(filePath, suffix string, data io.Reader) (string, error)
+	Open(filePath string) (io.ReadSeekCloser, error)
+	Delete(filePath string) error
+	// List returns all stored images, trashed ones excluded.
+	List() ([]Object, error)
+
+	Trash(filePath string) error
+	Restore(filePath string) error
+	Purge(filePath string) error
+	// ListTrash returns the trashed images under their original paths.
+	ListTrash() ([]Object, error)
+}
+
+// Object describes a stored image. For a trashed image, ModTime is when it
+// was trashed.
+type Object struct {
+	Path    string
+	ModTime time.Time
+}
+
+// ContentHash returns the hex SHA-256 of the image data. It identifies an
+// image independently of its file name, so repeated imports can be detected.
+func ContentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ContentHashOf returns the ContentHash of the image read from r.
+func ContentHashOf(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Disk stores images as files in a local directory.
+type Disk struct {
+	Dir string
+}
+
+func NewDisk(dir string) *Disk {
+	return &Disk{Dir: dir}
+}
+
+// Save copies the image into a file and returns its path. The file name
+// gets a short prefix so uploads with the same name don't overwrite each
+// other.
+func (d *Disk) Save(filename string, data io.Reader) (string, error) {
+	if err := os.MkdirAll(d.Dir, 0750); err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	return writeFileAtomic(d.Dir, io.TeeReader(data, hash), func() string {
+		hash.Write([]byte(time.Now().String()))
+		hashString := hex.EncodeToString(hash.Sum(nil))[:5]
+		return filepath.Join(d.Dir, fmt.Sprintf("%s_%s", hashString, filepath.Base(filename)))
+	})
+}
+
+// SaveDerivative writes data to the original's path with its extension
+// replaced by suffix, replacing an earlier derivative.
+func (d *Disk) SaveDerivative(filePath, suffix string, data io.Reader) (string, error) {
+	return writeFileAtomic(filepath.Dir(filePath), data, func() string {
+		return DerivativePath(filePath, suffix)
+	})
+}
+
+// DerivativePath returns where SaveDerivative stores the derivative of the
+// image at filePath.
+func DerivativePath(filePath, suffix string) string {
+	return strings.TrimSuffix(filePath, filepath.Ext(filePath)) + suffix
+}
+
+// writeFileAtomic copies data to a hidden temporary file in dir and renames
+// it into place under the path name returns once data is written, so a
+// crash or a full disk never leaves a truncated image behind.
+func writeFileAtomic(dir string, data io.Reader, name func() string) (string, error) {
+	file, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), 0644)
+	}
+	filePath := ""
+	if err == nil {
+		filePath = name()
+		err = os.Rename(file.Name(), filePath)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return filePath, nil
+}
+
+// Open returns the stored image. A missing file yields an error satisfying
+// errors.Is(err, fs.ErrNotExist).
+func (d *Disk) Open(filePath string) (io.ReadSeekCloser, error) {
+	return os.Open(filePath)
+}
+
+// Delete removes a stored image. A file that is already gone is not an
+// error.
+func (d *Disk) Delete(filePath string) error {
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List returns the images in the directory. Hidden files, such as uploads
+// still being written and the trash, are skipped.
+func (d *Disk) List() ([]Object, error) {
+	return listImages(d.Dir, d.Dir)
+}
+
+// Trash moves the image into the trash directory next to it and stamps it
+// with the current time.
+func (d *Disk) Trash(filePath string) error {
+	trashPath := d.trashPath(filePath)
+	if err := os.MkdirAll(filepath.Dir(trashPath), 0750); err != nil {
+		return err
+	}
+	if err := os.Rename(filePath, trashPath); err != nil {
+		return err
+	}
+	now := time.Now()
+	return os.Chtimes(trashPath, now, now)
+}
+
+// Restore moves a trashed image back to its original path. It fails with an
+// error satisfying errors.Is(err, fs.ErrNotExist) if the image is not in the
+// trash.
+func (d *Disk) Restore(filePath string) error {
+	return os.Rename(d.trashPath(filePath), filePath)
+}
+
+// Purge removes a trashed image for good. An image that is not in the trash
+// is not an error.
+func (d *Disk) Purge(filePath string) error {
+	return d.Delete(d.trashPath(filePath))
+}
+
+// ListTrash returns the images in the trash under their original paths.
+func (d *Disk) ListTrash() ([]Object, error) {
+	return listImages(filepath.Join(d.Dir, trashDir), d.Dir)
+}
+
+func (d *Disk) trashPath(filePath string) string {
+	return filepath.Join(d.Dir, trashDir, filepath.Base(filePath))
+}
+
+// listImages returns the regular, non-hidden files in dir as paths under
+// base. A missing directory holds no images.
+func listImages(dir, base string) ([]Object, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, Object{Path: filepath.Join(base, entry.Name()), ModTime: info.ModTime()})
+	}
+	return objects, nil
+}
+
+// Check makes sure images can be written by creating and removing a
+// temporary file.
+func (d *Disk) Check(ctx context.Context) error {
+	if err := os.MkdirAll(d.Dir, 0750); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(d.Dir, ".healthcheck-*")
+	if err != nil {
+		return err
+	}
+	_, writeErr := file.Write([]byte("ok"))
+	closeErr := file.Close()
+	removeErr := os.Remove(file.Name())
+	return errors.Join(writeErr, closeErr, removeErr)
+}
@@ -24,7 +24,7 @@ _sym_db = _symbol_database.Default()
 
 
 
-DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\tocr.proto\x12\x03ocr\" \n\nOcrRequest\x12\x12\n\nimage_data\x18\x01 \x01(\x0c\"%\n\x0bOcrResponse\x12\x16\n\x0e\x65xtracted_text\x18\x01 \x01(\t2?\n\nOcrService\x12\x31\n\nPerformOcr\x12\x0f.ocr.OcrRequest\x1a\x10.ocr.OcrResponse\"\x00\x42-Z+github.com/example/golang-postgres-crud/ocrb\x06proto3')
+DESCRIPTOR = _descriptor_pool.Default().AddSerializedFile(b'\n\tocr.proto\x12\x03ocr\" \n\nOcrRequest\x12\x12\n\nimage_data\x18\x01 \x01(\x0c\"%\n\x0bOcrResponse\x12\x16\n\x0e\x65xtracted_text\x18\x01 \x01(\t\"\x18\n\x08OcrChunk\x12\x0c\n\x04\x64\x61ta\x18\x01 \x01(\x0c\x32x\n\nOcrService\x12\x31\n\nPerformOcr\x12\x0f.ocr.OcrRequest\x1a\x10.ocr.OcrResponse\"\x00\x12\x37\n\x10PerformOcrStream\x12\r.ocr.OcrChunk\x1a\x10.ocr.OcrResponse\"\x00(\x01\x42-Z+github.com/example/golang-postgres-crud/ocrb\x06proto3')
 
 _globals = globals()
 _builder.BuildMessageAndEnumDescriptors(DESCRIPTOR, _globals)
@@ -36,6 +36,8 @@ if not _descriptor._USE_C_DESCRIPTORS:
   _globals['_OCRREQUEST']._serialized_end=50
   _globals['_OCRRESPONSE']._serialized_start=52
   _globals['_OCRRESPONSE']._serialized_end=89
-  _globals['_OCRSERVICE']._serialized_start=91
-  _globals['_OCRSERVICE']._serialized_end=154
+  _globals['_OCRCHUNK']._serialized_start=91
+  _globals['_OCRCHUNK']._serialized_end=115
+  _globals['_OCRSERVICE']._serialized_start=117
+  _globals['_OCRSERVICE']._serialized_end=237
 # @@protoc_insertion_point(module_scope)
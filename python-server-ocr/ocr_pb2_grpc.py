@@ -39,6 +39,11 @@ class OcrServiceStub(object):
                 request_serializer=ocr__pb2.OcrRequest.SerializeToString,
                 response_deserializer=ocr__pb2.OcrResponse.FromString,
                 _registered_method=True)
+        self.PerformOcrStream = channel.stream_unary(
+                '/ocr.OcrService/PerformOcrStream',
+                request_serializer=ocr__pb2.OcrChunk.SerializeToString,
+                response_deserializer=ocr__pb2.OcrResponse.FromString,
+                _registered_method=True)
 
 
 class OcrServiceServicer(object):
@@ -50,6 +55,15 @@ class OcrServiceServicer(object):
         context.set_details('Method not implemented!')
         raise NotImplementedError('Method not implemented!')
 
+    def PerformOcrStream(self, request_iterator, context):
+        """PerformOcrStream takes the image in chunks, so images of any size fit
+        under the message size limit. It answers once the client closes the
+        stream.
+        """
+        context.set_code(grpc.StatusCode.UNIMPLEMENTED)
+        context.set_details('Method not implemented!')
+        raise NotImplementedError('Method not implemented!')
+
 
 def add_OcrServiceServicer_to_server(servicer, server):
     rpc_method_handlers = {
@@ -58,6 +72,11 @@ def add_OcrServiceServicer_to_server(servicer, server):
                     request_deserializer=ocr__pb2.OcrRequest.FromString,
                     response_serializer=ocr__pb2.OcrResponse.SerializeToString,
             ),
+            'PerformOcrStream': grpc.stream_unary_rpc_method_handler(
+                    servicer.PerformOcrStream,
+                    request_deserializer=ocr__pb2.OcrChunk.FromString,
+                    response_serializer=ocr__pb2.OcrResponse.SerializeToString,
+            ),
     }
     generic_handler = grpc.method_handlers_generic_handler(
             'ocr.OcrService', rpc_method_handlers)
@@ -95,3 +114,30 @@ class OcrService(object):
             timeout,
             metadata,
             _registered_method=True)
+
+    @staticmethod
+    def PerformOcrStream(request_iterator,
+            target,
+            options=(),
+            channel_credentials=None,
+            call_credentials=None,
+            insecure=False,
+            compression=None,
+            wait_for_ready=None,
+            timeout=None,
+            metadata=None):
+        return grpc.experimental.stream_unary(
+            request_iterator,
+            target,
+            '/ocr.OcrService/PerformOcrStream',
+            ocr__pb2.OcrChunk.SerializeToString,
+            ocr__pb2.OcrResponse.FromString,
+            options,
+            channel_credentials,
+            insecure,
+            call_credentials,
+            compression,
+            wait_for_ready,
+            timeout,
+            metadata,
+            _registered_method=True)
@@ -1,9 +1,11 @@
 import grpc
 from concurrent import futures
+from grpc_health.v1 import health, health_pb2, health_pb2_grpc
 import ocr_pb2
 import ocr_pb2_grpc
 import easyocr
 import logging
+import tempfile
 
 logging.basicConfig(level=logging.INFO, format='%(asctime)s - %(levelname)s - %(message)s')
 
@@ -16,11 +18,41 @@ class OcrServiceImpl(ocr_pb2_grpc.OcrServiceServicer):
             raise
 
     def PerformOcr(self, request, context):
+        self._log_request(context)
+        return self._recognize(request.image_data, context)
+
+    def PerformOcrStream(self, request_iterator, context):
+        # Chunks are spooled to a temporary file, so a large image never sits
+        # in memory as a whole before EasyOCR decodes it.
+        self._log_request(context)
+        with tempfile.NamedTemporaryFile(suffix=".img") as image_file:
+            for chunk in request_iterator:
+                image_file.write(chunk.data)
+            image_file.flush()
+            size = image_file.tell()
+            logging.info(f"Received image of {size} bytes in chunks.")
+            return self._recognize(image_file.name if size else b"", context)
+
+    def _log_request(self, context):
+        # The Go client sends its request ID and W3C trace context, so OCR
+        # logs can be matched with the request that caused them.
+        metadata = dict(context.invocation_metadata())
+        logging.info(
+            f"Received new OCR request (request id {metadata.get('x-request-id', '-')}, "
+            f"traceparent {metadata.get('traceparent', '-')})."
+        )
+
+    def _recognize(self, image, context):
+        # image is the image data, or the path of a file holding it.
         try:
-            logging.info("Received new OCR request.")
-            image_bytes = request.image_data
+            if not image:
+                # The Go server maps INVALID_ARGUMENT to 422, so the client
+                # learns that retrying the same image won't help.
+                context.set_code(grpc.StatusCode.INVALID_ARGUMENT)
+                context.set_details("Image data is empty")
+                return ocr_pb2.OcrResponse()
 
-            result = self.reader.readtext(image_bytes, paragraph=True)
+            result = self.reader.readtext(image, paragraph=True)
 
             extracted_text = "\n".join([item[1] for item in result])
 
@@ -40,6 +72,12 @@ def serve():
 
     ocr_pb2_grpc.add_OcrServiceServicer_to_server(OcrServiceImpl(), server)
 
+    # The Go server's /readyz asks this service whether OCR is available.
+    health_servicer = health.HealthServicer()
+    health_pb2_grpc.add_HealthServicer_to_server(health_servicer, server)
+    for service in ("", "ocr.OcrService"):
+        health_servicer.set(service, health_pb2.HealthCheckResponse.SERVING)
+
     server.add_insecure_port('[::]:50051')
 
     logging.info("Starting server on port 50051...")